@@ -0,0 +1,171 @@
+package attack
+
+import (
+	"ethattacksim/consensus"
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// CensorshipConsensus never includes txs of the censored senders in its blocks.
+// With feather forking it additionally refuses to build on blocks containing censored txs
+// until enough blocks were mined on top of them, trying to orphan these blocks with its own.
+// Random txs filling blocks (without creation time) are not real txs and therefore never censored.
+type CensorshipConsensus struct {
+	interfaces.IConsensus
+	config              interfaces.IAttackerConfig
+	initialized         bool
+	censoredSenders     map[string]bool
+	featherForkingDepth int             // blocks on top of a block with censored txs needed until it is accepted, 0 disables feather forking
+	ignoredHashes       map[string]bool // blocks not accepted because of censored txs
+}
+
+func NewCensorshipConsensus(consensus interfaces.IConsensus, config interfaces.IAttackerConfig) interfaces.IConsensus {
+	return &CensorshipConsensus{IConsensus: consensus, config: config, censoredSenders: make(map[string]bool), ignoredHashes: make(map[string]bool)}
+}
+
+func init() {
+	RegisterAttackerType("censorship", AttackerType{
+		Numbers: map[string]AttackerParam{
+			"featherForkingDepth": {Description: "blocks on top of a block with censored txs until it is accepted, 0 disables feather forking"},
+		},
+		Strings: map[string]AttackerParam{
+			"censoredSenders": {Required: true, Description: "comma separated sender ids whose txs are never included"},
+		},
+		ProofOfStake: true,
+		NewGroup: func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+			return func() interfaces.IConsensus {
+				return NewCensorshipConsensus(consensus.NewConfiguredConsensus(world.SimConfig()), config)
+			}
+		},
+	})
+}
+
+func (c *CensorshipConsensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld) {
+	if !c.initialized {
+		c.initialize(world)
+	}
+	c.IConsensus.MineBlock(ledger, node, world)
+}
+
+// GetTxsForBlock never includes txs of the censored senders.
+func (c *CensorshipConsensus) GetTxsForBlock(gasUsed int, txs []interfaces.ITransaction, gasLimit int, baseFee int, pending interfaces.IAccounts, minerId string) (transactions []interfaces.ITransaction, gasAmount int) {
+	filteredTxs := make([]interfaces.ITransaction, 0, len(txs))
+	for _, tx := range txs {
+		if !c.isCensored(tx) {
+			filteredTxs = append(filteredTxs, tx)
+		}
+	}
+	return c.IConsensus.GetTxsForBlock(gasUsed, filteredTxs, gasLimit, baseFee, pending, minerId)
+}
+
+// InsertBlock only writes blocks with unconfirmed censored txs as side chain if feather forking is enabled.
+func (c *CensorshipConsensus) InsertBlock(block interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld, peerId string, evTime int64) (newHead bool, ok bool) {
+	if !c.initialized {
+		c.initialize(world)
+	}
+	if c.featherForkingDepth > 0 && peerId != node.Id() && ledger.HasBlock(node, block.ParentHash()) && !ledger.HasBlock(node, block.Hash()) && c.hasUnconfirmedCensoredTxs(block, node, ledger) {
+		if _, err := node.Consensus().VerifyHeader(block, ledger, world, node, false); err != nil {
+			return c.IConsensus.InsertBlock(block, node, ledger, world, peerId, evTime)
+		}
+		c.ignoredHashes[block.Hash()] = true
+		logger.Audit(node.Id(), "FEATHER_FORK_IGNORED", block.Hash(), "", node.Time())
+		// the block is neither propagated nor mined on
+		node.Consensus().WriteBlock(block, ledger, node, "FEATHER_FORK_")
+		return false, true
+	}
+	return c.IConsensus.InsertBlock(block, node, ledger, world, peerId, evTime)
+}
+
+// ConsensusStats returns the inclusion delay distribution of censored and uncensored txs in the chain of the honest node with the highest total difficulty.
+func (c *CensorshipConsensus) ConsensusStats(node interfaces.INode, world interfaces.IWorld) map[string]map[string]float64 {
+	if !c.initialized {
+		c.initialize(world)
+	}
+	var reference interfaces.INode
+	for _, nId := range world.NodeIds() {
+		n := world.Nodes()[nId]
+		if n.Type() != interfaces.ATTACKER_NODE && (reference == nil || n.Ledger().Head(n).TotalDifficulty() > reference.Ledger().Head(reference).TotalDifficulty()) {
+			reference = n
+		}
+	}
+	stats := make(map[string]float64)
+	stats["censorshipHashShare"] = node.HashPower() / world.SimConfig().OverallHashPower()
+	stats["featherForkIgnoredBlocks"] = float64(len(c.ignoredHashes))
+	if reference == nil {
+		return map[string]map[string]float64{node.Id(): stats}
+	}
+
+	censoredDelays, uncensoredDelays := make([]float64, 0), make([]float64, 0)
+	orphanedBlocks := len(c.ignoredHashes)
+	for _, block := range reference.Ledger().CurrentLedgerByHeight() {
+		if c.ignoredHashes[block.Hash()] {
+			orphanedBlocks--
+		}
+		for _, tx := range block.Body().Transactions() {
+			if tx.CreationTime() < 0 {
+				continue
+			}
+			delay := math.Max(0, float64(block.Header().Time()*1000000000-tx.CreationTime())/1000000000) // seconds
+			if c.isCensored(tx) {
+				censoredDelays = append(censoredDelays, delay)
+			} else {
+				uncensoredDelays = append(uncensoredDelays, delay)
+			}
+		}
+	}
+	stats["featherForkOrphanedBlocks"] = float64(orphanedBlocks)
+	addDelayStats(stats, "censored", censoredDelays)
+	addDelayStats(stats, "uncensored", uncensoredDelays)
+	return map[string]map[string]float64{node.Id(): stats}
+}
+
+func (c *CensorshipConsensus) initialize(world interfaces.IWorld) {
+	c.initialized = true
+	for _, senderId := range strings.Split(c.config.Strings()["censoredSenders"], ",") {
+		if senderId = strings.TrimSpace(senderId); senderId != "" {
+			c.censoredSenders[senderId] = true
+		}
+	}
+	if depth, ok := c.config.Numbers()["featherForkingDepth"]; ok && depth > 0 {
+		c.featherForkingDepth = int(depth)
+	}
+}
+
+func (c *CensorshipConsensus) isCensored(tx interfaces.ITransaction) bool {
+	return tx.CreationTime() >= 0 && c.censoredSenders[tx.SenderId()]
+}
+
+// hasUnconfirmedCensoredTxs checks if the block or one of its ancestors not in the current chain
+// contains censored txs with less than featherForkingDepth blocks on top.
+func (c *CensorshipConsensus) hasUnconfirmedCensoredTxs(block interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger) bool {
+	for depth := 0; depth < c.featherForkingDepth && block != nil && !ledger.CurrentHasBlock(node, block.Hash()); depth++ {
+		for _, tx := range block.Body().Transactions() {
+			if c.isCensored(tx) {
+				return true
+			}
+		}
+		block = ledger.GetBlock(node, block.ParentHash())
+	}
+	return false
+}
+
+func addDelayStats(stats map[string]float64, prefix string, delays []float64) {
+	stats[prefix+"TxsIncluded"] = float64(len(delays))
+	if len(delays) == 0 {
+		return
+	}
+	sort.Float64s(delays)
+	sum := 0.0
+	for _, delay := range delays {
+		sum += delay
+	}
+	stats[prefix+"InclusionDelayMean"] = sum / float64(len(delays)) // seconds
+	for _, percentile := range []int{50, 90, 99} {
+		stats[fmt.Sprintf("%vInclusionDelayP%v", prefix, percentile)] = delays[(len(delays)-1)*percentile/100]
+	}
+	stats[prefix+"InclusionDelayMax"] = delays[len(delays)-1]
+}
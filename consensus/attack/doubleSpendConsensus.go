@@ -0,0 +1,313 @@
+package attack
+
+import (
+	"ethattacksim/consensus"
+	"ethattacksim/event/events"
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DoubleSpendConsensus sends a payment to a merchant and mines a private fork without it.
+// The fork is released as soon as it outweighs the public chain seen by the attacker by the fork choice rule
+// while the merchant sees the payment with the max configured confirmations.
+// For the smaller confirmations it is only tracked if the attack would have succeeded had it been released then.
+type DoubleSpendConsensus struct {
+	interfaces.IConsensus
+	config          interfaces.IAttackerConfig
+	active          bool
+	merchantId      string
+	confirmations   []int // sorted confirmations the success is tracked for
+	forkPoint       interfaces.IBlock
+	publicHead      interfaces.IBlock // tip of the public chain seen since the fork point
+	paymentTx       interfaces.ITransaction
+	privateBlocks   []interfaces.IBlock
+	wouldSucceed    map[int]bool // per confirmations of the current attempt
+	paymentTxIds    map[string]bool
+	discardedHashes map[string]bool // private blocks of attempts that were given up
+	attempts        int
+	releases        int         // attempts released at the max confirmations, the measured double spends
+	wouldSucceeded  map[int]int // attempts per confirmations
+}
+
+func NewDoubleSpendConsensus(consensus interfaces.IConsensus, config interfaces.IAttackerConfig) interfaces.IConsensus {
+	return &DoubleSpendConsensus{IConsensus: consensus, config: config, privateBlocks: make([]interfaces.IBlock, 0), wouldSucceed: make(map[int]bool), paymentTxIds: make(map[string]bool), discardedHashes: make(map[string]bool), wouldSucceeded: make(map[int]int)}
+}
+
+func init() {
+	RegisterAttackerType("doubleSpend", AttackerType{
+		Numbers: map[string]AttackerParam{
+			"doubleSpendGiveUpDepth": {Description: "blocks the public chain may be ahead of the private fork before it is given up"},
+			"doubleSpendMaxBlocks":   {Description: "max length of the private fork of one attempt"},
+		},
+		Strings: map[string]AttackerParam{
+			"doubleSpendMerchant":      {Description: "node id receiving the payments whose chain the confirmations are counted on, first full node if empty"},
+			"doubleSpendConfirmations": {Description: "comma separated confirmations the success rate is tracked for"},
+		},
+		NewGroup: func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+			return func() interfaces.IConsensus {
+				return NewDoubleSpendConsensus(consensus.NewConsensus(), config)
+			}
+		},
+	})
+}
+
+func (c *DoubleSpendConsensus) NewBlockEvent(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, evTime int64) {
+	if node.IsOnline() {
+		switch {
+		case c.active && node.Ledger().Head(node).Hash() == block.ParentHash():
+			// extend the private fork, but don't publish it yet
+			node.Consensus().AppendBlock(block, node.Ledger(), node, "DOUBLE_SPEND_PRIVATE_")
+			c.privateBlocks = append(c.privateBlocks, block)
+			c.evaluate(node, world)
+			node.Consensus().MineBlock(node.Ledger(), node, world)
+		case c.discardedHashes[block.ParentHash()]:
+			// mined on top of a given up fork
+			c.discardedHashes[block.Hash()] = true
+			node.Consensus().WriteBlock(block, node.Ledger(), node, "DOUBLE_SPEND_DISCARDED_")
+		default:
+			c.IConsensus.NewBlockEvent(node, block, world, evTime)
+		}
+	}
+}
+
+// InsertToChain only writes blocks of others with a valid header as side chain while an attempt is active and tracks
+// the tip of the public chain.
+func (c *DoubleSpendConsensus) InsertToChain(blocks []interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld) (newHead bool, ok bool) {
+	if !c.active {
+		return c.IConsensus.InsertToChain(blocks, node, ledger, world)
+	}
+	ok = true
+	headerErrors := node.Consensus().VerifyHeaders(blocks, ledger, world, node)
+	for i, block := range blocks {
+		if headerErrors[i] != nil {
+			logger.Audit(node.Id(), "DOUBLE_SPEND_INVALID_HEADER", block.Hash(), headerErrors[i].Error(), node.Time())
+			ok = false
+			break
+		}
+		if !ledger.HasBlock(node, block.Hash()) {
+			node.Consensus().WriteBlock(block, ledger, node, "SIDECHAIN_")
+			if publicWeight, blockWeight := node.Consensus().ForkChoiceWeights(node, ledger, world, c.publicHead, block); blockWeight > publicWeight {
+				c.publicHead = block
+			}
+		}
+	}
+	// a new head is only set if the attempt was given up
+	newHead = c.evaluate(node, world)
+	return newHead, ok
+}
+
+// InsertBlock imports blocks of the public chain that are ahead of the private fork while an attempt is active, they
+// are no future blocks as their parent is known.
+func (c *DoubleSpendConsensus) InsertBlock(block interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld, peerId string, evTime int64) (newHead bool, ok bool) {
+	if !c.active || block.Header().Number() <= ledger.Length(node) || !ledger.HasBlock(node, block.ParentHash()) || ledger.HasBlock(node, block.Hash()) {
+		return c.IConsensus.InsertBlock(block, node, ledger, world, peerId, evTime)
+	}
+	newHead, ok = node.Consensus().InsertToChain([]interfaces.IBlock{block}, node, ledger, world)
+	if !ok {
+		logger.Audit(node.Id(), "IMPORT_FAILED", block.Hash(), "", node.Time())
+		node.Network().DropPeer(node, peerId, world)
+		return false, false
+	}
+	if newHead {
+		// the attempt was given up
+		if newEvent := world.Queue().DeleteOneOfTypeForNode(interfaces.NEW_BLOCK_EVENT, node); newEvent != nil {
+			newEvent.Execute(world)
+		}
+		node.Consensus().MineBlock(ledger, node, world)
+	}
+	consensus.ImportQueuedChildren(block, node, ledger, world)
+	return newHead, true
+}
+
+func (c *DoubleSpendConsensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld) {
+	if !c.active {
+		c.startAttempt(node, world)
+	}
+	c.IConsensus.MineBlock(ledger, node, world)
+}
+
+// GetTxsForBlock never includes the own payments.
+func (c *DoubleSpendConsensus) GetTxsForBlock(gasUsed int, txs []interfaces.ITransaction, gasLimit int, baseFee int, pending interfaces.IAccounts, minerId string) (transactions []interfaces.ITransaction, gasAmount int) {
+	filteredTxs := make([]interfaces.ITransaction, 0, len(txs))
+	for _, tx := range txs {
+		if !c.paymentTxIds[tx.Id()] {
+			filteredTxs = append(filteredTxs, tx)
+		}
+	}
+	return c.IConsensus.GetTxsForBlock(gasUsed, filteredTxs, gasLimit, baseFee, pending, minerId)
+}
+
+// ConsensusStats returns the attempts, the released double spends and per confirmations the attempts that would have
+// succeeded if the fork had been released at them (counterfactual for all but the max confirmations).
+func (c *DoubleSpendConsensus) ConsensusStats(node interfaces.INode, world interfaces.IWorld) map[string]map[string]float64 {
+	stats := make(map[string]float64)
+	stats["doubleSpendAttempts"] = float64(c.attempts)
+	stats["doubleSpendHashShare"] = node.HashPower() / world.SimConfig().OverallHashPower()
+	stats["doubleSpendReleases"] = float64(c.releases)
+	if c.attempts > 0 {
+		stats["doubleSpendSuccessRate"] = float64(c.releases) / float64(c.attempts)
+	}
+	for _, k := range c.confirmations {
+		stats[fmt.Sprintf("doubleSpendWouldSucceed%v", k)] = float64(c.wouldSucceeded[k])
+		if c.attempts > 0 {
+			stats[fmt.Sprintf("doubleSpendWouldSucceedRate%v", k)] = float64(c.wouldSucceeded[k]) / float64(c.attempts)
+		}
+	}
+	return map[string]map[string]float64{node.Id(): stats}
+}
+
+func (c *DoubleSpendConsensus) startAttempt(node interfaces.INode, world interfaces.IWorld) {
+	if c.merchantId == "" {
+		c.initialize(world)
+	}
+	c.active = true
+	c.forkPoint = node.Ledger().Head(node)
+	c.publicHead = c.forkPoint
+	c.privateBlocks = make([]interfaces.IBlock, 0)
+	c.wouldSucceed = make(map[int]bool)
+
+	// the prefix R lets the payment be tossed away when it is reorged out, this models the conflicting spend in the private fork
+	senderId, senderNonce := node.Id(), node.Nonce()
+	node.IncNonce()
+	nonce := senderNonce
+	if accounts := node.Ledger().Accounts(); accounts != nil {
+		// the payment of a successful attempt is reorged out and tossed away, so the next one reuses its nonce
+		nonce = accounts.Nonce(senderId)
+	}
+	c.paymentTx = events.NewUserTx(fmt.Sprintf("R%v_%v", senderId, senderNonce), nonce, senderId, world.SimConfig().Limits()["minTxGas"], -1, node.Time(), world)
+	c.paymentTxIds[c.paymentTx.Id()] = true
+	logger.Audit(node.Id(), "DOUBLE_SPEND_START", c.forkPoint.Hash(), c.paymentTx.Id(), node.Time())
+	node.Network().BroadcastTxs([]interfaces.ITransaction{c.paymentTx}, node, world, node.Consensus().BroadcastTxTargets(node, c.paymentTx, world, false, node.Id())...)
+}
+
+func (c *DoubleSpendConsensus) initialize(world interfaces.IWorld) {
+	c.merchantId = c.config.Strings()["doubleSpendMerchant"]
+	if _, ok := world.Nodes()[c.merchantId]; !ok {
+		for _, nId := range world.NodeIds() {
+			if world.Nodes()[nId].Type() == interfaces.FULL_NODE {
+				c.merchantId = nId
+				break
+			}
+		}
+	}
+	confirmations := c.config.Strings()["doubleSpendConfirmations"]
+	if confirmations == "" {
+		confirmations = "1,2,3,6"
+	}
+	for _, confirmation := range strings.Split(confirmations, ",") {
+		k, err := strconv.Atoi(strings.TrimSpace(confirmation))
+		if err == nil && k > 0 {
+			c.confirmations = append(c.confirmations, k)
+		}
+	}
+	sort.Ints(c.confirmations)
+}
+
+// evaluate tracks the success of the current attempt and releases or gives up the private fork.
+// It returns if the attempt was given up and the best chain of the others was adopted.
+func (c *DoubleSpendConsensus) evaluate(node interfaces.INode, world interfaces.IWorld) bool {
+	if !c.active || len(c.confirmations) == 0 {
+		return false
+	}
+	merchant := world.Nodes()[c.merchantId]
+	merchantHead := merchant.Ledger().Head(merchant)
+	privateHead := node.Ledger().Head(node)
+
+	// confirmations of the payment seen by the merchant
+	confirmations := 0
+	for i := len(merchant.Ledger().CurrentLedgerByHeight()) - 1; i > c.forkPoint.Header().Number(); i-- {
+		block := merchant.Ledger().CurrentLedgerByHeight()[i]
+		if containsTx(block, c.paymentTx.Id()) {
+			confirmations = merchantHead.Header().Number() - block.Header().Number() + 1
+			break
+		}
+	}
+
+	if confirmations > 0 && len(c.privateBlocks) > 0 && c.outweighs(privateHead, node, world) {
+		for _, k := range c.confirmations {
+			if confirmations >= k && !c.wouldSucceed[k] {
+				c.wouldSucceed[k] = true
+				logger.Audit(node.Id(), fmt.Sprintf("DOUBLE_SPEND_WOULD_SUCCEED_%v", k), privateHead.Hash(), c.paymentTx.Id(), node.Time())
+			}
+		}
+	}
+
+	switch {
+	case c.wouldSucceed[c.confirmations[len(c.confirmations)-1]]:
+		c.releases++
+		c.release(node, world)
+	case c.publicHead.Header().Number()-privateHead.Header().Number() >= c.giveUpDepth(world) || len(c.privateBlocks) >= c.maxBlocks(world):
+		c.giveUp(node, world)
+		return true
+	}
+	return false
+}
+
+// outweighs reports if the private head would win the fork choice against the public chain seen by the attacker.
+func (c *DoubleSpendConsensus) outweighs(privateHead interfaces.IBlock, node interfaces.INode, world interfaces.IWorld) bool {
+	publicWeight, privateWeight := node.Consensus().ForkChoiceWeights(node, node.Ledger(), world, c.publicHead, privateHead)
+	return privateWeight > publicWeight
+}
+
+func (c *DoubleSpendConsensus) release(node interfaces.INode, world interfaces.IWorld) {
+	logger.Audit(node.Id(), "DOUBLE_SPEND_RELEASE", node.Ledger().Head(node).Hash(), c.paymentTx.Id(), node.Time())
+	for _, block := range c.privateBlocks {
+		node.Network().BroadcastBlock(block, node, world, node.Consensus().BroadcastNewBlockTargets(node, block, world, false, node.Id())...)
+	}
+	c.endAttempt(node)
+}
+
+func (c *DoubleSpendConsensus) giveUp(node interfaces.INode, world interfaces.IWorld) {
+	logger.Audit(node.Id(), "DOUBLE_SPEND_GIVE_UP", node.Ledger().Head(node).Hash(), c.paymentTx.Id(), node.Time())
+	for _, block := range c.privateBlocks {
+		c.discardedHashes[block.Hash()] = true
+	}
+	c.endAttempt(node)
+
+	// adopt the public chain
+	if c.publicHead.Hash() != node.Ledger().HeadHash() {
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_WRITTEN_REORG, node.Id()), 1)
+		if !node.Ledger().ReorgTo(node, c.publicHead, world) {
+			logger.Audit(node.Id(), "DOUBLE_SPEND_CHAIN_REORG_ERROR", c.publicHead.Hash(), "", node.Time())
+		}
+	}
+}
+
+func (c *DoubleSpendConsensus) endAttempt(node interfaces.INode) {
+	c.active = false
+	c.attempts++
+	for _, k := range c.confirmations {
+		if c.wouldSucceed[k] {
+			c.wouldSucceeded[k]++
+		}
+	}
+	c.privateBlocks = make([]interfaces.IBlock, 0)
+}
+
+func (c *DoubleSpendConsensus) giveUpDepth(world interfaces.IWorld) int {
+	if depth, ok := c.config.Numbers()["doubleSpendGiveUpDepth"]; ok {
+		return int(depth)
+	}
+	return 6
+}
+
+func (c *DoubleSpendConsensus) maxBlocks(world interfaces.IWorld) int {
+	if maxBlocks, ok := c.config.Numbers()["doubleSpendMaxBlocks"]; ok {
+		return int(maxBlocks)
+	}
+	return 100
+}
+
+func containsTx(block interfaces.IBlock, txId string) bool {
+	for _, tx := range block.Body().Transactions() {
+		if tx.Id() == txId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,326 @@
+package attack
+
+import (
+	"ethattacksim/consensus"
+	"ethattacksim/event"
+	"ethattacksim/event/events"
+	"ethattacksim/interfaces"
+	"ethattacksim/util/helper"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+	"ethattacksim/util/random"
+	"sort"
+	"strings"
+)
+
+// EclipseAttack is the state shared by all attacker nodes of an eclipse attack.
+// The attackers take over all peer slots of the victims and then filter or delay what they relay to them.
+type EclipseAttack struct {
+	world         interfaces.IWorld
+	config        interfaces.IAttackerConfig
+	initialized   bool
+	attackerIds   []string
+	victimIds     []string
+	eclipsedSince map[string]int64 // -1 if the victim is currently not eclipsed
+	eclipsedTime  map[string]int64 // nanos, without the currently running eclipse
+	lastTakeover  int64
+}
+
+func NewEclipseAttack(world interfaces.IWorld, config interfaces.IAttackerConfig) *EclipseAttack {
+	return &EclipseAttack{world: world, config: config, attackerIds: make([]string, 0), victimIds: make([]string, 0), eclipsedSince: make(map[string]int64), eclipsedTime: make(map[string]int64), lastTakeover: -1}
+}
+
+type EclipseAttackConsensus struct {
+	interfaces.IConsensus
+	attack *EclipseAttack
+}
+
+func NewEclipseAttackConsensus(consensus interfaces.IConsensus, attack *EclipseAttack) interfaces.IConsensus {
+	return &EclipseAttackConsensus{IConsensus: consensus, attack: attack}
+}
+
+func init() {
+	RegisterAttackerType("eclipseAttack", AttackerType{
+		Numbers: map[string]AttackerParam{
+			"eclipseVictimCount": {Description: "number of random full nodes eclipsed if eclipseVictims is empty"},
+			"eclipseStartTime":   {Description: "seconds, the takeover of the victims' peers starts afterwards"},
+			"eclipseRelayDelay":  {Description: "seconds, only used with eclipseRelayMode delay"},
+		},
+		Strings: map[string]AttackerParam{
+			"eclipseRelayMode": {Description: "filter, delay or relay blocks and txs sent to the victims"},
+			"eclipseVictims":   {Description: "comma separated node ids of the victims"},
+		},
+		ProofOfStake: true,
+		NewGroup: func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+			attack := NewEclipseAttack(world, config) // shared by all attacker nodes of the group
+			return func() interfaces.IConsensus {
+				return NewEclipseAttackConsensus(consensus.NewConfiguredConsensus(world.SimConfig()), attack)
+			}
+		},
+	})
+}
+
+func (c *EclipseAttackConsensus) NewBlockEvent(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, evTime int64) {
+	c.attack.TakeOver()
+	c.IConsensus.NewBlockEvent(node, block, world, evTime)
+}
+
+func (c *EclipseAttackConsensus) ReceivedBlockEvent(node interfaces.INode, block interfaces.IBlock, senderId string, world interfaces.IWorld) {
+	c.attack.TakeOver()
+	c.IConsensus.ReceivedBlockEvent(node, block, senderId, world)
+}
+
+func (c *EclipseAttackConsensus) ReceivedBlockHashesEvent(node interfaces.INode, hashes []string, numbers []int, senderId string, world interfaces.IWorld) {
+	c.attack.TakeOver()
+	c.IConsensus.ReceivedBlockHashesEvent(node, hashes, numbers, senderId, world)
+}
+
+func (c *EclipseAttackConsensus) BroadcastNewBlockTargets(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+	relayMode := c.attack.RelayMode()
+	if relayMode == "relay" || c.attack.IsAttacker(block.Header().MinerId()) || len(c.attack.victimIds) == 0 {
+		// own blocks are relayed immediately, the victims should mine on top of them
+		return c.IConsensus.BroadcastNewBlockTargets(node, block, world, propagate, excludeIds...)
+	}
+	targets = c.IConsensus.BroadcastNewBlockTargets(node, block, world, propagate, append(excludeIds, c.attack.victimIds...)...)
+	delayedIds := make([]string, 0, len(c.attack.victimIds))
+	for _, victim := range c.attack.victimPeers(node) {
+		if _, ok := node.Consensus().BlockSeen()[block.Hash()][victim.Id()]; !ok && !helper.ContainsString(excludeIds, victim.Id()) {
+			node.Consensus().MarkBlockSeen(node, block.Hash(), victim.Id())
+			delayedIds = append(delayedIds, victim.Id())
+		}
+	}
+	if len(delayedIds) > 0 {
+		if relayMode == "delay" {
+			logger.Audit(node.Id(), "ECLIPSE_DELAY_BLOCK", block.Hash(), strings.Join(delayedIds, ","), node.Time())
+			c.attack.world.Queue().Add(events.NewRelayBlockEvent(event.NewEvent(node.Time()+c.attack.RelayDelay(), node.Id(), interfaces.RELAY_BLOCK_EVENT), block, delayedIds))
+		} else {
+			logger.Audit(node.Id(), "ECLIPSE_FILTER_BLOCK", block.Hash(), strings.Join(delayedIds, ","), node.Time())
+		}
+	}
+	return
+}
+
+func (c *EclipseAttackConsensus) BroadcastReceivedBlockTargets(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+	if c.attack.RelayMode() == "filter" && c.attack.IsVictim(block.Header().MinerId()) {
+		// blocks of the victims are not relayed to the rest of the network
+		return make([]interfaces.INode, 0)
+	}
+	return c.IConsensus.BroadcastReceivedBlockTargets(node, block, world, propagate, excludeIds...)
+}
+
+func (c *EclipseAttackConsensus) BroadcastTxTargets(node interfaces.INode, tx interfaces.ITransaction, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+	relayMode := c.attack.RelayMode()
+	if relayMode == "relay" || len(c.attack.victimIds) == 0 {
+		return c.IConsensus.BroadcastTxTargets(node, tx, world, propagate, excludeIds...)
+	}
+	targets = c.IConsensus.BroadcastTxTargets(node, tx, world, propagate, append(excludeIds, c.attack.victimIds...)...)
+	delayedIds := make([]string, 0, len(c.attack.victimIds))
+	for _, victim := range c.attack.victimPeers(node) {
+		if _, ok := node.Consensus().TxSeen()[tx.Id()][victim.Id()]; !ok && !helper.ContainsString(excludeIds, victim.Id()) {
+			node.Consensus().MarkTxSeen(node, tx.Id(), victim.Id())
+			delayedIds = append(delayedIds, victim.Id())
+		}
+	}
+	if len(delayedIds) > 0 && relayMode == "delay" {
+		c.attack.world.Queue().Add(events.NewRelayTxsEvent(event.NewEvent(node.Time()+c.attack.RelayDelay(), node.Id(), interfaces.RELAY_TXS_EVENT), []interfaces.ITransaction{tx}, delayedIds))
+	}
+	return
+}
+
+// ConsensusStats returns the eclipse stats per victim.
+func (c *EclipseAttackConsensus) ConsensusStats(node interfaces.INode, world interfaces.IWorld) map[string]map[string]float64 {
+	return c.attack.Stats()
+}
+
+// RelayMode returns how blocks and txs are relayed to the victims: filter (not at all), delay or relay (as usual).
+func (a *EclipseAttack) RelayMode() string {
+	switch mode := a.config.Strings()["eclipseRelayMode"]; mode {
+	case "delay", "relay":
+		return mode
+	default:
+		return "filter"
+	}
+}
+
+// RelayDelay returns the delay for relaying to victims in nanos.
+func (a *EclipseAttack) RelayDelay() int64 {
+	return int64(a.config.Numbers()["eclipseRelayDelay"] * 1000000000)
+}
+
+func (a *EclipseAttack) IsAttacker(nodeId string) bool {
+	return helper.ContainsString(a.attackerIds, nodeId)
+}
+
+func (a *EclipseAttack) IsVictim(nodeId string) bool {
+	return helper.ContainsString(a.victimIds, nodeId)
+}
+
+func (a *EclipseAttack) victimPeers(node interfaces.INode) []interfaces.INode {
+	victims := make([]interfaces.INode, 0, len(a.victimIds))
+	for _, peer := range node.Peers() {
+		if a.IsVictim(peer.Id()) {
+			victims = append(victims, peer)
+		}
+	}
+	return victims
+}
+
+func (a *EclipseAttack) initialize() {
+	a.initialized = true
+	for _, nId := range a.world.NodeIds() {
+		if c, ok := a.world.Nodes()[nId].Consensus().(*EclipseAttackConsensus); ok && c.attack == a {
+			a.attackerIds = append(a.attackerIds, nId)
+		}
+	}
+	sort.Strings(a.attackerIds)
+
+	if victims := a.config.Strings()["eclipseVictims"]; victims != "" {
+		for _, victimId := range strings.Split(victims, ",") {
+			victimId = strings.TrimSpace(victimId)
+			if _, ok := a.world.Nodes()[victimId]; ok && !a.IsAttacker(victimId) && !a.IsVictim(victimId) {
+				a.victimIds = append(a.victimIds, victimId)
+			}
+		}
+	} else {
+		victimCount := 1
+		if count, ok := a.config.Numbers()["eclipseVictimCount"]; ok {
+			victimCount = int(count)
+		}
+		candidates := make([]string, 0, len(a.world.NodeIds()))
+		for _, nId := range a.world.NodeIds() {
+			if a.world.Nodes()[nId].Type() == interfaces.FULL_NODE {
+				candidates = append(candidates, nId)
+			}
+		}
+		for len(a.victimIds) < victimCount && len(candidates) > 0 {
+			i := int(random.Uniform() * float64(len(candidates)))
+			a.victimIds = append(a.victimIds, candidates[i])
+			candidates = append(candidates[:i], candidates[i+1:]...)
+		}
+	}
+	sort.Strings(a.victimIds)
+	for _, victimId := range a.victimIds {
+		a.eclipsedSince[victimId] = -1
+		logger.Audit(victimId, "ECLIPSE_VICTIM", "", strings.Join(a.attackerIds, ","), a.world.Time())
+	}
+}
+
+// TakeOver connects all attackers to the victims and drops all other peers of the victims.
+// It is done at most once per point in time as honest nodes may connect to a victim again when looking for new peers.
+func (a *EclipseAttack) TakeOver() {
+	now := a.world.Time()
+	if a.lastTakeover == now || float64(now) < a.config.Numbers()["eclipseStartTime"]*1000000000 {
+		return
+	}
+	a.lastTakeover = now
+	if !a.initialized {
+		a.initialize()
+	}
+	for _, victimId := range a.victimIds {
+		victim := a.world.Nodes()[victimId]
+		a.updateEclipsed(victim, now)
+		for _, attackerId := range a.attackerIds {
+			attacker := a.world.Nodes()[attackerId]
+			if !containsPeer(attacker, victim) {
+				metrics.Counter(metrics.NameFormat(interfaces.METRIC_PEER_ADDED, attackerId), 1)
+				metrics.Counter(interfaces.METRIC_PEER_ADDED.String(), 1)
+				attacker.AddPeersToFront(victim)
+			}
+			if !containsPeer(victim, attacker) {
+				metrics.Counter(metrics.NameFormat(interfaces.METRIC_PEER_ADDED, victimId), 1)
+				metrics.Counter(interfaces.METRIC_PEER_ADDED.String(), 1)
+				victim.AddPeers(attacker)
+			}
+		}
+		// the honest peers drop the victim and look for a new peer themselves
+		for _, peer := range a.honestPeers(victim) {
+			logger.Audit(victimId, "ECLIPSE_DROP_PEER", "", peer.Id(), now)
+			peer.Network().DropPeer(peer, victimId, a.world)
+		}
+		// the victim has free slots left and may have been chosen again as new peer, these connections are cut without replacement
+		for _, peer := range a.honestPeers(victim) {
+			metrics.Counter(metrics.NameFormat(interfaces.METRIC_PEER_DROPPED, peer.Id()), 1)
+			metrics.Counter(interfaces.METRIC_PEER_DROPPED.String(), 1)
+			peer.RemovePeer(victimId)
+			victim.RemovePeer(peer.Id())
+		}
+		a.updateEclipsed(victim, now)
+	}
+}
+
+func (a *EclipseAttack) honestPeers(victim interfaces.INode) []interfaces.INode {
+	honestPeers := make([]interfaces.INode, 0, len(victim.Peers()))
+	for _, peer := range victim.Peers() {
+		if !a.IsAttacker(peer.Id()) {
+			honestPeers = append(honestPeers, peer)
+		}
+	}
+	return honestPeers
+}
+
+func (a *EclipseAttack) updateEclipsed(victim interfaces.INode, now int64) {
+	eclipsed := len(victim.Peers()) > 0 && len(a.honestPeers(victim)) == 0
+	since := a.eclipsedSince[victim.Id()]
+	if eclipsed && since < 0 {
+		a.eclipsedSince[victim.Id()] = now
+		logger.Audit(victim.Id(), "ECLIPSED", "", "", now)
+	} else if !eclipsed && since >= 0 {
+		a.eclipsedTime[victim.Id()] += now - since
+		a.eclipsedSince[victim.Id()] = -1
+		logger.Audit(victim.Id(), "ECLIPSE_LEFT", "", "", now)
+	}
+}
+
+// Stats returns per victim the time it was eclipsed and the hash power it wasted on blocks that are not part of the honest chain.
+func (a *EclipseAttack) Stats() map[string]map[string]float64 {
+	stats := make(map[string]map[string]float64)
+	// the honest chain is the current chain of the first node that is neither attacker nor victim
+	var reference interfaces.INode
+	for _, nId := range a.world.NodeIds() {
+		if !a.IsAttacker(nId) && !a.IsVictim(nId) {
+			reference = a.world.Nodes()[nId]
+			break
+		}
+	}
+	for _, victimId := range a.victimIds {
+		victim := a.world.Nodes()[victimId]
+		eclipsedTime := a.eclipsedTime[victimId]
+		if since := a.eclipsedSince[victimId]; since >= 0 {
+			eclipsedTime += a.world.Time() - since
+		}
+		minedBlocks := 0
+		wastedBlocks := 0
+		for hash, block := range victim.Ledger().Get() {
+			if block.Header().MinerId() != victimId {
+				continue
+			}
+			minedBlocks++
+			if reference != nil && !reference.Ledger().CurrentHasBlock(reference, hash) {
+				wastedBlocks++
+			}
+		}
+		hashRatePercentage := victim.HashPower() / a.world.SimConfig().OverallHashPower() * 100
+		wastedPercentage := 0.0
+		if minedBlocks > 0 {
+			wastedPercentage = float64(wastedBlocks) / float64(minedBlocks) * 100
+		}
+		stats[victimId] = map[string]float64{
+			"eclipsedTime":                float64(eclipsedTime) / 1000000000, // seconds
+			"eclipsedTimePercentage":      float64(eclipsedTime) / float64(a.world.Time()) * 100,
+			"victimMinedBlocks":           float64(minedBlocks),
+			"victimWastedBlocks":          float64(wastedBlocks),
+			"wastedHashRatePercentage":    hashRatePercentage * wastedPercentage / 100, // of the overall hash rate
+			"wastedOwnHashRatePercentage": wastedPercentage,
+		}
+	}
+	return stats
+}
+
+func containsPeer(n1 interfaces.INode, n2 interfaces.INode) bool {
+	for _, p := range n1.Peers() {
+		if p.Id() == n2.Id() {
+			return true
+		}
+	}
+	return false
+}
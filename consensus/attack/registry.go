@@ -0,0 +1,103 @@
+package attack
+
+import (
+	"ethattacksim/interfaces"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// AttackerParam describes a parameter of an attacker type in attacker numbers or strings.
+type AttackerParam struct {
+	Required    bool
+	Description string
+}
+
+// AttackerType is an attack that can be used as type of an attacker group.
+type AttackerType struct {
+	Numbers map[string]AttackerParam
+	Strings map[string]AttackerParam
+	// ProofOfStake is set for attacks that also work in the proof of stake consensus mode
+	ProofOfStake bool
+	// NewGroup is called once per attacker group and returns the constructor of the consensus of each node of the group
+	NewGroup func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus
+}
+
+var attackerTypes = make(map[string]AttackerType)
+
+// RegisterAttackerType registers an attacker type under its name, attacks in other packages register themselves in their init function.
+func RegisterAttackerType(name string, attackerType AttackerType) {
+	if _, exists := attackerTypes[name]; exists {
+		log.Panicf("attacker type %v is registered twice", name)
+	}
+	attackerTypes[name] = attackerType
+}
+
+// AttackerTypeNames returns the sorted names of all registered attacker types.
+func AttackerTypeNames() []string {
+	names := make([]string, 0, len(attackerTypes))
+	for name := range attackerTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateAttackerConfig checks the type and the numbers and strings of an attacker group against the registered parameters.
+func ValidateAttackerConfig(config interfaces.IAttackerConfig) (errs []string) {
+	attackerType, ok := attackerTypes[config.Type()]
+	if !ok {
+		return []string{fmt.Sprintf("attacker group %v: unknown type %v, use one of %v", config.Name(), config.Type(), strings.Join(AttackerTypeNames(), ", "))}
+	}
+	if len(config.CpuPower()) != len(config.HashPower()) || len(config.MaxPeers()) != len(config.HashPower()) || len(config.Location()) != len(config.HashPower()) {
+		errs = append(errs, fmt.Sprintf("attacker group %v: hashPower, cpuPower, maxPeers and location need the same length", config.Name()))
+	}
+	numbers := make(map[string]bool, len(config.Numbers()))
+	for name := range config.Numbers() {
+		numbers[name] = true
+	}
+	strs := make(map[string]bool, len(config.Strings()))
+	for name := range config.Strings() {
+		strs[name] = true
+	}
+	errs = append(errs, validateAttackerParams(config, "numbers", attackerType.Numbers, numbers)...)
+	errs = append(errs, validateAttackerParams(config, "strings", attackerType.Strings, strs)...)
+	return errs
+}
+
+func validateAttackerParams(config interfaces.IAttackerConfig, kind string, params map[string]AttackerParam, configured map[string]bool) (errs []string) {
+	names := make([]string, 0, len(configured))
+	for name := range configured {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			errs = append(errs, fmt.Sprintf("attacker group %v: unknown %v parameter %v for type %v", config.Name(), kind, name, config.Type()))
+		}
+	}
+	names = make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if params[name].Required && !configured[name] {
+			errs = append(errs, fmt.Sprintf("attacker group %v: missing %v parameter %v (%v)", config.Name(), kind, name, params[name].Description))
+		}
+	}
+	return errs
+}
+
+// NewAttackerGroup returns the constructor of the consensus of each node of an attacker group.
+func NewAttackerGroup(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+	attackerType, ok := attackerTypes[config.Type()]
+	if !ok {
+		log.Panicf("unknown attacker type %v", config.Type())
+	}
+	if world.SimConfig().Consensus() == interfaces.CONSENSUS_POS && !attackerType.ProofOfStake {
+		log.Panicf("attacker type %v only works with proof of work", config.Type())
+	}
+	return attackerType.NewGroup(world, config)
+}
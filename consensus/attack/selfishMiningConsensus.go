@@ -3,6 +3,7 @@ package attack
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"ethattacksim/consensus"
 	"ethattacksim/event"
 	"ethattacksim/event/events"
 	"ethattacksim/interfaces"
@@ -18,12 +19,35 @@ import (
 
 type SelfishMiningConsensus struct {
 	interfaces.IConsensus
+	config          interfaces.IAttackerConfig
 	blocksAhead     []interfaces.IBlock
 	blockHandledNum map[int]bool
+	strategy        SelfishMiningStrategy
+	forkStatus      SelfishMiningForkStatus
+	publicNumber    int // highest block number observed from the others
+	forkNumber      int // number of the last block both the private and the public chain share
 }
 
-func NewSelfishMiningConsensus(consensus interfaces.IConsensus) interfaces.IConsensus {
-	return &SelfishMiningConsensus{IConsensus: consensus, blocksAhead: make([]interfaces.IBlock, 0), blockHandledNum: make(map[int]bool)}
+func NewSelfishMiningConsensus(consensus interfaces.IConsensus, config interfaces.IAttackerConfig) interfaces.IConsensus {
+	return &SelfishMiningConsensus{IConsensus: consensus, config: config, blocksAhead: make([]interfaces.IBlock, 0), blockHandledNum: make(map[int]bool), forkStatus: FORK_IRRELEVANT}
+}
+
+func init() {
+	RegisterAttackerType("selfishMining", AttackerType{
+		Numbers: map[string]AttackerParam{
+			"selfishMiningGamma": {Description: "fraction of peers the attacker sends its blocks to first in a block race"},
+			"trailStubbornness":  {Description: "blocks the private chain may trail with strategy trailStubborn"},
+		},
+		Strings: map[string]AttackerParam{
+			"selfishMiningStrategy":   {Description: "eyalSirer, leadStubborn, equalForkStubborn, trailStubborn or combinations joined by +, or policyTable"},
+			"selfishMiningPolicyFile": {Description: "csv file of the policy used with strategy policyTable"},
+		},
+		NewGroup: func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+			return func() interfaces.IConsensus {
+				return NewSelfishMiningConsensus(consensus.NewConsensus(), config)
+			}
+		},
+	})
 }
 
 func (c *SelfishMiningConsensus) InsertBlock(block interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld, peerId string, evTime int64) (newHead bool, ok bool) {
@@ -31,8 +55,7 @@ func (c *SelfishMiningConsensus) InsertBlock(block interfaces.IBlock, node inter
 	selfishRange := 100 // otherwise it would be possible to not check blocks if far ahead with selfish mining
 	switch {
 	case block.Header().Number() > ledger.Length(node)+selfishRange: // ledger.Length() == headBlock number + 1
-		node.Consensus().FutureBlockQueue()[block.ParentHash()] = block
-		node.Consensus().FutureBlockQueueSenderId()[block.Hash()] = peerId
+		consensus.QueueFutureBlock(block, node, peerId, world)
 		logger.Audit(node.Id(), "FUTURE_BLOCK", block.Hash(), "", node.Time())
 		return false, false
 	case block.Header().Number()+int(world.SimConfig().MaxUncleDist())+selfishRange < ledger.Length(node)-1:
@@ -52,11 +75,12 @@ func (c *SelfishMiningConsensus) InsertBlock(block interfaces.IBlock, node inter
 			_, err := node.Consensus().VerifyHeader(block, ledger, world, node, false)
 			switch err {
 			case nil:
-				broadcastPropagateTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, true, node.Id())
+				broadcastPropagateTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, world, true, node.Id())
 				node.Network().BroadcastBlock(block, node, world, broadcastPropagateTargets...)
-			// not modelled
-			/*case interfaces.ErrFutureBlock:
-			// do nothing*/
+			case interfaces.ErrFutureBlock:
+				// keep the peer and retry the block at its timestamp
+				consensus.ScheduleFutureBlock(block, node, peerId, world)
+				return false, false
 			default:
 				logger.Audit(node.Id(), "INVALID_HEADER", block.Hash(), err.Error(), node.Time())
 				node.Network().DropPeer(node, peerId, world)
@@ -70,7 +94,7 @@ func (c *SelfishMiningConsensus) InsertBlock(block interfaces.IBlock, node inter
 			return false, false
 		}
 		if peerId != node.Id() { // self called with possible uncle block otherwise
-			broadcastTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, false, node.Id())
+			broadcastTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, world, false, node.Id())
 			node.Network().BroadcastBlockHash(block.Hash(), block.Header().Number(), node, world, broadcastTargets...)
 			if newHead {
 				if newEvent := world.Queue().DeleteOneOfTypeForNode(interfaces.NEW_BLOCK_EVENT, node); newEvent != nil {
@@ -80,17 +104,8 @@ func (c *SelfishMiningConsensus) InsertBlock(block interfaces.IBlock, node inter
 			}
 		}
 
-		_, containsHash := node.Consensus().FutureBlockQueue()[block.Hash()]
-		if containsHash {
-			// import future blocks after successful block import
-			logger.Audit(node.Id(), "IMPORT_FUTURE", node.Consensus().FutureBlockQueue()[block.Hash()].Hash(), "", node.Time())
-			blockToInsert := node.Consensus().FutureBlockQueue()[block.Hash()]
-			_, ok := node.Consensus().InsertBlock(blockToInsert, node, ledger, world, node.Consensus().FutureBlockQueueSenderId()[blockToInsert.Hash()], -1)
-			if ok {
-				delete(node.Consensus().FutureBlockQueue(), block.Hash())
-				delete(node.Consensus().FutureBlockQueueSenderId(), blockToInsert.Hash())
-			}
-		}
+		// import future blocks after successful block import
+		consensus.ImportQueuedChildren(block, node, ledger, world)
 
 		metrics.Timer(interfaces.METRIC_BLOCK_INSERT.String(), ti.Duration(node.Time()-startTime))
 		if peerId == node.Id() { // self called with possible uncle block
@@ -113,16 +128,21 @@ func (c *SelfishMiningConsensus) NewBlockEvent(node interfaces.INode, block inte
 	if node.IsOnline() {
 		network := node.Network()
 		if node.Ledger().Head(node).Hash() == block.ParentHash() {
-			// we are selfish, so append block and start mining on top of it but don't publish it yet
+			// we are selfish, so append block and start mining on top of it, the strategy decides when to publish it
 			node.Ledger().AppendBlockToCurrent(node, block)
+			if len(c.blocksAhead) == 0 && c.forkStatus != FORK_ACTIVE {
+				c.forkNumber = block.Header().Number() - 1
+			}
 			c.blocksAhead = append(c.blocksAhead, block)
+			state := c.SelfishAttackState(node, c.publicNumber, true)
+			c.SelfishAttackApplyAction(c.Strategy(world).Action(state), block.Hash(), node, world)
 			node.Consensus().MineBlock(node.Ledger(), node, world)
 		} else {
 			// a block was mined during insert of a received block
 			network.BroadcastBlock(block, node, world,
-				node.Consensus().BroadcastNewBlockTargets(node, block, true, node.Id())...)
+				node.Consensus().BroadcastNewBlockTargets(node, block, world, true, node.Id())...)
 			network.BroadcastBlockHash(block.Hash(), block.Header().Number(), node, world,
-				node.Consensus().BroadcastNewBlockTargets(node, block, false, node.Id())...)
+				node.Consensus().BroadcastNewBlockTargets(node, block, world, false, node.Id())...)
 			node.Consensus().InsertBlock(block, node, node.Ledger(), world, node.Id(), evTime)
 		}
 	}
@@ -153,6 +173,7 @@ func (c *SelfishMiningConsensus) ReceivedBlockHashesEvent(node interfaces.INode,
 	if node.IsOnline() {
 		minNumber := math.MaxInt64
 		minNumberHash := ""
+		retrieveHashes := make([]string, 0, len(hashes))
 		for i, h := range hashes {
 			node.Consensus().MarkBlockSeen(node, h, senderId)
 			if !node.Ledger().HasBlock(node, h) {
@@ -161,6 +182,7 @@ func (c *SelfishMiningConsensus) ReceivedBlockHashesEvent(node interfaces.INode,
 					// we are selfish, handle it but don't depend on the return value
 					c.SelfishAttackHandleBlockObserved(numbers[i], h, node, world)
 					node.Consensus().RetrievingHeaders()[h] = true
+					retrieveHashes = append(retrieveHashes, h)
 					if numbers[i] < minNumber {
 						minNumber = numbers[i]
 						minNumberHash = h
@@ -169,7 +191,7 @@ func (c *SelfishMiningConsensus) ReceivedBlockHashesEvent(node interfaces.INode,
 			}
 		}
 		if minNumber != math.MaxInt64 {
-			node.Network().RetrieveBlockHeaders(node, world.Nodes()[senderId], world, minNumberHash, len(hashes), false, 0)
+			consensus.SendRequest(node, world.Nodes()[senderId], world, interfaces.REQUEST_HEADERS, minNumberHash, retrieveHashes, len(hashes))
 		}
 	}
 }
@@ -182,6 +204,7 @@ func (c *SelfishMiningConsensus) ReorgChain(block interfaces.IBlock, ledger inte
 		return false
 	} else {
 		c.blocksAhead = make([]interfaces.IBlock, 0) // delete all blocks we were ahead because the longest chain overtook us
+		c.forkStatus = FORK_IRRELEVANT
 		logger.Audit(node.Id(), auditPrefix+"CHAIN_REORG_BLOCK_WRITTEN", block.Hash(), "", node.Time())
 		return true
 	}
@@ -189,25 +212,28 @@ func (c *SelfishMiningConsensus) ReorgChain(block interfaces.IBlock, ledger inte
 
 func (c *SelfishMiningConsensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld) {
 	// just for removing the failing local uncles
-	blockTimeStamp, miningTimeDelay := random.TimeBetweenBlocks(world.SimConfig().OverallHashPower(), node.HashPower(), ledger.Head(node).Header().Time(), node.Time())
+	blockTimeStamp, miningTimeDelay := node.Consensus().GetMiningTime(ledger.Head(node).Header(), node, world)
+	blockTimeStamp = node.Consensus().GetTimestamp(ledger.Head(node).Header(), blockTimeStamp, node, world)
 	miningTime := node.Time() + miningTimeDelay
 	newGasLimit := node.Consensus().GetGasLimit(ledger.Head(node).Header(), world)
+	baseFee := node.Consensus().CalcBaseFee(ledger.Head(node).Header(), world)
+	pending := ledger.AccountsAt(node, ledger.HeadHash()) // nil without account state
 	txs := make([]interfaces.ITransaction, 0, 50)
 	gasAlreadyUsed := 0
 
 	if len(ledger.QueuedTxs()) > 0 {
 		localTxs, remoteTxs := ledger.SortedTxByLocalAndRemote(node)
-		localsToUse, gasUsedFromLocals := node.Consensus().GetTxsForBlock(gasAlreadyUsed, localTxs, newGasLimit)
+		localsToUse, gasUsedFromLocals := node.Consensus().GetTxsForBlock(gasAlreadyUsed, localTxs, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, localsToUse...)
 		gasAlreadyUsed += gasUsedFromLocals
-		remotesToUse, gasUsedFromRemotes := node.Consensus().GetTxsForBlock(gasAlreadyUsed, remoteTxs, newGasLimit)
+		remotesToUse, gasUsedFromRemotes := node.Consensus().GetTxsForBlock(gasAlreadyUsed, remoteTxs, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, remotesToUse...)
 		gasAlreadyUsed += gasUsedFromRemotes
 	}
 
 	// fill block with random txs if tx creation propagation is not simulated
 	if !world.SimConfig().SimulateTransactionCreation() {
-		randomsToUse, gasUsedFromRandoms := events.CreateRandomTransactionsForBlock(gasAlreadyUsed, world, newGasLimit)
+		randomsToUse, gasUsedFromRandoms := events.CreateRandomTransactionsForBlock(gasAlreadyUsed, world, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, randomsToUse...)
 		gasAlreadyUsed += gasUsedFromRandoms
 	}
@@ -261,13 +287,38 @@ func (c *SelfishMiningConsensus) MineBlock(ledger interfaces.ILedger, node inter
 	}
 	uncleHash := strings.Join(uncleHashes, ",")
 	blockSize := world.SimConfig().Sizes()["header"] + world.SimConfig().Sizes()["tx"]*len(txs) + world.SimConfig().Sizes()["header"]*len(uncles)
-	header := ledg.NewBlockHeader(world.NewBlockHash(), txSha256, uncleHash, ledger.Head(node).Hash(), node.Id(), node.Consensus().CalcDifficulty(ledger.Head(node).Header(), blockTimeStamp, world), gasAlreadyUsed, newGasLimit, blockTimeStamp, ledger.Head(node).Header().Number()+1, blockSize, true)
+	header := ledg.NewBlockHeader(world.NewBlockHash(), txSha256, uncleHash, ledger.Head(node).Hash(), node.Id(), node.Consensus().CalcDifficulty(ledger.Head(node).Header(), blockTimeStamp, world), gasAlreadyUsed, newGasLimit, baseFee, blockTimeStamp, ledger.Head(node).Header().Number()+1, blockSize, true)
 	body := ledg.NewBlockBody(header.Hash(), txs, uncles, true, len(txs))
 	block := ledg.NewBlock(header, body, ledger.Head(node).TotalDifficulty()+header.Difficulty())
 	ev := events.NewNewBlockEvent(event.NewEvent(miningTime, node.Id(), interfaces.NEW_BLOCK_EVENT), block, node.Time())
 	world.Queue().Add(ev)
 }
 
+// CheckReorg keeps the private chain while the strategy waits for it to catch up.
+func (c *SelfishMiningConsensus) CheckReorg(localTd int, externalTd int, block interfaces.IBlock, currentHead interfaces.IBlock, node interfaces.INode) bool {
+	if len(c.blocksAhead) > 0 && block.Header().MinerId() != node.Id() && c.strategy != nil {
+		state := c.SelfishAttackState(node, block.Header().Number(), false)
+		state.Fork = FORK_RELEVANT
+		if state.Lead < 0 && c.strategy.Action(state) == WAIT {
+			return false
+		}
+	}
+	return c.IConsensus.CheckReorg(localTd, externalTd, block, currentHead, node)
+}
+
+// selectFraction returns a random fraction of the nodes.
+func selectFraction(nodes []interfaces.INode, fraction float64) []interfaces.INode {
+	count := int(math.Round(float64(len(nodes)) * math.Max(math.Min(fraction, 1), 0)))
+	selected := make([]interfaces.INode, 0, count)
+	remaining := append(make([]interfaces.INode, 0, len(nodes)), nodes...)
+	for len(selected) < count {
+		i := int(random.Uniform() * float64(len(remaining)))
+		selected = append(selected, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return selected
+}
+
 func getSelfishUncles(possibleUncles []interfaces.IBlockHeader, world interfaces.IWorld, ledger interfaces.ILedger, node interfaces.INode, alreadyUsedUncles int) (uncles []interfaces.IBlockHeader, uncleHashes []string) {
 	uncles = make([]interfaces.IBlockHeader, 0, 2-alreadyUsedUncles)
 	uncleHashes = make([]string, 0, 2-alreadyUsedUncles)
@@ -296,50 +347,95 @@ func (c *SelfishMiningConsensus) SelfishAttackHandleBlockObserved(observedBlockN
 		return true
 	}
 	c.blockHandledNum[observedBlockNumber] = true
+	if observedBlockNumber > c.publicNumber {
+		c.publicNumber = observedBlockNumber
+	}
 
 	if len(c.blocksAhead) <= 0 {
 		// no selfish mining attack happening, abort handling
 		return true
 	}
 
-	currentHead := node.Ledger().Head(node)
-	if currentHead.Header().Number() == observedBlockNumber || currentHead.Header().Number() == observedBlockNumber+1 {
-		// we were either 1 block ahead, now off to a block race
-		// or we were 2 blocks ahead, now only one left -> publish all blocks
-		for i := 0; i < len(c.blocksAhead); i++ {
-			blockToPublish := c.blocksAhead[i]
-			network := node.Network()
-			network.BroadcastBlock(blockToPublish, node, world, c.SelfishAttackBroadcastBlockTargets(node, blockToPublish)...)
-		}
+	c.forkStatus = FORK_RELEVANT
+	state := c.SelfishAttackState(node, c.publicNumber, false)
+	c.SelfishAttackApplyAction(c.Strategy(world).Action(state), observedBlockHash, node, world)
+	return true
+}
+
+// Strategy returns the configured selfish mining strategy.
+func (c *SelfishMiningConsensus) Strategy(world interfaces.IWorld) SelfishMiningStrategy {
+	if c.strategy == nil {
+		c.strategy = NewSelfishMiningStrategy(c.config.Strings()["selfishMiningStrategy"], c.config.Numbers(), c.config.Strings())
+	}
+	return c.strategy
+}
+
+func (c *SelfishMiningConsensus) SelfishAttackState(node interfaces.INode, publicNumber int, ownBlock bool) SelfishMiningState {
+	uncles := 0
+	for _, block := range c.blocksAhead {
+		uncles += len(block.Body().Uncles())
+	}
+	headNumber := node.Ledger().Head(node).Header().Number()
+	return SelfishMiningState{
+		Lead:           headNumber - publicNumber,
+		AttackerBlocks: int(math.Max(float64(headNumber-c.forkNumber), 0)),
+		HonestBlocks:   int(math.Max(float64(publicNumber-c.forkNumber), 0)),
+		Fork:           c.forkStatus,
+		Uncles:         uncles,
+		OwnBlock:       ownBlock,
+	}
+}
+
+func (c *SelfishMiningConsensus) SelfishAttackApplyAction(action SelfishMiningAction, hash string, node interfaces.INode, world interfaces.IWorld) {
+	if action != WAIT {
+		logger.Audit(node.Id(), "SELFISH_"+strings.ToUpper(string(action)), hash, "", node.Time())
+	}
+	switch action {
+	case ADOPT:
+		// publish all blocks anyway, they can still be referenced as uncles
+		c.SelfishAttackPublish(math.MaxInt64, false, node, world)
 		c.blocksAhead = make([]interfaces.IBlock, 0)
-		return true
+		c.forkStatus = FORK_IRRELEVANT
+	case OVERRIDE:
+		c.SelfishAttackPublish(c.publicNumber+1, false, node, world)
+		c.forkStatus = FORK_IRRELEVANT
+		c.forkNumber = c.publicNumber + 1
+	case MATCH:
+		c.SelfishAttackPublish(c.publicNumber, true, node, world)
+		c.forkStatus = FORK_ACTIVE
+	case REVEAL:
+		c.SelfishAttackPublish(c.publicNumber, false, node, world)
+		c.forkStatus = FORK_IRRELEVANT
+	case WAIT:
+		if node.Ledger().Head(node).Header().Number() > c.publicNumber {
+			c.forkStatus = FORK_IRRELEVANT
+		}
 	}
+}
 
-	if currentHead.Header().Number() >= observedBlockNumber+2 {
-		// we were at least 3 blocks ahead, now one less -> publish first unpublished block
+// SelfishAttackPublish publishes all private blocks up to the block number.
+// If it is a race, only the fraction gamma of the peers gets the blocks from the attacker first.
+func (c *SelfishMiningConsensus) SelfishAttackPublish(maxNumber int, race bool, node interfaces.INode, world interfaces.IWorld) {
+	for len(c.blocksAhead) > 0 && c.blocksAhead[0].Header().Number() <= maxNumber {
 		blockToPublish := c.blocksAhead[0]
 		c.blocksAhead = c.blocksAhead[1:]
-		network := node.Network()
-		network.BroadcastBlock(blockToPublish, node, world, c.SelfishAttackBroadcastBlockTargets(node, blockToPublish)...)
-		return true
+		targets := c.SelfishAttackBroadcastBlockTargets(node, blockToPublish, race)
+		node.Network().BroadcastBlock(blockToPublish, node, world, targets...)
 	}
-
-	for i := 0; i < len(c.blocksAhead); i++ {
-		blockToPublish := c.blocksAhead[i]
-		network := node.Network()
-		network.BroadcastBlock(blockToPublish, node, world, c.SelfishAttackBroadcastBlockTargets(node, blockToPublish)...)
-	}
-	c.blocksAhead = make([]interfaces.IBlock, 0)
-	return true
 }
 
-func (c *SelfishMiningConsensus) SelfishAttackBroadcastBlockTargets(node interfaces.INode, block interfaces.IBlock) (targets []interfaces.INode) {
+// SelfishAttackBroadcastBlockTargets returns the peers that have not seen the block, in a race only the fraction gamma of
+// them. Only the returned peers are marked as having seen the block, the others still get it when it propagates.
+func (c *SelfishMiningConsensus) SelfishAttackBroadcastBlockTargets(node interfaces.INode, block interfaces.IBlock, race bool) (targets []interfaces.INode) {
 	selectedPeers := make([]interfaces.INode, 0, len(node.Peers()))
 	for _, peer := range node.Peers() {
 		if _, ok := node.Consensus().BlockSeen()[block.Hash()][peer.Id()]; !ok {
 			selectedPeers = append(selectedPeers, peer)
 		}
 	}
+	if gamma, ok := c.config.Numbers()["selfishMiningGamma"]; race && ok && gamma < 1 {
+		selectedPeers = selectFraction(selectedPeers, gamma)
+	}
 	// send whole block to all peers immediately
 	targets = selectedPeers
 	for _, target := range targets {
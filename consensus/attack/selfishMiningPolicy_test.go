@@ -0,0 +1,76 @@
+package attack
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// writePolicy writes the csv to a temporary file and returns its name, the caller removes it.
+func writePolicy(t *testing.T, csv string) string {
+	policyFile, err := ioutil.TempFile("", "policy*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer policyFile.Close()
+	if _, err := policyFile.WriteString(csv); err != nil {
+		t.Fatal(err)
+	}
+	return policyFile.Name()
+}
+
+func TestNewPolicyTableStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		states  int
+		wantErr bool
+	}{
+		{"header", "attackerBlocks,honestBlocks,fork,uncles,action\n1,0,irrelevant,0,wait\n1,1,relevant,0,match\n", 2, false},
+		{"no header", "1,0,irrelevant,0,wait\n1,1,relevant,0,match\n", 2, false},
+		{"comments", "# example\n1,0,irrelevant,0,wait\n", 1, false},
+		{"spaces and case", "1, 1, Active, 2, Override\n", 1, false},
+		{"malformed first line", "1,0,irrelevant,x,wait\n1,1,relevant,0,match\n", 0, true},
+		{"malformed line", "1,0,irrelevant,0,wait\n1,x,relevant,0,match\n", 0, true},
+		{"second header", "attackerBlocks,honestBlocks,fork,uncles,action\nattackerBlocks,honestBlocks,fork,uncles,action\n", 0, true},
+		{"missing column", "1,0,irrelevant,wait\n", 0, true},
+		{"unknown fork status", "1,0,forked,0,wait\n", 0, true},
+		{"unknown action", "1,0,irrelevant,0,publish\n", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := writePolicy(t, test.csv)
+			defer os.Remove(filename)
+			defer func() {
+				if r := recover(); (r != nil) != test.wantErr {
+					t.Errorf("got panic %v, want panic %v", r, test.wantErr)
+				}
+			}()
+			strategy := NewPolicyTableStrategy(filename).(*PolicyTableStrategy)
+			if len(strategy.policy) != test.states {
+				t.Errorf("got %v states, want %v", len(strategy.policy), test.states)
+			}
+		})
+	}
+}
+
+func TestPolicyTableStrategyAction(t *testing.T) {
+	filename := writePolicy(t, "2,1,relevant,0,override\n1,1,active,1,wait\n")
+	defer os.Remove(filename)
+	strategy := NewPolicyTableStrategy(filename)
+	tests := []struct {
+		state SelfishMiningState
+		want  SelfishMiningAction
+	}{
+		{SelfishMiningState{Lead: 1, AttackerBlocks: 2, HonestBlocks: 1, Fork: FORK_RELEVANT}, OVERRIDE},
+		{SelfishMiningState{Lead: 0, AttackerBlocks: 1, HonestBlocks: 1, Fork: FORK_ACTIVE, Uncles: 1}, WAIT},
+		// missing states fall back to the strategy of Eyal and Sirer
+		{SelfishMiningState{Lead: 0, AttackerBlocks: 1, HonestBlocks: 1, Fork: FORK_ACTIVE}, MATCH},
+		{SelfishMiningState{Lead: 3, AttackerBlocks: 4, HonestBlocks: 1, Fork: FORK_RELEVANT}, REVEAL},
+	}
+	for _, test := range tests {
+		if got := strategy.Action(test.state); got != test.want {
+			t.Errorf("%+v: got %v, want %v", test.state, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package attack
+
+import (
+	"ethattacksim/util/file"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+type SelfishMiningAction string
+
+// actions of a selfish miner (see Sapirshtein et al., Optimal Selfish Mining Strategies in Bitcoin)
+const (
+	ADOPT    = SelfishMiningAction("Adopt")    // give up the private chain (published as possible uncles) and mine on the public one
+	OVERRIDE = SelfishMiningAction("Override") // publish one block more than the public chain
+	MATCH    = SelfishMiningAction("Match")    // publish as many blocks as the public chain to start a block race
+	REVEAL   = SelfishMiningAction("Reveal")   // publish the blocks up to the height of the public chain while staying ahead, no race
+	WAIT     = SelfishMiningAction("Wait")     // publish nothing
+)
+
+type SelfishMiningForkStatus string
+
+const (
+	FORK_IRRELEVANT = SelfishMiningForkStatus("Irrelevant") // the last block was mined by the attacker
+	FORK_RELEVANT   = SelfishMiningForkStatus("Relevant")   // the last block was mined by the others, a match is possible
+	FORK_ACTIVE     = SelfishMiningForkStatus("Active")     // the attacker matched and a block race is ongoing
+)
+
+// SelfishMiningState is the state a selfish mining strategy decides on.
+type SelfishMiningState struct {
+	Lead           int                     // height of the private chain minus height of the public chain (negative if trailing)
+	AttackerBlocks int                     // blocks of the private chain since the fork
+	HonestBlocks   int                     // blocks of the public chain since the fork
+	Fork           SelfishMiningForkStatus // status of the fork
+	Uncles         int                     // uncles referenced by the unpublished blocks
+	OwnBlock       bool                    // if the state was reached by an own block (otherwise by a block of the others)
+}
+
+// SelfishMiningStrategy decides when the private blocks of a selfish miner are published.
+type SelfishMiningStrategy interface {
+	Action(state SelfishMiningState) SelfishMiningAction
+}
+
+// NewSelfishMiningStrategy returns the strategy for the name, combinations of stubborn strategies are joined by "+" (i.e. "leadStubborn+trailStubborn").
+func NewSelfishMiningStrategy(name string, numbers map[string]float64, strs map[string]string) SelfishMiningStrategy {
+	if name == "policyTable" {
+		return NewPolicyTableStrategy(strs["selfishMiningPolicyFile"])
+	}
+	strategy := &StubbornStrategy{}
+	for _, part := range strings.Split(name, "+") {
+		switch strings.TrimSpace(part) {
+		case "", "eyalSirer":
+			// default
+		case "leadStubborn":
+			strategy.leadStubborn = true
+		case "equalForkStubborn":
+			strategy.equalForkStubborn = true
+		case "trailStubborn":
+			strategy.trailStubbornness = 1
+			if trailStubbornness, ok := numbers["trailStubbornness"]; ok {
+				strategy.trailStubbornness = int(trailStubbornness)
+			}
+		default:
+			log.Printf("unknown selfish mining strategy %v, using eyalSirer instead\n", part)
+		}
+	}
+	return strategy
+}
+
+// StubbornStrategy implements the strategy of Eyal and Sirer (Majority is not Enough: Bitcoin Mining is Vulnerable)
+// and its stubborn variants of Nayak et al. (Stubborn Mining: Generalizing Selfish Mining and Combining with an Eclipse Attack).
+type StubbornStrategy struct {
+	leadStubborn      bool // match instead of override when the lead drops to one
+	equalForkStubborn bool // keep own blocks found during a block race private
+	trailStubbornness int  // blocks the private chain may trail before it is given up
+}
+
+func (s *StubbornStrategy) Action(state SelfishMiningState) SelfishMiningAction {
+	if state.OwnBlock {
+		switch {
+		case state.Lead == 0:
+			// the trailing private chain caught up
+			return MATCH
+		case state.Fork == FORK_ACTIVE && state.Lead == 1 && !s.equalForkStubborn:
+			// the block race was won
+			return OVERRIDE
+		default:
+			return WAIT
+		}
+	}
+	switch {
+	case state.Lead < 0 && -state.Lead <= s.trailStubbornness:
+		return WAIT
+	case state.Lead < 0:
+		return ADOPT
+	case state.Lead == 0:
+		return MATCH
+	case state.Lead == 1 && !s.leadStubborn:
+		return OVERRIDE
+	default:
+		return REVEAL
+	}
+}
+
+// PolicyTableStrategy follows a precomputed policy (i.e. the solution of a MDP of selfish mining with uncles).
+// States missing in the table are handled by the strategy of Eyal and Sirer.
+type PolicyTableStrategy struct {
+	policy   map[string]SelfishMiningAction
+	fallback SelfishMiningStrategy
+}
+
+// NewPolicyTableStrategy loads the policy from a csv file with the columns
+// attacker blocks, honest blocks, fork status (irrelevant, relevant, active), uncles and action (adopt, override, match, reveal, wait).
+func NewPolicyTableStrategy(filename string) SelfishMiningStrategy {
+	strategy := &PolicyTableStrategy{policy: make(map[string]SelfishMiningAction), fallback: &StubbornStrategy{}}
+	for i, record := range file.LoadCsv(filename) {
+		if len(record) != 5 {
+			log.Panicf("policy table %v line %v: expected 5 columns but got %v", filename, i+1, len(record))
+		}
+		values := make([]int, 0, 3)
+		var errs []error
+		for _, column := range []int{0, 1, 3} {
+			value, err := strconv.Atoi(strings.TrimSpace(record[column]))
+			if err != nil {
+				errs = append(errs, err)
+			}
+			values = append(values, value)
+		}
+		if i == 0 && len(errs) == len(values) {
+			// header, none of the number columns is a number
+			continue
+		}
+		if len(errs) > 0 {
+			log.Panicf("policy table %v line %v: %v", filename, i+1, errs[0])
+		}
+		fork, forkOk := parseForkStatus(record[2])
+		action, actionOk := parseAction(record[4])
+		if !forkOk || !actionOk {
+			log.Panicf("policy table %v line %v: unknown fork status %v or action %v", filename, i+1, record[2], record[4])
+		}
+		strategy.policy[policyKey(values[0], values[1], fork, values[2])] = action
+	}
+	log.Printf("loaded selfish mining policy table %v with %v states\n", filename, len(strategy.policy))
+	return strategy
+}
+
+func (s *PolicyTableStrategy) Action(state SelfishMiningState) SelfishMiningAction {
+	if action, ok := s.policy[policyKey(state.AttackerBlocks, state.HonestBlocks, state.Fork, state.Uncles)]; ok {
+		return action
+	}
+	return s.fallback.Action(state)
+}
+
+func policyKey(attackerBlocks int, honestBlocks int, fork SelfishMiningForkStatus, uncles int) string {
+	return fmt.Sprintf("%v,%v,%v,%v", attackerBlocks, honestBlocks, fork, uncles)
+}
+
+func parseForkStatus(value string) (SelfishMiningForkStatus, bool) {
+	for _, fork := range []SelfishMiningForkStatus{FORK_IRRELEVANT, FORK_RELEVANT, FORK_ACTIVE} {
+		if strings.EqualFold(strings.TrimSpace(value), string(fork)) {
+			return fork, true
+		}
+	}
+	return FORK_IRRELEVANT, false
+}
+
+func parseAction(value string) (SelfishMiningAction, bool) {
+	for _, action := range []SelfishMiningAction{ADOPT, OVERRIDE, MATCH, REVEAL, WAIT} {
+		if strings.EqualFold(strings.TrimSpace(value), string(action)) {
+			return action, true
+		}
+	}
+	return WAIT, false
+}
@@ -0,0 +1,45 @@
+package attack
+
+import "testing"
+
+func TestStubbornStrategyAction(t *testing.T) {
+	tests := []struct {
+		strategy string
+		state    SelfishMiningState
+		want     SelfishMiningAction
+	}{
+		// blocks of the others
+		{"eyalSirer", SelfishMiningState{Lead: -1}, ADOPT},
+		{"eyalSirer", SelfishMiningState{Lead: 0, Fork: FORK_RELEVANT}, MATCH},
+		{"eyalSirer", SelfishMiningState{Lead: 1, Fork: FORK_RELEVANT}, OVERRIDE},
+		{"eyalSirer", SelfishMiningState{Lead: 2, Fork: FORK_RELEVANT}, REVEAL},
+		{"leadStubborn", SelfishMiningState{Lead: 1, Fork: FORK_RELEVANT}, REVEAL},
+		{"leadStubborn", SelfishMiningState{Lead: 2, Fork: FORK_RELEVANT}, REVEAL},
+		{"trailStubborn", SelfishMiningState{Lead: -1}, WAIT},
+		{"trailStubborn", SelfishMiningState{Lead: -2}, ADOPT},
+		{"leadStubborn+trailStubborn", SelfishMiningState{Lead: -1}, WAIT},
+		{"leadStubborn+trailStubborn", SelfishMiningState{Lead: 1, Fork: FORK_RELEVANT}, REVEAL},
+		// own blocks
+		{"eyalSirer", SelfishMiningState{Lead: 0, OwnBlock: true}, MATCH},
+		{"eyalSirer", SelfishMiningState{Lead: 1, Fork: FORK_IRRELEVANT, OwnBlock: true}, WAIT},
+		{"eyalSirer", SelfishMiningState{Lead: 1, Fork: FORK_ACTIVE, OwnBlock: true}, OVERRIDE},
+		{"eyalSirer", SelfishMiningState{Lead: 2, Fork: FORK_IRRELEVANT, OwnBlock: true}, WAIT},
+		{"equalForkStubborn", SelfishMiningState{Lead: 1, Fork: FORK_ACTIVE, OwnBlock: true}, WAIT},
+		{"trailStubborn", SelfishMiningState{Lead: 0, OwnBlock: true}, MATCH},
+	}
+	for _, test := range tests {
+		strategy := NewSelfishMiningStrategy(test.strategy, map[string]float64{}, map[string]string{})
+		if got := strategy.Action(test.state); got != test.want {
+			t.Errorf("%v with %+v: got %v, want %v", test.strategy, test.state, got, test.want)
+		}
+	}
+}
+
+func TestTrailStubbornness(t *testing.T) {
+	strategy := NewSelfishMiningStrategy("trailStubborn", map[string]float64{"trailStubbornness": 3}, map[string]string{})
+	for lead, want := range map[int]SelfishMiningAction{-1: WAIT, -3: WAIT, -4: ADOPT} {
+		if got := strategy.Action(SelfishMiningState{Lead: lead}); got != want {
+			t.Errorf("lead %v: got %v, want %v", lead, got, want)
+		}
+	}
+}
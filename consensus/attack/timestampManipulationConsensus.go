@@ -0,0 +1,84 @@
+package attack
+
+import (
+	"ethattacksim/consensus"
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"fmt"
+)
+
+// TimestampManipulationConsensus sets the timestamps of its blocks to the latest time that still gives the highest
+// difficulty instead of the real time, and forks out blocks of other miners if its block on their parent gets a higher
+// difficulty, so they become uncles (i.e. uncle maker attack).
+type TimestampManipulationConsensus struct {
+	interfaces.IConsensus
+	config            interfaces.IAttackerConfig
+	manipulatedBlocks int
+	timestampShift    int64 // seconds, summed up over all manipulated blocks
+	forkedBlocks      int
+}
+
+func NewTimestampManipulationConsensus(consensus interfaces.IConsensus, config interfaces.IAttackerConfig) interfaces.IConsensus {
+	return &TimestampManipulationConsensus{IConsensus: consensus, config: config}
+}
+
+func init() {
+	RegisterAttackerType("timestampManipulation", AttackerType{
+		NewGroup: func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+			return func() interfaces.IConsensus {
+				return NewTimestampManipulationConsensus(consensus.NewConsensus(), config)
+			}
+		},
+	})
+}
+
+// MineBlock mines on the parent of the head instead if the head was mined by another node and a block on the parent
+// gets a higher difficulty than the head, the block then wins the fork by total difficulty.
+func (c *TimestampManipulationConsensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld) {
+	head := ledger.Head(node)
+	if head.Header().MinerId() != node.Id() && head.Header().Number() > 1 { // the genesis block is never the new head
+		parent := ledger.GetBlock(node, head.ParentHash())
+		if node.Consensus().CalcDifficulty(parent.Header(), parent.Header().Time()+1, world) > head.Header().Difficulty() && ledger.ReorgTo(node, parent, world) {
+			c.forkedBlocks++
+			logger.Audit(node.Id(), "TIMESTAMP_FORK", head.Hash(), parent.Hash(), node.Time())
+		}
+	}
+	c.IConsensus.MineBlock(ledger, node, world)
+}
+
+// GetTimestamp returns the latest timestamp that gives the highest difficulty, it is never later than the mined
+// timestamp so it passes the future block check and at least one second after the parent so it is no older block.
+func (c *TimestampManipulationConsensus) GetTimestamp(parentHeader interfaces.IBlockHeader, minedTimestamp int64, node interfaces.INode, world interfaces.IWorld) (timestamp int64) {
+	earliest := parentHeader.Time() + 1
+	if minedTimestamp <= earliest {
+		return minedTimestamp
+	}
+	maxDifficulty := node.Consensus().CalcDifficulty(parentHeader, earliest, world)
+	if node.Consensus().CalcDifficulty(parentHeader, minedTimestamp, world) >= maxDifficulty {
+		return minedTimestamp
+	}
+	// the difficulty does not rise with the timestamp, search the last second with the highest difficulty
+	low, high := earliest, minedTimestamp
+	for high-low > 1 {
+		middle := low + (high-low)/2
+		if node.Consensus().CalcDifficulty(parentHeader, middle, world) >= maxDifficulty {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	timestamp = low
+	c.manipulatedBlocks++
+	c.timestampShift += timestamp - minedTimestamp
+	logger.Audit(node.Id(), "TIMESTAMP_MANIPULATED", parentHeader.Hash(), fmt.Sprintf("%v", timestamp-minedTimestamp), node.Time())
+	return
+}
+
+// ConsensusStats returns the count of manipulated timestamps, their mean shift and the forked blocks of other miners.
+func (c *TimestampManipulationConsensus) ConsensusStats(node interfaces.INode, world interfaces.IWorld) map[string]map[string]float64 {
+	stats := map[string]float64{"manipulatedTimestamps": float64(c.manipulatedBlocks), "timestampForkedBlocks": float64(c.forkedBlocks)}
+	if c.manipulatedBlocks > 0 {
+		stats["meanTimestampShift"] = float64(c.timestampShift) / float64(c.manipulatedBlocks) // seconds
+	}
+	return map[string]map[string]float64{node.Id(): stats}
+}
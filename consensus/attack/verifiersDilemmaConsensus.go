@@ -1,6 +1,7 @@
 package attack
 
 import (
+	"ethattacksim/consensus"
 	"ethattacksim/interfaces"
 )
 
@@ -12,6 +13,16 @@ func NewVerifiersDilemmaConsensus(consensus interfaces.IConsensus) interfaces.IC
 	return &VerifiersDilemmaConsensus{IConsensus: consensus}
 }
 
+func init() {
+	RegisterAttackerType("verifiersDilemma", AttackerType{
+		NewGroup: func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+			return func() interfaces.IConsensus {
+				return NewVerifiersDilemmaConsensus(consensus.NewConsensus())
+			}
+		},
+	})
+}
+
 func (c *VerifiersDilemmaConsensus) VerifyTx(tx interfaces.ITransaction, node interfaces.INode) (ok bool) {
 	return true
 }
@@ -20,6 +31,6 @@ func (c *VerifiersDilemmaConsensus) VerifyState(block interfaces.IBlock, node in
 	return true
 }
 
-func (c *VerifiersDilemmaConsensus) BroadcastReceivedBlockTargets(node interfaces.INode, block interfaces.IBlock, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+func (c *VerifiersDilemmaConsensus) BroadcastReceivedBlockTargets(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
 	return
 }
@@ -3,6 +3,7 @@ package attack
 import (
 	"crypto/sha256"
 	"encoding/base64"
+	"ethattacksim/consensus"
 	"ethattacksim/event"
 	"ethattacksim/event/events"
 	"ethattacksim/interfaces"
@@ -16,10 +17,26 @@ import (
 
 type VerifiersDilemmaConsensusForced struct {
 	interfaces.IConsensus
+	config interfaces.IAttackerConfig
 }
 
-func NewVerifiersDilemmaConsensusForced(consensus interfaces.IConsensus) interfaces.IConsensus {
-	return &VerifiersDilemmaConsensusForced{IConsensus: consensus}
+func NewVerifiersDilemmaConsensusForced(consensus interfaces.IConsensus, config interfaces.IAttackerConfig) interfaces.IConsensus {
+	return &VerifiersDilemmaConsensusForced{IConsensus: consensus, config: config}
+}
+
+func init() {
+	RegisterAttackerType("verifiersDilemmaForced", AttackerType{
+		Numbers: map[string]AttackerParam{
+			"percentOfGasToForceVerifiersDilemma": {Description: "share of the gas limit used by the attacker's expensive tx, 0.5 = 50%"},
+			"percentOfMaxGasLimitIncrease":        {Description: "share of the max gas limit increase, 0.5 = 0.5 * parentGasLimit/1024"},
+			"specialTxStateComputation":           {Description: "state computation delay of the attacker's expensive tx"},
+		},
+		NewGroup: func(world interfaces.IWorld, config interfaces.IAttackerConfig) func() interfaces.IConsensus {
+			return func() interfaces.IConsensus {
+				return NewVerifiersDilemmaConsensusForced(consensus.NewConsensus(), config)
+			}
+		},
+	})
 }
 
 func (c *VerifiersDilemmaConsensusForced) VerifyTx(tx interfaces.ITransaction, node interfaces.INode) (ok bool) {
@@ -30,11 +47,11 @@ func (c *VerifiersDilemmaConsensusForced) VerifyState(block interfaces.IBlock, n
 	return true
 }
 
-func (c *VerifiersDilemmaConsensusForced) BroadcastReceivedBlockTargets(node interfaces.INode, block interfaces.IBlock, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+func (c *VerifiersDilemmaConsensusForced) BroadcastReceivedBlockTargets(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
 	return
 }
 
-func (c *VerifiersDilemmaConsensusForced) BroadcastNewBlockTargets(node interfaces.INode, block interfaces.IBlock, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+func (c *VerifiersDilemmaConsensusForced) BroadcastNewBlockTargets(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
 	selectedPeers := make([]interfaces.INode, 0, len(node.Peers()))
 	for _, peer := range node.Peers() {
 		if _, ok := node.Consensus().BlockSeen()[block.Hash()][peer.Id()]; !ok {
@@ -56,32 +73,35 @@ func (c *VerifiersDilemmaConsensusForced) BroadcastNewBlockTargets(node interfac
 }
 
 func (c *VerifiersDilemmaConsensusForced) MineBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld) {
-	blockTimeStamp, miningTimeDelay := random.TimeBetweenBlocks(world.SimConfig().OverallHashPower(), node.HashPower(), ledger.Head(node).Header().Time(), node.Time())
+	blockTimeStamp, miningTimeDelay := node.Consensus().GetMiningTime(ledger.Head(node).Header(), node, world)
+	blockTimeStamp = node.Consensus().GetTimestamp(ledger.Head(node).Header(), blockTimeStamp, node, world)
 	miningTime := node.Time() + miningTimeDelay
 	newGasLimit := node.Consensus().GetGasLimit(ledger.Head(node).Header(), world)
+	baseFee := node.Consensus().CalcBaseFee(ledger.Head(node).Header(), world)
+	pending := ledger.AccountsAt(node, ledger.HeadHash()) // nil without account state
 	txs := make([]interfaces.ITransaction, 0, 50)
 	gasAlreadyUsed := 0
 
 	// create bad transaction
-	if world.SimConfig().Attacker().Numbers()["percentOfGasToForceVerifiersDilemma"] > 0 {
-		badTx := createBadTransaction(int(float64(newGasLimit)*world.SimConfig().Attacker().Numbers()["percentOfGasToForceVerifiersDilemma"]), world.SimConfig().Attacker().Numbers()["specialTxStateComputation"], node)
+	if c.config.Numbers()["percentOfGasToForceVerifiersDilemma"] > 0 {
+		badTx := createBadTransaction(int(float64(newGasLimit)*c.config.Numbers()["percentOfGasToForceVerifiersDilemma"]), c.config.Numbers()["specialTxStateComputation"], node, baseFee, pending)
 		txs = append(txs, badTx)
 		gasAlreadyUsed += badTx.GasUsed()
 	}
 
 	if len(ledger.QueuedTxs()) > 0 {
 		localTxs, remoteTxs := ledger.SortedTxByLocalAndRemote(node)
-		localsToUse, gasUsedFromLocals := node.Consensus().GetTxsForBlock(gasAlreadyUsed, localTxs, newGasLimit)
+		localsToUse, gasUsedFromLocals := node.Consensus().GetTxsForBlock(gasAlreadyUsed, localTxs, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, localsToUse...)
 		gasAlreadyUsed += gasUsedFromLocals
-		remotesToUse, gasUsedFromRemotes := node.Consensus().GetTxsForBlock(gasAlreadyUsed, remoteTxs, newGasLimit)
+		remotesToUse, gasUsedFromRemotes := node.Consensus().GetTxsForBlock(gasAlreadyUsed, remoteTxs, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, remotesToUse...)
 		gasAlreadyUsed += gasUsedFromRemotes
 	}
 
 	// fill block with random txs if tx creation propagation is not simulated
 	if !world.SimConfig().SimulateTransactionCreation() {
-		randomsToUse, gasUsedFromRandoms := events.CreateRandomTransactionsForBlock(gasAlreadyUsed, world, newGasLimit)
+		randomsToUse, gasUsedFromRandoms := events.CreateRandomTransactionsForBlock(gasAlreadyUsed, world, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, randomsToUse...)
 		gasAlreadyUsed += gasUsedFromRandoms
 	}
@@ -135,17 +155,33 @@ func (c *VerifiersDilemmaConsensusForced) MineBlock(ledger interfaces.ILedger, n
 	}
 	uncleHash := strings.Join(uncleHashes, ",")
 	blockSize := world.SimConfig().Sizes()["header"] + world.SimConfig().Sizes()["tx"]*len(txs) + world.SimConfig().Sizes()["header"]*len(uncles)
-	header := ledg.NewBlockHeader(world.NewBlockHash(), txSha256, uncleHash, ledger.Head(node).Hash(), node.Id(), node.Consensus().CalcDifficulty(ledger.Head(node).Header(), blockTimeStamp, world), gasAlreadyUsed, newGasLimit, blockTimeStamp, ledger.Head(node).Header().Number()+1, blockSize, true)
+	header := ledg.NewBlockHeader(world.NewBlockHash(), txSha256, uncleHash, ledger.Head(node).Hash(), node.Id(), node.Consensus().CalcDifficulty(ledger.Head(node).Header(), blockTimeStamp, world), gasAlreadyUsed, newGasLimit, baseFee, blockTimeStamp, ledger.Head(node).Header().Number()+1, blockSize, true)
 	body := ledg.NewBlockBody(header.Hash(), txs, uncles, true, len(txs))
 	block := ledg.NewBlock(header, body, ledger.Head(node).TotalDifficulty()+header.Difficulty())
 	ev := events.NewNewBlockEvent(event.NewEvent(miningTime, node.Id(), interfaces.NEW_BLOCK_EVENT), block, node.Time())
 	world.Queue().Add(ev)
 }
 
-func createBadTransaction(gasToUse int, specialTxStateComputation float64, senderNode interfaces.INode) interfaces.ITransaction {
+// createBadTransaction creates the attacker's expensive tx, with EIP-1559 it pays just the base fee (wei) as the tip would go to the attacker anyway.
+// With account state the tx gets the next nonce of the attacker in pending and is applied to it.
+func createBadTransaction(gasToUse int, specialTxStateComputation float64, senderNode interfaces.INode, baseFee int, pending interfaces.IAccounts) (tx interfaces.ITransaction) {
 	senderId, senderNonce := senderNode.Id(), senderNode.Nonce()
 	senderNode.IncNonce()
-	return ledg.NewTx(fmt.Sprintf("R%v_%v", senderId, senderNonce), senderNonce, senderId, gasToUse, int(random.GasPrice()), true, specialTxStateComputation)
+	txId, nonce := fmt.Sprintf("R%v_%v", senderId, senderNonce), senderNonce
+	if pending != nil {
+		// the tx is tossed away when it is reorged out, so the node's own counter would leave nonce gaps
+		nonce = pending.Nonce(senderId)
+	}
+	if baseFee > 0 {
+		maxFeePerGas := (baseFee + 999999999) / 1000000000 // wei to gwei, rounded up
+		tx = ledg.NewEip1559Tx(txId, nonce, senderId, gasToUse, maxFeePerGas, 0, true, specialTxStateComputation, senderNode.Time())
+	} else {
+		tx = ledg.NewTx(txId, nonce, senderId, gasToUse, int(random.GasPrice()), true, specialTxStateComputation, senderNode.Time())
+	}
+	if pending != nil {
+		pending.ApplyTx(tx, senderId, baseFee)
+	}
+	return tx
 }
 
 func getUncles(possibleUncles []interfaces.IBlockHeader, world interfaces.IWorld, ledger interfaces.ILedger, node interfaces.INode, alreadyUsedUncles int) (uncles []interfaces.IBlockHeader, uncleHashes []string) {
@@ -171,7 +207,7 @@ func getUncles(possibleUncles []interfaces.IBlockHeader, world interfaces.IWorld
 
 func (c *VerifiersDilemmaConsensusForced) GetGasLimit(currentHead interfaces.IBlockHeader, world interfaces.IWorld) (gasLimit int) {
 	maxAdaption := currentHead.GasLimit() / 1024 // maximum allowed change of gas limit
-	maxAdaption = int(float64(maxAdaption) * world.SimConfig().Attacker().Numbers()["percentOfMaxGasLimitIncrease"])
+	maxAdaption = int(float64(maxAdaption) * c.config.Numbers()["percentOfMaxGasLimitIncrease"])
 	gasLimit = currentHead.GasLimit() + maxAdaption
 	return
 }
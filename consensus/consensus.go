@@ -11,6 +11,7 @@ import (
 	"ethattacksim/util/logger"
 	"ethattacksim/util/metrics"
 	"ethattacksim/util/random"
+	"fmt"
 	"math"
 	"sort"
 	"strings"
@@ -18,16 +19,26 @@ import (
 )
 
 type Consensus struct {
-	blockSeen                map[string]map[string]bool         // peers
-	txSeen                   map[string]map[string]bool         // peers
-	retrievingHeaders        map[string]bool                    // indicates if header for block hash is retrieving
-	retrievingBodies         map[string]interfaces.IBlockHeader // indicates if body is retrieving and caches header
-	futureBlockQueue         map[string]interfaces.IBlock       // parent hash to block
-	futureBlockQueueSenderId map[string]string                  // block hash to sender id, only for dropping peer
+	blockSeen            map[string]map[string]bool         // peers
+	txSeen               map[string]map[string]bool         // peers
+	retrievingHeaders    map[string]bool                    // indicates if header for block hash is retrieving
+	retrievingBodies     map[string]interfaces.IBlockHeader // indicates if body is retrieving and caches header
+	futureBlocks         interfaces.IFutureBlockQueue
+	reconstructingBlocks map[string]interfaces.IBlock // compact blocks waiting for their missing txs
+	requests             interfaces.IRequestTracker
+	sync                 interfaces.ISync
 }
 
 func NewConsensus() interfaces.IConsensus {
-	return &Consensus{blockSeen: make(map[string]map[string]bool, 1000), txSeen: make(map[string]map[string]bool, 1000), retrievingHeaders: make(map[string]bool, 1000), retrievingBodies: make(map[string]interfaces.IBlockHeader, 1000), futureBlockQueue: make(map[string]interfaces.IBlock, 20), futureBlockQueueSenderId: make(map[string]string, 20)}
+	return &Consensus{blockSeen: make(map[string]map[string]bool, 1000), txSeen: make(map[string]map[string]bool, 1000), retrievingHeaders: make(map[string]bool, 1000), retrievingBodies: make(map[string]interfaces.IBlockHeader, 1000), futureBlocks: NewFutureBlockQueue(), reconstructingBlocks: make(map[string]interfaces.IBlock, 20), requests: NewRequestTracker(), sync: NewSync()}
+}
+
+// NewConfiguredConsensus returns the consensus of the configured mode, proof of work or proof of stake (Gasper).
+func NewConfiguredConsensus(config interfaces.IConfig) interfaces.IConsensus {
+	if config.Consensus() == interfaces.CONSENSUS_POS {
+		return NewGasperConsensus(NewConsensus(), config.Pos())
+	}
+	return NewConsensus()
 }
 
 func (c *Consensus) BlockSeen() map[string]map[string]bool {
@@ -46,12 +57,20 @@ func (c *Consensus) RetrievingBodies() map[string]interfaces.IBlockHeader {
 	return c.retrievingBodies
 }
 
-func (c *Consensus) FutureBlockQueue() map[string]interfaces.IBlock {
-	return c.futureBlockQueue
+func (c *Consensus) FutureBlocks() interfaces.IFutureBlockQueue {
+	return c.futureBlocks
+}
+
+func (c *Consensus) ReconstructingBlocks() map[string]interfaces.IBlock {
+	return c.reconstructingBlocks
 }
 
-func (c *Consensus) FutureBlockQueueSenderId() map[string]string {
-	return c.futureBlockQueueSenderId
+func (c *Consensus) Requests() interfaces.IRequestTracker {
+	return c.requests
+}
+
+func (c *Consensus) Sync() interfaces.ISync {
+	return c.sync
 }
 
 func (c *Consensus) ReceivedBlockEvent(node interfaces.INode, block interfaces.IBlock, senderId string, world interfaces.IWorld) {
@@ -63,6 +82,9 @@ func (c *Consensus) ReceivedBlockEvent(node interfaces.INode, block interfaces.I
 		if _, ok := node.Consensus().RetrievingBodies()[block.Hash()]; ok {
 			delete(node.Consensus().RetrievingBodies(), block.Hash())
 		}
+		if StartSync(node, world.Nodes()[senderId], block, world) {
+			return // the block is the head of the sync
+		}
 		node.Consensus().InsertBlock(block, node, node.Ledger(), world, senderId, -1)
 	}
 }
@@ -73,16 +95,16 @@ func (c *Consensus) NewBlockEvent(node interfaces.INode, block interfaces.IBlock
 		if node.Ledger().Head(node).Hash() == block.ParentHash() {
 			node.Ledger().AppendBlockToCurrent(node, block)
 			network.BroadcastBlock(block, node, world,
-				node.Consensus().BroadcastNewBlockTargets(node, block, true, node.Id())...)
+				node.Consensus().BroadcastNewBlockTargets(node, block, world, true, node.Id())...)
 			network.BroadcastBlockHash(block.Hash(), block.Header().Number(), node, world,
-				node.Consensus().BroadcastNewBlockTargets(node, block, false, node.Id())...)
+				node.Consensus().BroadcastNewBlockTargets(node, block, world, false, node.Id())...)
 			node.Consensus().MineBlock(node.Ledger(), node, world)
 		} else {
 			// a block was mined during insert of a received block
 			network.BroadcastBlock(block, node, world,
-				node.Consensus().BroadcastNewBlockTargets(node, block, true, node.Id())...)
+				node.Consensus().BroadcastNewBlockTargets(node, block, world, true, node.Id())...)
 			network.BroadcastBlockHash(block.Hash(), block.Header().Number(), node, world,
-				node.Consensus().BroadcastNewBlockTargets(node, block, false, node.Id())...)
+				node.Consensus().BroadcastNewBlockTargets(node, block, world, false, node.Id())...)
 			node.Consensus().InsertBlock(block, node, node.Ledger(), world, node.Id(), evTime)
 		}
 	}
@@ -92,12 +114,14 @@ func (c *Consensus) ReceivedBlockHashesEvent(node interfaces.INode, hashes []str
 	if node.IsOnline() {
 		minNumber := math.MaxInt64
 		minNumberHash := ""
+		retrieveHashes := make([]string, 0, len(hashes))
 		for i, h := range hashes {
 			node.Consensus().MarkBlockSeen(node, h, senderId)
 			if !node.Ledger().HasBlock(node, h) {
 				isRetrieving, ok := node.Consensus().RetrievingHeaders()[h]
 				if !ok || !isRetrieving {
 					node.Consensus().RetrievingHeaders()[h] = true
+					retrieveHashes = append(retrieveHashes, h)
 					if numbers[i] < minNumber {
 						minNumber = numbers[i]
 						minNumberHash = h
@@ -106,37 +130,65 @@ func (c *Consensus) ReceivedBlockHashesEvent(node interfaces.INode, hashes []str
 			}
 		}
 		if minNumber != math.MaxInt64 {
-			node.Network().RetrieveBlockHeaders(node, world.Nodes()[senderId], world, minNumberHash, len(hashes), false, 0)
+			SendRequest(node, world.Nodes()[senderId], world, interfaces.REQUEST_HEADERS, minNumberHash, retrieveHashes, len(hashes))
 		}
 	}
 }
 
-func (c *Consensus) RetrieveBlockHeadersEvent(node interfaces.INode, originBlockHash string, num int, reverse bool, skip int, senderId string, world interfaces.IWorld) {
+func (c *Consensus) RetrieveBlockHeadersEvent(node interfaces.INode, requestId int, originBlockHash string, num int, reverse bool, skip int, senderId string, world interfaces.IWorld) {
 	if node.IsOnline() {
 		headers := node.Consensus().RetrieveHeaders(node, node.Ledger(), originBlockHash, num, reverse, skip)
-		if len(headers) != 0 {
-			node.Network().SendBlockHeaders(node, world.Nodes()[senderId], world, headers)
+		if len(headers) != 0 || RequestTimeoutActive(world) {
+			node.Network().SendBlockHeaders(node, world.Nodes()[senderId], world, requestId, headers)
 		}
 	}
 }
 
-func (c *Consensus) ReceivedTxsEvent(node interfaces.INode, txs []interfaces.ITransaction, senderId string, world interfaces.IWorld) {
+func (c *Consensus) ReceivedTxsEvent(node interfaces.INode, requestId int, txs []interfaces.ITransaction, senderId string, world interfaces.IWorld) {
 	if node.IsOnline() {
 		for _, tx := range txs {
 			node.Consensus().MarkTxSeen(node, tx.Id(), senderId)
 			if !node.Ledger().KnowsQueuedTx(node, tx.Id()) {
 				ok := node.Consensus().VerifyTx(tx, node)
 				if ok {
-					// add to queue and broadcast if valid
-					node.Ledger().AddTxsToQueue(node, tx)
-					broadcastPropagateTargets := node.Consensus().BroadcastTxTargets(node, tx, true, node.Id())
+					// add to queue and broadcast if valid and not dropped by the tx pool
+					dropped, err := node.Ledger().AddTxToPool(node, tx)
+					recordTxPoolChanges(node, tx, dropped, err, world)
+					if err != nil {
+						continue
+					}
+					broadcastPropagateTargets := node.Consensus().BroadcastTxTargets(node, tx, world, true, node.Id())
 					node.Network().BroadcastTxs([]interfaces.ITransaction{tx}, node, world, broadcastPropagateTargets...)
-					broadcastOtherTargets := node.Consensus().BroadcastTxTargets(node, tx, false, node.Id())
+					broadcastOtherTargets := node.Consensus().BroadcastTxTargets(node, tx, world, false, node.Id())
 					node.Network().BroadcastTxHashes([]string{tx.Id()}, node, world, broadcastOtherTargets...)
 				}
 			}
 		}
 	}
+	AnswerRequest(node, requestId, txIds(txs), world)
+}
+
+// recordTxPoolChanges counts the txs rejected, replaced or evicted by the tx pool when adding tx.
+func recordTxPoolChanges(node interfaces.INode, tx interfaces.ITransaction, dropped []interfaces.ITransaction, err error, world interfaces.IWorld) {
+	if err != nil {
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_TX_DROPPED, node.Id()), 1)
+		if world.SimConfig().AuditLogTxMessages() {
+			logger.Audit(node.Id(), "TX_DROPPED", tx.Id(), err.Error(), node.Time())
+		}
+		return
+	}
+	for _, old := range dropped {
+		auditType := "TX_EVICTED"
+		if old.SenderId() == tx.SenderId() && old.Nonce() == tx.Nonce() {
+			auditType = "TX_REPLACED"
+			metrics.Counter(metrics.NameFormat(interfaces.METRIC_TX_REPLACED, node.Id()), 1)
+		} else {
+			metrics.Counter(metrics.NameFormat(interfaces.METRIC_TX_EVICTED, node.Id()), 1)
+		}
+		if world.SimConfig().AuditLogTxMessages() {
+			logger.Audit(node.Id(), auditType, old.Id(), tx.Id(), node.Time())
+		}
+	}
 }
 
 func (c *Consensus) ReceivedTxHashesEvent(node interfaces.INode, txHashes []string, senderId string, world interfaces.IWorld) {
@@ -148,11 +200,13 @@ func (c *Consensus) ReceivedTxHashesEvent(node interfaces.INode, txHashes []stri
 				toRetrieve = append(toRetrieve, txHash)
 			}
 		}
-		node.Network().RetrieveTxs(toRetrieve, node, world.Nodes()[senderId], world)
+		if len(toRetrieve) > 0 {
+			SendRequest(node, world.Nodes()[senderId], world, interfaces.REQUEST_TXS, "", toRetrieve, 0)
+		}
 	}
 }
 
-func (c *Consensus) RetrieveTxsEvent(node interfaces.INode, txHashes []string, senderId string, world interfaces.IWorld) {
+func (c *Consensus) RetrieveTxsEvent(node interfaces.INode, requestId int, txHashes []string, senderId string, world interfaces.IWorld) {
 	ret := make([]interfaces.ITransaction, 0, len(txHashes))
 	if node.IsOnline() {
 		for _, txHash := range txHashes {
@@ -161,12 +215,18 @@ func (c *Consensus) RetrieveTxsEvent(node interfaces.INode, txHashes []string, s
 				node.Consensus().MarkTxSeen(node, txHash, senderId)
 			}
 		}
-		node.Network().BroadcastTxs(ret, node, world, world.Nodes()[senderId])
+		if len(ret) > 0 || RequestTimeoutActive(world) {
+			node.Network().SendTxs(ret, node, world.Nodes()[senderId], world, requestId)
+		}
 	}
 }
 
-func (c *Consensus) ReceivedBlockHeadersEvent(node interfaces.INode, headers []interfaces.IBlockHeader, senderId string, world interfaces.IWorld) {
-	if node.IsOnline() {
+func (c *Consensus) ReceivedBlockHeadersEvent(node interfaces.INode, requestId int, headers []interfaces.IBlockHeader, senderId string, world interfaces.IWorld) {
+	if request := node.Consensus().Requests().Get(requestId); request != nil && isSyncRequest(request) {
+		if node.IsOnline() {
+			node.Consensus().Sync().ReceivedHeaders(node, request, headers, world)
+		}
+	} else if node.IsOnline() {
 		retrieveHashes := make([]string, 0, len(headers))
 		blocksToImport := make([]interfaces.IBlock, 0)
 		for _, header := range headers {
@@ -183,28 +243,37 @@ func (c *Consensus) ReceivedBlockHeadersEvent(node interfaces.INode, headers []i
 			}
 		}
 		if len(retrieveHashes) > 0 {
-			node.Network().RetrieveBlockBodies(node, world.Nodes()[senderId], world, retrieveHashes)
+			SendRequest(node, world.Nodes()[senderId], world, interfaces.REQUEST_BODIES, "", retrieveHashes, 0)
 		}
 		for _, b := range blocksToImport {
 			node.Consensus().InsertBlock(b, node, node.Ledger(), world, senderId, -1)
 		}
 	}
+	delivered := make([]string, 0, len(headers))
+	for _, header := range headers {
+		delivered = append(delivered, header.Hash())
+	}
+	AnswerRequest(node, requestId, delivered, world)
 }
 
-func (c *Consensus) RetrieveBlockBodiesEvent(node interfaces.INode, hashes []string, senderId string, world interfaces.IWorld) {
+func (c *Consensus) RetrieveBlockBodiesEvent(node interfaces.INode, requestId int, hashes []string, senderId string, world interfaces.IWorld) {
 	if node.IsOnline() {
 		bodies := node.Consensus().RetrieveBodies(node, node.Ledger(), hashes)
 		for _, body := range bodies {
 			node.Consensus().MarkBlockSeen(node, body.BlockHash(), senderId)
 		}
-		if len(bodies) != 0 {
-			node.Network().SendBlockBodies(node, world.Nodes()[senderId], world, bodies)
+		if len(bodies) != 0 || RequestTimeoutActive(world) {
+			node.Network().SendBlockBodies(node, world.Nodes()[senderId], world, requestId, bodies)
 		}
 	}
 }
 
-func (c *Consensus) ReceivedBlockBodiesEvent(node interfaces.INode, bodies []interfaces.IBlockBody, senderId string, world interfaces.IWorld) {
-	if node.IsOnline() {
+func (c *Consensus) ReceivedBlockBodiesEvent(node interfaces.INode, requestId int, bodies []interfaces.IBlockBody, senderId string, world interfaces.IWorld) {
+	if request := node.Consensus().Requests().Get(requestId); request != nil && isSyncRequest(request) {
+		if node.IsOnline() {
+			node.Consensus().Sync().ReceivedBodies(node, request, bodies, world)
+		}
+	} else if node.IsOnline() {
 		for _, body := range bodies {
 			if header, ok := node.Consensus().RetrievingBodies()[body.BlockHash()]; ok {
 				delete(node.Consensus().RetrievingBodies(), body.BlockHash())
@@ -213,38 +282,159 @@ func (c *Consensus) ReceivedBlockBodiesEvent(node interfaces.INode, bodies []int
 			}
 		}
 	}
+	delivered := make([]string, 0, len(bodies))
+	for _, body := range bodies {
+		delivered = append(delivered, body.BlockHash())
+	}
+	AnswerRequest(node, requestId, delivered, world)
+}
+
+// ReceivedCompactBlockEvent reconstructs the block from the tx pool, the missing txs are fetched from the sender before the block is inserted.
+func (c *Consensus) ReceivedCompactBlockEvent(node interfaces.INode, block interfaces.IBlock, senderId string, world interfaces.IWorld) {
+	if node.IsOnline() {
+		node.Consensus().MarkBlockSeen(node, block.Hash(), senderId)
+		if _, ok := node.Consensus().ReconstructingBlocks()[block.Hash()]; ok {
+			return // the missing txs are already fetched from another peer
+		}
+		missingTxIds := make([]string, 0)
+		if !node.Ledger().HasBlock(node, block.Hash()) {
+			for _, tx := range block.Body().Transactions() {
+				if !node.Ledger().KnowsQueuedTx(node, tx.Id()) {
+					missingTxIds = append(missingTxIds, tx.Id())
+				}
+			}
+		}
+		if len(missingTxIds) > 0 {
+			metrics.Counter(metrics.NameFormat(interfaces.METRIC_COMPACT_BLOCK_MISSING, node.Id()), int64(len(missingTxIds)))
+			node.Consensus().ReconstructingBlocks()[block.Hash()] = block
+			SendRequest(node, world.Nodes()[senderId], world, interfaces.REQUEST_BLOCK_TXS, block.Hash(), missingTxIds, 0)
+			return
+		}
+		node.Consensus().ReceivedBlockEvent(node, block, senderId, world)
+	}
+}
+
+func (c *Consensus) RetrieveBlockTxsEvent(node interfaces.INode, requestId int, blockHash string, txIds []string, senderId string, world interfaces.IWorld) {
+	if node.IsOnline() {
+		txs := make([]interfaces.ITransaction, 0, len(txIds))
+		block := node.Ledger().GetBlock(node, blockHash)
+		if block == nil && !RequestTimeoutActive(world) {
+			return // the block failed to import
+		}
+		if block != nil {
+			for _, tx := range block.Body().Transactions() {
+				if helper.ContainsString(txIds, tx.Id()) {
+					txs = append(txs, tx)
+				}
+			}
+		}
+		node.Network().SendBlockTxs(node, world.Nodes()[senderId], world, requestId, blockHash, txs)
+	}
+}
+
+func (c *Consensus) ReceivedBlockTxsEvent(node interfaces.INode, requestId int, blockHash string, txs []interfaces.ITransaction, senderId string, world interfaces.IWorld) {
+	// an empty response is sent by a peer without the block, the txs are requested from another peer then
+	if block, ok := node.Consensus().ReconstructingBlocks()[blockHash]; ok && len(txs) > 0 {
+		delete(node.Consensus().ReconstructingBlocks(), blockHash)
+		if node.IsOnline() {
+			node.Consensus().ReceivedBlockEvent(node, block, senderId, world)
+		}
+	}
+	AnswerRequest(node, requestId, txIds(txs), world)
+}
+
+// RequestTimeoutEvent counts the timeout against the peer and re-sends the hashes still missing to another peer.
+func (c *Consensus) RequestTimeoutEvent(node interfaces.INode, requestId int, world interfaces.IWorld) {
+	request := node.Consensus().Requests().Remove(requestId)
+	if request == nil {
+		return // answered in time
+	}
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_REQUEST_TIMEOUT, node.Id()), 1)
+	logger.Audit(node.Id(), "REQUEST_TIMEOUT", requestLogId(request), fmt.Sprintf("%v:%v", request.Kind(), request.PeerId()), node.Time())
+	if node.IsOnline() {
+		penalizePeer(node, request.PeerId(), world)
+	}
+	if missing := missingHashes(node, request, nil); len(missing) > 0 {
+		retryRequest(node, request, missing, world)
+	}
+}
+
+// RejoinEvent retrieves the head with the highest total difficulty the peers tell in their status, like the eth handshake.
+// A head far ahead is synced, otherwise its missing ancestors are retrieved as parents of the future block. Mining
+// restarts on the old head meanwhile.
+func (c *Consensus) RejoinEvent(node interfaces.INode, world interfaces.IWorld) {
+	if node.IsOnline() {
+		var best interfaces.IBlock
+		var bestPeer interfaces.INode
+		bestTd := 0
+		for _, peer := range node.Peers() {
+			head := peer.Ledger().Head(peer)
+			if head == nil {
+				continue
+			}
+			if td := head.TotalDifficulty(); best == nil || td > bestTd {
+				best, bestPeer, bestTd = head, peer, td
+			}
+		}
+		if best != nil && !StartSync(node, bestPeer, best, world) && !node.Ledger().HasBlock(node, best.Hash()) && !node.Consensus().RetrievingHeaders()[best.Hash()] {
+			logger.Audit(node.Id(), "REJOIN_SYNC", best.Hash(), bestPeer.Id(), node.Time())
+			node.Consensus().MarkBlockSeen(node, best.Hash(), bestPeer.Id())
+			node.Consensus().RetrievingHeaders()[best.Hash()] = true
+			SendRequest(node, bestPeer, world, interfaces.REQUEST_HEADERS, best.Hash(), []string{best.Hash()}, 1)
+		}
+		node.Consensus().MineBlock(node.Ledger(), node, world)
+	}
+}
+
+// SlotEvent is not used as proof of work has no slots.
+func (c *Consensus) SlotEvent(node interfaces.INode, slot int, attest bool, world interfaces.IWorld) {
+}
+
+// ReceivedAttestationsEvent ignores attestations as proof of work has no validators.
+func (c *Consensus) ReceivedAttestationsEvent(node interfaces.INode, attestations []interfaces.IAttestation, senderId string, world interfaces.IWorld) {
 }
 
 func (c *Consensus) InsertBlock(block interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld, peerId string, evTime int64) (newHead bool, ok bool) {
 	startTime := node.Time()
+	retrieveAncestors := retrievesAncestors(world)
 	switch {
 	case block.Header().Number() > ledger.Length(node): // ledger.Length() == headBlock number + 1
-		node.Consensus().FutureBlockQueue()[block.ParentHash()] = block
-		node.Consensus().FutureBlockQueueSenderId()[block.Hash()] = peerId
+		QueueFutureBlock(block, node, peerId, world)
 		logger.Audit(node.Id(), "FUTURE_BLOCK", block.Hash(), "", node.Time())
+		if retrieveAncestors {
+			retrieveParent(block, node, peerId, world)
+		}
 		return false, false
-	case block.Header().Number()+int(world.SimConfig().MaxUncleDist()) < ledger.Length(node)-1:
-		// block should be dismissed because it's too old
+	case block.Header().Number()+int(world.SimConfig().MaxUncleDist()) < ledger.Length(node)-1 &&
+		!(retrieveAncestors && len(node.Consensus().FutureBlocks().Children(block.Hash())) > 0):
+		// block should be dismissed because it's too old (and not the retrieved ancestor of a queued block)
 		logger.Audit(node.Id(), "BLOCK_OLD", block.Hash(), "", node.Time())
 		return false, false
 	case ledger.HasBlock(node, block.Hash()):
 		// block should be dismissed because already in chain
 		logger.Audit(node.Id(), "BLOCK_KNOWN", block.Hash(), "", node.Time())
 		return false, false
-	case !ledger.HasBlock(node, block.ParentHash()):
+	case !ledger.HasBlock(node, block.ParentHash()) && !retrieveAncestors:
 		// block should be dismissed because unknown parent and not future
 		logger.Audit(node.Id(), "UNKNOWN_PARENT", block.Hash(), "", node.Time())
 		return false, false
+	case !ledger.HasBlock(node, block.ParentHash()):
+		// block is on an unknown fork (i.e. of the other side of a network partition), retrieve its ancestors first
+		QueueFutureBlock(block, node, peerId, world)
+		logger.Audit(node.Id(), "UNKNOWN_PARENT", block.Hash(), "", node.Time())
+		retrieveParent(block, node, peerId, world)
+		return false, false
 	default:
 		if peerId != node.Id() { // self called with possible uncle block otherwise
 			_, err := node.Consensus().VerifyHeader(block, ledger, world, node, false)
 			switch err {
 			case nil:
-				broadcastPropagateTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, true, node.Id())
+				broadcastPropagateTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, world, true, node.Id())
 				node.Network().BroadcastBlock(block, node, world, broadcastPropagateTargets...)
-			// not modelled
-			/*case interfaces.ErrFutureBlock:
-			// do nothing*/
+			case interfaces.ErrFutureBlock:
+				// keep the peer and retry the block at its timestamp
+				ScheduleFutureBlock(block, node, peerId, world)
+				return false, false
 			default:
 				logger.Audit(node.Id(), "INVALID_HEADER", block.Hash(), err.Error(), node.Time())
 				node.Network().DropPeer(node, peerId, world)
@@ -258,7 +448,7 @@ func (c *Consensus) InsertBlock(block interfaces.IBlock, node interfaces.INode,
 			return false, false
 		}
 		if peerId != node.Id() { // self called with possible uncle block otherwise
-			broadcastTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, false, node.Id())
+			broadcastTargets := node.Consensus().BroadcastReceivedBlockTargets(node, block, world, false, node.Id())
 			node.Network().BroadcastBlockHash(block.Hash(), block.Header().Number(), node, world, broadcastTargets...)
 			if newHead {
 				if newEvent := world.Queue().DeleteOneOfTypeForNode(interfaces.NEW_BLOCK_EVENT, node); newEvent != nil {
@@ -268,17 +458,8 @@ func (c *Consensus) InsertBlock(block interfaces.IBlock, node interfaces.INode,
 			}
 		}
 
-		_, containsHash := node.Consensus().FutureBlockQueue()[block.Hash()]
-		if containsHash {
-			// import future blocks after successful block import
-			logger.Audit(node.Id(), "IMPORT_FUTURE", node.Consensus().FutureBlockQueue()[block.Hash()].Hash(), "", node.Time())
-			blockToInsert := node.Consensus().FutureBlockQueue()[block.Hash()]
-			_, ok := node.Consensus().InsertBlock(blockToInsert, node, ledger, world, node.Consensus().FutureBlockQueueSenderId()[blockToInsert.Hash()], -1)
-			if ok {
-				delete(node.Consensus().FutureBlockQueue(), block.Hash())
-				delete(node.Consensus().FutureBlockQueueSenderId(), blockToInsert.Hash())
-			}
-		}
+		// import future blocks after successful block import
+		ImportQueuedChildren(block, node, ledger, world)
 
 		metrics.Timer(interfaces.METRIC_BLOCK_INSERT.String(), ti.Duration(node.Time()-startTime))
 		if peerId == node.Id() { // self called with possible uncle block
@@ -297,9 +478,274 @@ func (c *Consensus) InsertBlock(block interfaces.IBlock, node interfaces.INode,
 	}
 }
 
+// QueueFutureBlock queues a block that cannot be imported yet, if the queue is full (limits maxFutureBlocks) the oldest block is dismissed.
+// It returns if the block was not queued before.
+func QueueFutureBlock(block interfaces.IBlock, node interfaces.INode, peerId string, world interfaces.IWorld) bool {
+	added, dropped := node.Consensus().FutureBlocks().Add(block, peerId, world.SimConfig().Limits()["maxFutureBlocks"])
+	if dropped != nil {
+		logger.Audit(node.Id(), "FUTURE_DISMISSED", dropped.Hash(), "", node.Time())
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_FUTURE_DISMISSED, node.Id()), 1)
+	}
+	return added
+}
+
+// ScheduleFutureBlock queues a block with a timestamp too far ahead and retries its import when the node's clock reaches the timestamp minus futureBlockTime.
+func ScheduleFutureBlock(block interfaces.IBlock, node interfaces.INode, peerId string, world interfaces.IWorld) {
+	logger.Audit(node.Id(), "FUTURE_TIMESTAMP", block.Hash(), "", node.Time())
+	if !QueueFutureBlock(block, node, peerId, world) {
+		return // already scheduled
+	}
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_FUTURE_QUEUED, node.Id()), 1)
+	// the drift of the local clock until then is neglected, a block retried too early is queued again
+	retryTime := node.Time() + (block.Header().Time()-int64(world.SimConfig().Limits()["futureBlockTime"]))*1000000000 - node.ClockTime() // block time is in seconds
+	if retryTime < node.Time() {
+		retryTime = node.Time()
+	}
+	world.Queue().Add(events.NewFutureBlockEvent(event.NewEvent(retryTime, node.Id(), interfaces.FUTURE_BLOCK_EVENT), block.Hash()))
+}
+
+// ImportQueuedChildren imports the queued blocks whose parent was imported.
+func ImportQueuedChildren(block interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld) {
+	queue := node.Consensus().FutureBlocks()
+	for _, child := range queue.Children(block.Hash()) {
+		senderId := queue.SenderId(child.Hash())
+		queue.Remove(child.Hash())
+		logger.Audit(node.Id(), "IMPORT_FUTURE", child.Hash(), "", node.Time())
+		node.Consensus().InsertBlock(child, node, ledger, world, senderId, -1)
+	}
+}
+
+// retrievesAncestors returns if the missing parents of received blocks are retrieved, which is needed to import the fork
+// of the other side of a network partition and with the sync mode (limits syncThreshold) only.
+// Otherwise blocks with an unknown parent are dismissed and future blocks wait until their parent is announced.
+func retrievesAncestors(world interfaces.IWorld) bool {
+	_, syncMode := world.SimConfig().Limits()["syncThreshold"]
+	return world.SimConfig().PartitionActive() || syncMode
+}
+
+// retrieveParent requests the header of the unknown parent of a block from the peer the block was received from.
+func retrieveParent(block interfaces.IBlock, node interfaces.INode, peerId string, world interfaces.IWorld) {
+	parentHash := block.ParentHash()
+	peer, ok := world.Nodes()[peerId]
+	if !ok || peerId == node.Id() || node.Ledger().HasBlock(node, parentHash) || node.Consensus().Sync().Active() {
+		return // a sync imports the missing ancestors
+	}
+	if isRetrieving, ok := node.Consensus().RetrievingHeaders()[parentHash]; ok && isRetrieving {
+		return
+	}
+	if _, ok := node.Consensus().RetrievingBodies()[parentHash]; ok {
+		return
+	}
+	node.Consensus().RetrievingHeaders()[parentHash] = true
+	SendRequest(node, peer, world, interfaces.REQUEST_HEADERS, parentHash, []string{parentHash}, 1)
+}
+
+// RequestTimeoutActive returns if requests time out (limits requestTimeout), a peer then answers a request it cannot
+// serve with an empty response as it would be penalized otherwise.
+func RequestTimeoutActive(world interfaces.IWorld) bool {
+	_, ok := world.SimConfig().Limits()["requestTimeout"]
+	return ok
+}
+
+// SendRequest retrieves the hashes from the peer with a new request id (eth/66), headers are retrieved from blockHash on
+// and the txs of a compact block by the block hash. An unanswered request times out after limits requestTimeout (millis).
+func SendRequest(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, kind string, blockHash string, hashes []string, num int) {
+	sendRequest(node, peer, world, kind, blockHash, hashes, num, 0)
+}
+
+func sendRequest(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, kind string, blockHash string, hashes []string, num int, retries int) {
+	var request interfaces.IRequest
+	if RequestTimeoutActive(world) {
+		request = node.Consensus().Requests().Add(kind, peer.Id(), blockHash, hashes, num, retries)
+	} else {
+		// fire and forget, the request is not stored as peers do not answer requests they cannot serve
+		request = node.Consensus().Requests().New(kind, peer.Id(), blockHash, hashes, num, retries)
+	}
+	switch kind {
+	case interfaces.REQUEST_HEADERS:
+		node.Network().RetrieveBlockHeaders(node, peer, world, request.Id(), blockHash, num, false, 0)
+	case interfaces.REQUEST_SKELETON:
+		node.Network().RetrieveBlockHeaders(node, peer, world, request.Id(), blockHash, num, true, syncBatch(world)-1)
+	case interfaces.REQUEST_SYNC_HEADERS:
+		node.Network().RetrieveBlockHeaders(node, peer, world, request.Id(), blockHash, num, true, 0)
+	case interfaces.REQUEST_SYNC_BODIES:
+		node.Network().RetrieveBlockBodies(node, peer, world, request.Id(), hashes)
+	case interfaces.REQUEST_BODIES:
+		node.Network().RetrieveBlockBodies(node, peer, world, request.Id(), hashes)
+	case interfaces.REQUEST_TXS:
+		node.Network().RetrieveTxs(hashes, node, peer, world, request.Id())
+	case interfaces.REQUEST_BLOCK_TXS:
+		node.Network().RetrieveBlockTxs(node, peer, world, request.Id(), blockHash, hashes)
+	}
+	if RequestTimeoutActive(world) {
+		timeoutTime := node.Time() + int64(world.SimConfig().Limits()["requestTimeout"])*1000000 // millis to nanos
+		world.Queue().Add(events.NewRequestTimeoutEvent(event.NewEvent(timeoutTime, node.Id(), interfaces.REQUEST_TIMEOUT_EVENT), request.Id()))
+	}
+}
+
+// AnswerRequest removes the answered request, the hashes the response did not deliver are re-sent to another peer.
+// Late and unsolicited responses (request id 0) are ignored.
+func AnswerRequest(node interfaces.INode, requestId int, delivered []string, world interfaces.IWorld) {
+	request := node.Consensus().Requests().Remove(requestId)
+	if request == nil {
+		return
+	}
+	node.Consensus().Requests().ResetTimeouts(request.PeerId())
+	if !RequestTimeoutActive(world) {
+		return // fire and forget
+	}
+	if missing := missingHashes(node, request, delivered); len(missing) > 0 {
+		retryRequest(node, request, missing, world)
+	}
+}
+
+// missingHashes returns the hashes of the request that were not delivered and the node is still waiting for.
+func missingHashes(node interfaces.INode, request interfaces.IRequest, delivered []string) []string {
+	missing := make([]string, 0, len(request.Hashes()))
+	if isSyncRequest(request) {
+		for _, h := range node.Consensus().Sync().Missing(request) {
+			if !helper.ContainsString(delivered, h) {
+				missing = append(missing, h)
+			}
+		}
+		return missing
+	}
+	for _, h := range request.Hashes() {
+		if helper.ContainsString(delivered, h) {
+			continue
+		}
+		switch request.Kind() {
+		case interfaces.REQUEST_HEADERS:
+			if node.Consensus().RetrievingHeaders()[h] && !node.Ledger().HasBlock(node, h) {
+				missing = append(missing, h)
+			}
+		case interfaces.REQUEST_BODIES:
+			if _, ok := node.Consensus().RetrievingBodies()[h]; ok {
+				missing = append(missing, h)
+			}
+		case interfaces.REQUEST_TXS:
+			if !node.Ledger().KnowsQueuedTx(node, h) {
+				missing = append(missing, h)
+			}
+		case interfaces.REQUEST_BLOCK_TXS:
+			if _, ok := node.Consensus().ReconstructingBlocks()[request.BlockHash()]; ok {
+				missing = append(missing, h)
+			}
+		}
+	}
+	return missing
+}
+
+// retryRequest re-sends the missing hashes to the first peer that announced them, at most limits requestRetries times.
+// Otherwise the hashes are no longer marked as retrieving, so the next announcement of a hash retrieves it again.
+func retryRequest(node interfaces.INode, request interfaces.IRequest, missing []string, world interfaces.IWorld) {
+	if node.IsOnline() && request.Retries() < world.SimConfig().Limits()["requestRetries"] {
+		if peer := announcingPeer(node, request, missing); peer != nil {
+			metrics.Counter(metrics.NameFormat(interfaces.METRIC_REQUEST_RETRY, node.Id()), 1)
+			logger.Audit(node.Id(), "REQUEST_RETRY", requestLogId(request), fmt.Sprintf("%v:%v", request.Kind(), peer.Id()), node.Time())
+			sendRequest(node, peer, world, request.Kind(), request.BlockHash(), missing, request.Num(), request.Retries()+1)
+			return
+		}
+	}
+	logger.Audit(node.Id(), "REQUEST_ABANDONED", requestLogId(request), request.Kind(), node.Time())
+	switch request.Kind() {
+	case interfaces.REQUEST_HEADERS:
+		for _, h := range missing {
+			delete(node.Consensus().RetrievingHeaders(), h)
+		}
+	case interfaces.REQUEST_BODIES:
+		for _, h := range missing {
+			delete(node.Consensus().RetrievingBodies(), h)
+		}
+	case interfaces.REQUEST_BLOCK_TXS:
+		delete(node.Consensus().ReconstructingBlocks(), request.BlockHash())
+	case interfaces.REQUEST_SKELETON, interfaces.REQUEST_SYNC_HEADERS, interfaces.REQUEST_SYNC_BODIES:
+		node.Consensus().Sync().Abort(node, "request abandoned")
+	}
+}
+
+// announcingPeer returns the first peer other than the requested one that has seen the origin of the headers,
+// the block of the block txs or all missing bodies or txs. For a sync the peer's head has to reach the missing blocks.
+func announcingPeer(node interfaces.INode, request interfaces.IRequest, missing []string) interfaces.INode {
+	if isSyncRequest(request) {
+		for _, peer := range node.Peers() {
+			if peer.Id() == request.PeerId() {
+				continue
+			}
+			has := true
+			for _, h := range missing {
+				has = has && node.Consensus().Sync().PeerHas(peer, h)
+			}
+			if has {
+				return peer
+			}
+		}
+		return nil
+	}
+	seen := node.Consensus().BlockSeen()
+	if request.Kind() == interfaces.REQUEST_TXS {
+		seen = node.Consensus().TxSeen()
+	}
+	if request.Kind() == interfaces.REQUEST_HEADERS || request.Kind() == interfaces.REQUEST_BLOCK_TXS {
+		missing = []string{request.BlockHash()}
+	}
+	for _, peer := range node.Peers() {
+		if peer.Id() == request.PeerId() {
+			continue
+		}
+		announced := true
+		for _, h := range missing {
+			if !seen[h][peer.Id()] {
+				announced = false
+				break
+			}
+		}
+		if announced {
+			return peer
+		}
+	}
+	return nil
+}
+
+// penalizePeer counts an unanswered request of the peer, the peer is dropped after limits maxPeerTimeouts consecutive timeouts.
+func penalizePeer(node interfaces.INode, peerId string, world interfaces.IWorld) {
+	timeouts := node.Consensus().Requests().AddTimeout(peerId)
+	maxTimeouts := world.SimConfig().Limits()["maxPeerTimeouts"]
+	if maxTimeouts == 0 || timeouts < maxTimeouts {
+		return
+	}
+	node.Consensus().Requests().ResetTimeouts(peerId)
+	for _, peer := range node.Peers() {
+		if peer.Id() == peerId {
+			logger.Audit(node.Id(), "PEER_TIMEOUT", "", peerId, node.Time())
+			node.Network().DropPeer(node, peerId, world)
+			return
+		}
+	}
+}
+
+func isSyncRequest(request interfaces.IRequest) bool {
+	kind := request.Kind()
+	return kind == interfaces.REQUEST_SKELETON || kind == interfaces.REQUEST_SYNC_HEADERS || kind == interfaces.REQUEST_SYNC_BODIES
+}
+
+func txIds(txs []interfaces.ITransaction) []string {
+	ids := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		ids = append(ids, tx.Id())
+	}
+	return ids
+}
+
+// requestLogId identifies the request in the audit log by its block or its first hash.
+func requestLogId(request interfaces.IRequest) string {
+	if request.BlockHash() != "" {
+		return request.BlockHash()
+	}
+	return request.Hashes()[0]
+}
+
 func (c *Consensus) InsertToSidechain(blocks []interfaces.IBlock, headerErrors []error, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld) (newHead bool, ok bool) {
-	localTd := node.Consensus().TotalDifficulty(node, ledger.Head(node).Hash(), ledger)
-	externalTd := 0
 	var lastBlock interfaces.IBlock
 	for i, block := range blocks {
 		err := headerErrors[i]
@@ -308,10 +754,7 @@ func (c *Consensus) InsertToSidechain(blocks []interfaces.IBlock, headerErrors [
 			if err == interfaces.ErrPrunedAncestor {
 				lastBlock = block
 				logger.Audit(node.Id(), "PRUNED_ANCESTOR", block.Hash(), "", node.Time())
-				if ledger.HasBlock(node, block.Hash()) {
-					externalTd = node.Consensus().TotalDifficulty(node, block.Hash(), ledger)
-				} else {
-					externalTd = node.Consensus().TotalDifficulty(node, block.Header().ParentHash(), ledger) + block.Header().Difficulty()
+				if !ledger.HasBlock(node, block.Hash()) {
 					node.Consensus().WriteBlock(block, ledger, node, "SIDECHAIN_")
 				}
 			} else {
@@ -323,6 +766,7 @@ func (c *Consensus) InsertToSidechain(blocks []interfaces.IBlock, headerErrors [
 		logger.Audit(node.Id(), "SIDECHAIN_NO_LAST_BLOCK", "", "", node.Time())
 		return false, false
 	}
+	localTd, externalTd := node.Consensus().ForkChoiceWeights(node, ledger, world, ledger.Head(node), lastBlock)
 	reorg := node.Consensus().CheckReorg(localTd, externalTd, lastBlock, ledger.Head(node), node)
 	if !reorg {
 		logger.Audit(node.Id(), "SIDECHAIN_TD_LOW", "", "", node.Time())
@@ -383,9 +827,11 @@ func (c *Consensus) InsertToChain(blocks []interfaces.IBlock, node interfaces.IN
 			return newHead, ok
 		case err == interfaces.ErrUnknownAncestor:
 			return false, false
-		// not modelled
-		//case err == interfaces.ErrFutureBlock:
-		//node.Consensus().futureBlockQueue[block.ParentHash()] = block
+		case err == interfaces.ErrFutureBlock:
+			// only reachable for blocks not passed through InsertBlock, these have no sender to retry with
+			logger.Audit(node.Id(), "FUTURE_TIMESTAMP", block.Hash(), "", node.Time())
+			metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_FUTURE_DISMISSED, node.Id()), 1)
+			return false, false
 		case err != nil:
 			logger.Audit(node.Id(), "UNKNOWN_BLOCK_ERROR", block.Hash(), "", node.Time())
 			return false, false
@@ -399,8 +845,7 @@ func (c *Consensus) InsertToChain(blocks []interfaces.IBlock, node interfaces.IN
 				// simply appending to head
 				node.Consensus().AppendBlock(block, ledger, node, "")
 			case ledger.CurrentHasBlock(node, block.ParentHash()):
-				localTd := node.Consensus().TotalDifficulty(node, ledger.Head(node).Hash(), ledger)
-				externalTd := node.Consensus().TotalDifficulty(node, block.ParentHash(), ledger) + block.Header().Difficulty() // already verified here
+				localTd, externalTd := node.Consensus().ForkChoiceWeights(node, ledger, world, ledger.Head(node), block)
 				reorg := node.Consensus().CheckReorg(localTd, externalTd, block, ledger.Head(node), node)
 				if reorg {
 					ok := node.Consensus().ReorgChain(block, ledger, node, world, "")
@@ -420,6 +865,57 @@ func (c *Consensus) InsertToChain(blocks []interfaces.IBlock, node interfaces.IN
 	return true, true
 }
 
+func (c *Consensus) ForkChoiceWeights(node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld, currentHead interfaces.IBlock, block interfaces.IBlock) (localWeight int, externalWeight int) {
+	switch world.SimConfig().ForkChoice() {
+	case interfaces.FORK_CHOICE_LONGEST_CHAIN:
+		return currentHead.Header().Number(), block.Header().Number()
+	case interfaces.FORK_CHOICE_GHOST:
+		return ghostWeights(node, ledger, currentHead, block)
+	default:
+		// difficulty of the block is already verified here
+		return node.Consensus().TotalDifficulty(node, currentHead.Hash(), ledger), node.Consensus().TotalDifficulty(node, block.ParentHash(), ledger) + block.Header().Difficulty()
+	}
+}
+
+// ghostWeights compares the subtrees of both chains below their fork point,
+// the weight of a subtree is the difficulty of all known blocks in it including siblings and uncles.
+func ghostWeights(node interfaces.INode, ledger interfaces.ILedger, currentHead interfaces.IBlock, block interfaces.IBlock) (localWeight int, externalWeight int) {
+	// find the children of the fork point on both chains
+	local, external := currentHead, block
+	for local != nil && external != nil && local.ParentHash() != external.ParentHash() {
+		if local.Header().Number() >= external.Header().Number() {
+			local = ledger.GetBlock(node, local.ParentHash())
+		} else {
+			external = ledger.GetBlock(node, external.ParentHash())
+		}
+	}
+	if local == nil || external == nil {
+		return currentHead.TotalDifficulty(), block.TotalDifficulty()
+	}
+	if local.Hash() == external.Hash() {
+		// one block is the ancestor of the other
+		return currentHead.Header().Number(), block.Header().Number()
+	}
+
+	unwritten := block
+	if ledger.HasBlock(node, block.Hash()) {
+		unwritten = nil
+	}
+	return subtreeWeight(node, ledger, local, unwritten), subtreeWeight(node, ledger, external, unwritten)
+}
+
+// subtreeWeight sums the difficulty of the root and its descendants in the ledger including the block not written yet.
+func subtreeWeight(node interfaces.INode, ledger interfaces.ILedger, root interfaces.IBlock, unwritten interfaces.IBlock) int {
+	weight := root.Header().Difficulty()
+	for _, child := range ledger.Children(node, root.Hash()) {
+		weight += subtreeWeight(node, ledger, child, unwritten)
+	}
+	if unwritten != nil && unwritten.ParentHash() == root.Hash() {
+		weight += subtreeWeight(node, ledger, unwritten, nil)
+	}
+	return weight
+}
+
 func (c *Consensus) CheckReorg(localTd int, externalTd int, block interfaces.IBlock, currentHead interfaces.IBlock, node interfaces.INode) bool {
 	if localTd > externalTd {
 		return false
@@ -516,12 +1012,12 @@ func (c *Consensus) VerifyHeader(block interfaces.IBlock, ledger interfaces.ILed
 	if !ledger.HasBlock(node, block.ParentHash()) {
 		return timeToAdd, interfaces.ErrUnknownAncestor
 	}
-	// not modelled
-	/*if !isUncle {
-		if block.Header().Time() > (time + 15000000000) {
-			return interfaces.ErrFutureBlock
+	// block timestamp (seconds) must not be too far ahead of the local clock (nanos)
+	if maxFutureTime, ok := world.SimConfig().Limits()["futureBlockTime"]; ok && !isUncle {
+		if block.Header().Time() > node.ClockTime()/1000000000+int64(maxFutureTime) {
+			return timeToAdd, interfaces.ErrFutureBlock
 		}
-	}*/
+	}
 	// subsequent block has to have a timestamp of at least one second more than parent
 	if block.Header().Time() < ledger.GetBlock(node, block.ParentHash()).Header().Time()+1 {
 		return timeToAdd, interfaces.ErrOlderBlock
@@ -535,6 +1031,9 @@ func (c *Consensus) VerifyHeader(block interfaces.IBlock, ledger interfaces.ILed
 			block.SetTotalDifficulty(ledger.GetBlock(node, block.ParentHash()).TotalDifficulty() + block.Header().Difficulty())
 		}
 	}
+	if node.Consensus().CalcBaseFee(ledger.GetBlock(node, block.ParentHash()).Header(), world) != block.Header().BaseFee() {
+		return timeToAdd, interfaces.ErrInvalidHeader
+	}
 	// check gas limit/usage
 	if block.Header().GasUsed() > block.Header().GasLimit() {
 		return timeToAdd, interfaces.ErrInvalidHeader
@@ -638,8 +1137,22 @@ func (c *Consensus) VerifyState(block interfaces.IBlock, node interfaces.INode,
 		}
 	}
 
+	// with account state the txs are executed on the state after the parent instead of being checked against the head
+	accounts := node.Ledger().AccountsAt(node, block.ParentHash())
+	if node.Ledger().Accounts() != nil && accounts == nil {
+		return false
+	}
+
 	for _, tx := range block.Body().Transactions() {
-		invalidTxFound = !node.Consensus().VerifyTx(tx, node)
+		if accounts != nil {
+			node.IncrementTime(random.BaseTxVerification(node.HashPower(), node.CpuPower()))
+			invalidTxFound = !tx.IsValid() || accounts.CheckTx(tx, block.Header().BaseFee()) != nil
+			if !invalidTxFound {
+				accounts.ApplyTx(tx, block.Header().MinerId(), block.Header().BaseFee())
+			}
+		} else {
+			invalidTxFound = !node.Consensus().VerifyTx(tx, node)
+		}
 		node.IncrementTime(random.TxStateComputation(tx.GasUsed(), node.CpuPower(), tx.SpecialTxStateComputation()))
 
 		if checkPastTx && !invalidTxFound {
@@ -659,16 +1172,37 @@ func (c *Consensus) VerifyState(block interfaces.IBlock, node interfaces.INode,
 func (c *Consensus) VerifyTx(tx interfaces.ITransaction, node interfaces.INode) (ok bool) {
 	node.IncrementTime(random.BaseTxVerification(node.HashPower(), node.CpuPower()))
 	// check tx size
-	// check sender nonce
 	// check signed
 	// check gas price
 	// check gas > currentMaxGas
-	// check sender balance high enough
 	// check tx gas higher than min gas (21000)
-	return tx.IsValid()
+	if !tx.IsValid() {
+		return false
+	}
+	// check sender nonce and balance high enough if account state is simulated
+	return verifyTxAgainstHead(tx, node) == nil
+}
+
+// verifyTxAgainstHead checks the nonce and the balance of the sender in the state of the head,
+// txs with a nonce gap are kept as queued txs by the tx pool.
+func verifyTxAgainstHead(tx interfaces.ITransaction, node interfaces.INode) error {
+	accounts := node.Ledger().Accounts()
+	if accounts == nil {
+		return nil
+	}
+	if tx.Nonce() < accounts.Nonce(tx.SenderId()) {
+		return interfaces.ErrNonceTooLow
+	}
+	if ledg.MaxTxCost(tx) > accounts.Balance(tx.SenderId()) {
+		return interfaces.ErrInsufficientFunds
+	}
+	return nil
 }
 
 func (c *Consensus) CalcDifficulty(parentHeader interfaces.IBlockHeader, time int64, world interfaces.IWorld) int {
+	if world.SimConfig().Difficulty() == interfaces.DIFFICULTY_ETHEREUM {
+		return ethereumDifficulty(parentHeader, time, world.SimConfig())
+	}
 	// simply giving higher difficulty to blocks that were created quicker
 	// blocks with uncles have also higher difficulty
 	/*diffS := time - parentHeader.Time()
@@ -716,29 +1250,84 @@ func (c *Consensus) CalcDifficulty(parentHeader interfaces.IBlockHeader, time in
 		x = 131072
 	}*/
 
-	/*// leaving bomb delay out of simulation
-	bombDelayFromParent := world.SimConfig().BombDelay() - 1
-	var fakeBlockNumber int64 = 0
-	if int64(parentHeader.Number()) >= int64(bombDelayFromParent) {
-		fakeBlockNumber = int64(parentHeader.Number()) - int64(bombDelayFromParent)
-	}
-	periodCount := fakeBlockNumber
-	periodCount = periodCount / 100000
-	if periodCount > 1 {
-		y = periodCount - 2
-		y = int64(math.Pow(2, float64(y)))
-		y = 2 ^ y
-		x = x + y
-	}*/
 	return int(x)
 }
 
+// ethereumDifficulty is the difficulty of Byzantium, Constantinople and Muir Glacier (differing in the bomb delay).
+// The difficulty is in MH to keep the total difficulty within int.
+func ethereumDifficulty(parentHeader interfaces.IBlockHeader, time int64, config interfaces.IConfig) int {
+	x := (time - parentHeader.Time()) / 9
+	if len(parentHeader.UncleHash()) > 0 {
+		x = 2 - x
+	} else {
+		x = 1 - x
+	}
+	if x < -99 {
+		x = -99
+	}
+	diff := int64(parentHeader.Difficulty()) + int64(parentHeader.Difficulty())/2048*x
+	if diff < 1 { // minimum difficulty of 131072 hashes
+		diff = 1
+	}
+
+	// the bomb doubles every 100000 blocks after the bomb delay
+	bombDelayFromParent := config.BombDelay() - 1
+	parentNumber := config.GenesisNumber() + uint64(parentHeader.Number())
+	if parentNumber >= bombDelayFromParent {
+		periodCount := (parentNumber - bombDelayFromParent) / 100000
+		if periodCount > 1 {
+			diff += int64(math.Min(math.Pow(2, float64(periodCount-2))/1000000, math.MaxInt64/4)) // hashes to MH
+		}
+	}
+	return int(diff)
+}
+
+// CalcBaseFee adjusts the base fee of the parent by at most 1/8 towards the gas target of half the gas limit (EIP-1559).
+func (c *Consensus) CalcBaseFee(parentHeader interfaces.IBlockHeader, world interfaces.IWorld) int {
+	if world.SimConfig().FeeMarket() != interfaces.FEE_MARKET_EIP1559 {
+		return 0
+	}
+	if parentHeader.Number() == 0 {
+		return InitialBaseFee(world.SimConfig())
+	}
+	parentBaseFee := parentHeader.BaseFee()
+	gasTarget := parentHeader.GasLimit() / interfaces.EIP1559_ELASTICITY_MULTIPLIER
+	if parentHeader.GasUsed() == gasTarget {
+		return parentBaseFee
+	}
+	// floats as base fee (wei) times gas overflows int
+	delta := float64(parentBaseFee) * float64(parentHeader.GasUsed()-gasTarget) / float64(gasTarget) / interfaces.EIP1559_BASE_FEE_CHANGE_DENOMINATOR
+	if parentHeader.GasUsed() > gasTarget {
+		return parentBaseFee + int(math.Max(delta, 1))
+	}
+	return int(math.Max(float64(parentBaseFee)+delta, 0))
+}
+
+// InitialBaseFee returns the base fee (wei) of the first blocks, limits initialBaseFee is in gwei.
+func InitialBaseFee(config interfaces.IConfig) int {
+	if config.FeeMarket() != interfaces.FEE_MARKET_EIP1559 {
+		return 0
+	}
+	if initialBaseFee, ok := config.Limits()["initialBaseFee"]; ok {
+		return initialBaseFee * 1000000000
+	}
+	return 1000000000
+}
+
+// GasTargetLimit returns the gas limit the miners aim for, twice the initial gas limit with EIP-1559.
+func GasTargetLimit(config interfaces.IConfig) int {
+	if config.FeeMarket() == interfaces.FEE_MARKET_EIP1559 {
+		return config.Limits()["initialGasLimit"] * interfaces.EIP1559_ELASTICITY_MULTIPLIER
+	}
+	return config.Limits()["initialGasLimit"]
+}
+
 func (c *Consensus) TotalDifficulty(node interfaces.INode, hash string, ledger interfaces.ILedger) int {
 	return ledger.GetBlock(node, hash).TotalDifficulty()
 }
 
-func (c *Consensus) BroadcastNewBlockTargets(node interfaces.INode, block interfaces.IBlock, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
-	numPeers := int(math.Sqrt(float64(len(node.Peers()))))
+func (c *Consensus) BroadcastNewBlockTargets(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+	numPeers := pushedPeers(world.SimConfig().BlockPropagation(), len(node.Peers()))
 	selectedPeers := make([]interfaces.INode, 0, len(node.Peers()))
 	for _, peer := range node.Peers() {
 		if _, ok := node.Consensus().BlockSeen()[block.Hash()][peer.Id()]; !ok {
@@ -758,13 +1347,13 @@ func (c *Consensus) BroadcastNewBlockTargets(node interfaces.INode, block interf
 	return
 }
 
-func (c *Consensus) BroadcastReceivedBlockTargets(node interfaces.INode, block interfaces.IBlock, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
-	targets = node.Consensus().BroadcastNewBlockTargets(node, block, propagate, excludeIds...)
+func (c *Consensus) BroadcastReceivedBlockTargets(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+	targets = node.Consensus().BroadcastNewBlockTargets(node, block, world, propagate, excludeIds...)
 	return
 }
 
-func (c *Consensus) BroadcastTxTargets(node interfaces.INode, tx interfaces.ITransaction, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
-	numPeers := int(math.Sqrt(float64(len(node.Peers()))))
+func (c *Consensus) BroadcastTxTargets(node interfaces.INode, tx interfaces.ITransaction, world interfaces.IWorld, propagate bool, excludeIds ...string) (targets []interfaces.INode) {
+	numPeers := pushedPeers(world.SimConfig().TxPropagation(), len(node.Peers()))
 	selectedPeers := make([]interfaces.INode, 0, len(node.Peers()))
 	for _, peer := range node.Peers() {
 		if _, ok := node.Consensus().TxSeen()[tx.Id()][peer.Id()]; !ok {
@@ -784,26 +1373,79 @@ func (c *Consensus) BroadcastTxTargets(node interfaces.INode, tx interfaces.ITra
 	return
 }
 
+// pushedPeers returns to how many of the peers the propagation strategy pushes the full (or compact) object, the hash is announced to the rest.
+func pushedPeers(propagation string, peers int) int {
+	switch propagation {
+	case interfaces.PROPAGATION_FULL, interfaces.PROPAGATION_COMPACT:
+		return peers
+	case interfaces.PROPAGATION_ANNOUNCE:
+		return 0
+	default:
+		return int(math.Sqrt(float64(peers)))
+	}
+}
+
 func (c *Consensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld) {
-	blockTimeStamp, miningTimeDelay := random.TimeBetweenBlocks(world.SimConfig().OverallHashPower(), node.HashPower(), ledger.Head(node).Header().Time(), node.Time())
+	blockTimeStamp, miningTimeDelay := node.Consensus().GetMiningTime(ledger.Head(node).Header(), node, world)
+	blockTimeStamp = node.Consensus().GetTimestamp(ledger.Head(node).Header(), blockTimeStamp, node, world)
 	miningTime := node.Time() + miningTimeDelay
+	block := newBlock(ledger, node, world, blockTimeStamp, true)
+	ev := events.NewNewBlockEvent(event.NewEvent(miningTime, node.Id(), interfaces.NEW_BLOCK_EVENT), block, node.Time())
+	world.Queue().Add(ev)
+}
+
+// The timestamp is taken from the local clock of the node, its drift while mining is neglected.
+func (c *Consensus) GetMiningTime(parentHeader interfaces.IBlockHeader, node interfaces.INode, world interfaces.IWorld) (blockTimeStamp int64, miningTimeDelay int64) {
+	clockTime := node.ClockTime()
+	if world.SimConfig().Difficulty() != interfaces.DIFFICULTY_ETHEREUM {
+		return random.TimeBetweenBlocks(world.SimConfig().OverallHashPower(), node.HashPower(), parentHeader.Time(), clockTime)
+	}
+	// the difficulty depends on the timestamp and changes every 9 seconds after the parent,
+	// as mining is memoryless the mining time is drawn per 9 second window with the difficulty of the window
+	for {
+		found := clockTime + miningTimeDelay
+		blockTimeStamp = found / 1000000000
+		if blockTimeStamp <= parentHeader.Time() { // timestamp must be at least one second higher than the parent
+			blockTimeStamp = parentHeader.Time() + 1
+		}
+		window := (blockTimeStamp - parentHeader.Time()) / 9
+		windowEnd := (parentHeader.Time() + (window+1)*9) * 1000000000
+		delay := random.MiningTime(node.HashPower(), float64(node.Consensus().CalcDifficulty(parentHeader, blockTimeStamp, world)))
+		// the difficulty stops falling after 100 windows
+		if found+delay < windowEnd || window > 100 || miningTimeDelay+delay >= 86400000000000 {
+			miningTimeDelay += delay
+			break
+		}
+		miningTimeDelay = windowEnd - clockTime
+	}
+	blockTimeStamp = (clockTime + miningTimeDelay) / 1000000000
+	if blockTimeStamp <= parentHeader.Time() {
+		blockTimeStamp = parentHeader.Time() + 1
+	}
+	return
+}
+
+// newBlock creates a block on top of the head with the txs of the queue (or random txs) and possibly uncles.
+func newBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld, blockTimeStamp int64, withUncles bool) interfaces.IBlock {
 	newGasLimit := node.Consensus().GetGasLimit(ledger.Head(node).Header(), world)
+	baseFee := node.Consensus().CalcBaseFee(ledger.Head(node).Header(), world)
+	pending := ledger.AccountsAt(node, ledger.HeadHash()) // nil without account state
 	txs := make([]interfaces.ITransaction, 0, 50)
 	gasAlreadyUsed := 0
 
 	if len(ledger.QueuedTxs()) > 0 {
 		localTxs, remoteTxs := ledger.SortedTxByLocalAndRemote(node)
-		localsToUse, gasUsedFromLocals := node.Consensus().GetTxsForBlock(gasAlreadyUsed, localTxs, newGasLimit)
+		localsToUse, gasUsedFromLocals := node.Consensus().GetTxsForBlock(gasAlreadyUsed, localTxs, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, localsToUse...)
 		gasAlreadyUsed += gasUsedFromLocals
-		remotesToUse, gasUsedFromRemotes := node.Consensus().GetTxsForBlock(gasAlreadyUsed, remoteTxs, newGasLimit)
+		remotesToUse, gasUsedFromRemotes := node.Consensus().GetTxsForBlock(gasAlreadyUsed, remoteTxs, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, remotesToUse...)
 		gasAlreadyUsed += gasUsedFromRemotes
 	}
 
 	// fill block with random txs if tx creation propagation is not simulated
 	if !world.SimConfig().SimulateTransactionCreation() {
-		randomsToUse, gasUsedFromRandoms := events.CreateRandomTransactionsForBlock(gasAlreadyUsed, world, newGasLimit)
+		randomsToUse, gasUsedFromRandoms := events.CreateRandomTransactionsForBlock(gasAlreadyUsed, world, newGasLimit, baseFee, pending, node.Id())
 		txs = append(txs, randomsToUse...)
 		gasAlreadyUsed += gasUsedFromRandoms
 	}
@@ -824,7 +1466,7 @@ func (c *Consensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode,
 
 	uncles := make([]interfaces.IBlockHeader, 0, 2)
 	uncleHashes := make([]string, 0, 2)
-	if len(ledger.PossibleUncles()) > 0 {
+	if withUncles && len(ledger.PossibleUncles()) > 0 {
 		localUncles := make([]interfaces.IBlockHeader, 0, 2)
 		remoteUncles := make([]interfaces.IBlockHeader, 0, 2)
 		// divide into local and remote uncles
@@ -857,11 +1499,9 @@ func (c *Consensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode,
 	}
 	uncleHash := strings.Join(uncleHashes, ",")
 	blockSize := world.SimConfig().Sizes()["header"] + world.SimConfig().Sizes()["tx"]*len(txs) + world.SimConfig().Sizes()["header"]*len(uncles)
-	header := ledg.NewBlockHeader(world.NewBlockHash(), txSha256, uncleHash, ledger.Head(node).Hash(), node.Id(), node.Consensus().CalcDifficulty(ledger.Head(node).Header(), blockTimeStamp, world), gasAlreadyUsed, newGasLimit, blockTimeStamp, ledger.Head(node).Header().Number()+1, blockSize, true)
+	header := ledg.NewBlockHeader(world.NewBlockHash(), txSha256, uncleHash, ledger.Head(node).Hash(), node.Id(), node.Consensus().CalcDifficulty(ledger.Head(node).Header(), blockTimeStamp, world), gasAlreadyUsed, newGasLimit, baseFee, blockTimeStamp, ledger.Head(node).Header().Number()+1, blockSize, true)
 	body := ledg.NewBlockBody(header.Hash(), txs, uncles, true, len(txs))
-	block := ledg.NewBlock(header, body, ledger.Head(node).TotalDifficulty()+header.Difficulty())
-	ev := events.NewNewBlockEvent(event.NewEvent(miningTime, node.Id(), interfaces.NEW_BLOCK_EVENT), block, node.Time())
-	world.Queue().Add(ev)
+	return ledg.NewBlock(header, body, ledger.Head(node).TotalDifficulty()+header.Difficulty())
 }
 
 func getUncles(possibleUncles []interfaces.IBlockHeader, world interfaces.IWorld, ledger interfaces.ILedger, node interfaces.INode, alreadyUsedUncles int) (uncles []interfaces.IBlockHeader, uncleHashes []string) {
@@ -885,10 +1525,19 @@ func getUncles(possibleUncles []interfaces.IBlockHeader, world interfaces.IWorld
 	return
 }
 
-func (c *Consensus) GetTxsForBlock(gasUsed int, txs []interfaces.ITransaction, gasLimit int) (transactions []interfaces.ITransaction, gasAmount int) {
+func (c *Consensus) GetTxsForBlock(gasUsed int, txs []interfaces.ITransaction, gasLimit int, baseFee int, pending interfaces.IAccounts, minerId string) (transactions []interfaces.ITransaction, gasAmount int) {
+	if pending != nil {
+		return getTxsByPriceAndNonce(gasUsed, txs, gasLimit, baseFee, pending, minerId)
+	}
 	transactions = make([]interfaces.ITransaction, 0)
 	gasAmount = 0
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].EffectiveTip(baseFee) > txs[j].EffectiveTip(baseFee)
+	})
 	for _, tx := range txs {
+		if tx.EffectiveTip(baseFee) < 0 {
+			break // all remaining txs cannot pay the base fee
+		}
 		if gasUsed+gasAmount+tx.GasUsed() > gasLimit {
 			break
 		}
@@ -898,28 +1547,85 @@ func (c *Consensus) GetTxsForBlock(gasUsed int, txs []interfaces.ITransaction, g
 	return transactions, gasAmount
 }
 
+// getTxsByPriceAndNonce repeatedly selects the next tx of the sender with the highest effective tip (like geth's miner),
+// ties are broken by the order of txs. Senders with a nonce gap or without enough funds are skipped.
+func getTxsByPriceAndNonce(gasUsed int, txs []interfaces.ITransaction, gasLimit int, baseFee int, pending interfaces.IAccounts, minerId string) (transactions []interfaces.ITransaction, gasAmount int) {
+	transactions = make([]interfaces.ITransaction, 0)
+	gasAmount = 0
+	senders := make([]string, 0)
+	bySender := make(map[string][]interfaces.ITransaction)
+	for _, tx := range txs {
+		if _, ok := bySender[tx.SenderId()]; !ok {
+			senders = append(senders, tx.SenderId())
+		}
+		bySender[tx.SenderId()] = append(bySender[tx.SenderId()], tx)
+	}
+	for _, sender := range senders {
+		senderTxs := bySender[sender]
+		sort.SliceStable(senderTxs, func(i, j int) bool {
+			return senderTxs[i].Nonce() < senderTxs[j].Nonce()
+		})
+	}
+	for {
+		var best interfaces.ITransaction
+		for _, sender := range senders {
+			senderTxs := bySender[sender]
+			for len(senderTxs) > 0 && senderTxs[0].Nonce() < pending.Nonce(sender) {
+				senderTxs = senderTxs[1:] // already executed or replaced
+			}
+			bySender[sender] = senderTxs
+			if len(senderTxs) == 0 || senderTxs[0].Nonce() > pending.Nonce(sender) {
+				continue
+			}
+			if best == nil || senderTxs[0].EffectiveTip(baseFee) > best.EffectiveTip(baseFee) {
+				best = senderTxs[0]
+			}
+		}
+		if best == nil || best.EffectiveTip(baseFee) < 0 {
+			break // all remaining txs cannot pay the base fee
+		}
+		if gasUsed+gasAmount+best.GasUsed() > gasLimit {
+			break
+		}
+		if pending.CheckTx(best, baseFee) != nil {
+			bySender[best.SenderId()] = nil // the following txs of the sender can't be executed either
+			continue
+		}
+		bySender[best.SenderId()] = bySender[best.SenderId()][1:]
+		pending.ApplyTx(best, minerId, baseFee)
+		transactions = append(transactions, best)
+		gasAmount += best.GasUsed()
+	}
+	return transactions, gasAmount
+}
+
+func (c *Consensus) GetTimestamp(parentHeader interfaces.IBlockHeader, minedTimestamp int64, node interfaces.INode, world interfaces.IWorld) (timestamp int64) {
+	return minedTimestamp
+}
+
 func (c *Consensus) GetGasLimit(currentHead interfaces.IBlockHeader, world interfaces.IWorld) (gasLimit int) {
 	rand := random.Uniform()
 	doIncreaseGas := rand < 0.1                  // some nodes may try to increase the limit
 	doDecreaseGas := rand > 0.9                  // some nodes may try to decrease the limit
 	maxAdaption := currentHead.GasLimit() / 1024 // maximum allowed change of gas limit
+	targetLimit := GasTargetLimit(world.SimConfig())
 	if doIncreaseGas {
 		gasLimit = currentHead.GasLimit() + maxAdaption
 	} else if doDecreaseGas {
 		gasLimit = currentHead.GasLimit() - maxAdaption
 	} else {
 		// try keep initial
-		if currentHead.GasLimit() > world.SimConfig().Limits()["initialGasLimit"] {
-			if currentHead.GasLimit()-maxAdaption > world.SimConfig().Limits()["initialGasLimit"] {
+		if currentHead.GasLimit() > targetLimit {
+			if currentHead.GasLimit()-maxAdaption > targetLimit {
 				gasLimit = currentHead.GasLimit() - maxAdaption
 			} else {
-				gasLimit = world.SimConfig().Limits()["initialGasLimit"]
+				gasLimit = targetLimit
 			}
 		} else {
-			if currentHead.GasLimit()+maxAdaption < world.SimConfig().Limits()["initialGasLimit"] {
+			if currentHead.GasLimit()+maxAdaption < targetLimit {
 				gasLimit = currentHead.GasLimit() + maxAdaption
 			} else {
-				gasLimit = world.SimConfig().Limits()["initialGasLimit"]
+				gasLimit = targetLimit
 			}
 		}
 	}
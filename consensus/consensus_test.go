@@ -0,0 +1,52 @@
+package consensus
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/ledger"
+	"ethattacksim/util/file"
+	"testing"
+)
+
+// testWorld only provides the config, other calls panic.
+type testWorld struct {
+	interfaces.IWorld
+	config interfaces.IConfig
+}
+
+func (w *testWorld) SimConfig() interfaces.IConfig {
+	return w.config
+}
+
+func TestCalcBaseFee(t *testing.T) {
+	world := &testWorld{config: &file.Config{CFeeMarket: interfaces.FEE_MARKET_EIP1559, CLimits: map[string]int{"initialBaseFee": 2}}}
+	gasLimit := 20000000 // gas target 10000000
+	tests := []struct {
+		name    string
+		number  int
+		gasUsed int
+		baseFee int
+		want    int
+	}{
+		{"genesis", 0, 0, 0, 2000000000},
+		{"at target", 5, 10000000, 1000000000, 1000000000},
+		{"full block", 5, 20000000, 1000000000, 1125000000},
+		{"empty block", 5, 0, 1000000000, 875000000},
+		{"half above target", 5, 15000000, 1000000000, 1062500000},
+		{"rises at least one wei", 5, 10000001, 8, 9},
+		{"falls to zero", 5, 0, 0, 0},
+	}
+	for _, test := range tests {
+		parent := ledger.NewBlockHeader("parent", "", "", "", "", 8, test.gasUsed, gasLimit, test.baseFee, 0, test.number, 0, true)
+		if got := NewConsensus().CalcBaseFee(parent, world); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCalcBaseFeeLegacy(t *testing.T) {
+	world := &testWorld{config: &file.Config{CFeeMarket: interfaces.FEE_MARKET_LEGACY}}
+	parent := ledger.NewBlockHeader("parent", "", "", "", "", 8, 20000000, 20000000, 1000000000, 0, 5, 0, true)
+	if got := NewConsensus().CalcBaseFee(parent, world); got != 0 {
+		t.Errorf("got %v, want 0", got)
+	}
+}
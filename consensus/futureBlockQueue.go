@@ -0,0 +1,73 @@
+package consensus
+
+import (
+	"ethattacksim/interfaces"
+	"sort"
+)
+
+// FutureBlockQueue holds the blocks that cannot be imported yet because their parent is unknown or their timestamp is too far ahead.
+// It is keyed by block hash so a parent can have several queued children, the oldest block is dropped when it is full.
+type FutureBlockQueue struct {
+	blocks    map[string]interfaces.IBlock
+	senderIds map[string]string // block hash to sender id, only for dropping peer
+	order     []string          // block hashes, oldest first
+}
+
+func NewFutureBlockQueue() interfaces.IFutureBlockQueue {
+	return &FutureBlockQueue{blocks: make(map[string]interfaces.IBlock, 20), senderIds: make(map[string]string, 20)}
+}
+
+// Add queues the block unless it is already queued, if maxBlocks (0 is unlimited) is reached the oldest block is dropped.
+func (q *FutureBlockQueue) Add(block interfaces.IBlock, senderId string, maxBlocks int) (added bool, dropped interfaces.IBlock) {
+	if _, ok := q.blocks[block.Hash()]; ok {
+		return false, nil
+	}
+	if maxBlocks > 0 && len(q.order) >= maxBlocks {
+		dropped = q.blocks[q.order[0]]
+		q.Remove(q.order[0])
+	}
+	q.blocks[block.Hash()] = block
+	q.senderIds[block.Hash()] = senderId
+	q.order = append(q.order, block.Hash())
+	return true, dropped
+}
+
+func (q *FutureBlockQueue) Get(hash string) interfaces.IBlock {
+	return q.blocks[hash]
+}
+
+func (q *FutureBlockQueue) SenderId(hash string) string {
+	return q.senderIds[hash]
+}
+
+func (q *FutureBlockQueue) Remove(hash string) {
+	if _, ok := q.blocks[hash]; !ok {
+		return
+	}
+	delete(q.blocks, hash)
+	delete(q.senderIds, hash)
+	for i, h := range q.order {
+		if h == hash {
+			q.order = append(q.order[:i:i], q.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Children returns the queued blocks with the parent, sorted by hash because of determinism.
+func (q *FutureBlockQueue) Children(parentHash string) []interfaces.IBlock {
+	var children []interfaces.IBlock
+	for _, block := range q.blocks {
+		if block.ParentHash() == parentHash {
+			children = append(children, block)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Hash() < children[j].Hash()
+	})
+	return children
+}
+
+func (q *FutureBlockQueue) Len() int {
+	return len(q.order)
+}
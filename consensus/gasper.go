@@ -0,0 +1,493 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"ethattacksim/event"
+	"ethattacksim/event/events"
+	"ethattacksim/interfaces"
+	ledg "ethattacksim/ledger"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// GasperConsensus is proof of stake with slots of fixed length and one proposer per slot picked weighted by stake.
+// Every validator attests once per epoch in a slot assigned to it, the head is chosen with LMD-GHOST starting at the
+// justified checkpoint and epoch boundary checkpoints are justified and finalized with Casper FFG.
+// All nodes are validators with their hash power used as stake. Attestations are gossiped but not included in blocks,
+// so the FFG votes are counted in the view of each node.
+type GasperConsensus struct {
+	interfaces.IConsensus
+	config           interfaces.IPosConfig
+	started          bool
+	validatorIds     []string  // sorted
+	cumulatedStakes  []float64 // per validator id for the proposer selection
+	stakes           map[string]float64
+	totalStake       float64
+	latestMessages   map[string]interfaces.IAttestation // latest attestation per validator (LMD)
+	attestationSeen  map[string]map[string]bool         // attestation id to peers
+	attestationSlots map[int][]string                   // attestation ids per slot, for pruning the seen attestations
+	prunedSlot       int
+	ffgVotes         map[checkpoint]map[checkpoint]float64 // stake per source and target checkpoint
+	ffgVoted         map[int]map[string]bool               // validators that voted per target epoch
+	justifiedSet     map[checkpoint]bool                   // justified checkpoints not older than the finalized one
+	justified        checkpoint                            // highest justified checkpoint, the root of the fork choice
+	finalized        checkpoint
+	weights          map[string]int64    // stake (rounded MH/s) of the validators whose latest message is the block or a descendant
+	weighted         map[string]string   // validator id to the block hash its stake is counted for
+	pendingWeights   map[string][]string // validator ids per block hash of latest messages not written yet
+	children         map[string][]string // block hash to hashes of the known children
+	indexed          map[string]bool
+	proposedBlocks   int
+	forkChoiceReorgs int
+}
+
+type checkpoint struct {
+	epoch int
+	hash  string
+}
+
+func NewGasperConsensus(consensus interfaces.IConsensus, config interfaces.IPosConfig) interfaces.IConsensus {
+	genesis := checkpoint{0, "GENESIS"}
+	return &GasperConsensus{IConsensus: consensus, config: config, stakes: make(map[string]float64), latestMessages: make(map[string]interfaces.IAttestation), attestationSeen: make(map[string]map[string]bool), attestationSlots: make(map[int][]string), ffgVotes: make(map[checkpoint]map[checkpoint]float64), ffgVoted: make(map[int]map[string]bool), justifiedSet: map[checkpoint]bool{genesis: true}, justified: genesis, finalized: genesis, children: make(map[string][]string), indexed: make(map[string]bool), weights: make(map[string]int64), weighted: make(map[string]string), pendingWeights: make(map[string][]string)}
+}
+
+// MineBlock starts the slots of the node, blocks are only proposed in the slots the node is proposer of.
+func (c *GasperConsensus) MineBlock(ledger interfaces.ILedger, node interfaces.INode, world interfaces.IWorld) {
+	if c.started {
+		return
+	}
+	c.started = true
+	nextSlot := int(node.Time()/c.slotNanos()) + 1
+	world.Queue().Add(events.NewSlotEvent(event.NewEvent(int64(nextSlot)*c.slotNanos(), node.Id(), interfaces.SLOT_EVENT), nextSlot, false))
+}
+
+func (c *GasperConsensus) SlotEvent(node interfaces.INode, slot int, attest bool, world interfaces.IWorld) {
+	if attest {
+		if node.IsOnline() {
+			c.attest(node, slot, world)
+		}
+		return
+	}
+	world.Queue().Add(events.NewSlotEvent(event.NewEvent(int64(slot+1)*c.slotNanos(), node.Id(), interfaces.SLOT_EVENT), slot+1, false))
+	if !node.IsOnline() {
+		return
+	}
+	c.pruneAttestations(slot)
+	if c.proposer(slot, world) == node.Id() {
+		c.propose(node, slot, world)
+	}
+	if c.attestationSlot(node.Id(), slot/c.config.SlotsPerEpoch(), world) == slot%c.config.SlotsPerEpoch() {
+		// attest a third into the slot to give the block of the slot time to propagate
+		world.Queue().Add(events.NewSlotEvent(event.NewEvent(int64(slot)*c.slotNanos()+c.slotNanos()/3, node.Id(), interfaces.SLOT_EVENT), slot, true))
+	}
+}
+
+func (c *GasperConsensus) ReceivedAttestationsEvent(node interfaces.INode, attestations []interfaces.IAttestation, senderId string, world interfaces.IWorld) {
+	if !node.IsOnline() {
+		return
+	}
+	c.initValidators(world)
+	oldestSlot := int(node.Time()/c.slotNanos()) - 2*c.config.SlotsPerEpoch()
+	forward := make([]interfaces.IAttestation, 0, len(attestations))
+	for _, attestation := range attestations {
+		_, seen := c.attestationSeen[attestation.Id()]
+		c.markAttestationSeen(attestation, senderId)
+		if !seen && attestation.Slot() >= oldestSlot {
+			if _, ok := c.stakes[attestation.AttesterId()]; ok {
+				c.onAttestation(node, attestation, world)
+				forward = append(forward, attestation)
+			}
+		}
+	}
+	if len(forward) > 0 {
+		c.broadcastAttestations(node, forward, true, world)
+	}
+}
+
+// NewBlockEvent indexes the own proposed block for the fork choice.
+func (c *GasperConsensus) NewBlockEvent(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, evTime int64) {
+	c.IConsensus.NewBlockEvent(node, block, world, evTime)
+	c.index(block, node)
+}
+
+// InsertBlock writes the block with the proof of work rules (without reorgs, see CheckReorg) and applies the fork choice afterwards.
+func (c *GasperConsensus) InsertBlock(block interfaces.IBlock, node interfaces.INode, ledger interfaces.ILedger, world interfaces.IWorld, peerId string, evTime int64) (newHead bool, ok bool) {
+	newHead, ok = c.IConsensus.InsertBlock(block, node, ledger, world, peerId, evTime)
+	if ok && c.index(block, node) {
+		if c.updateHead(node, world) {
+			newHead = true
+		}
+	}
+	return newHead, ok
+}
+
+// VerifyHeader additionally checks that the block was proposed by the proposer of its slot.
+func (c *GasperConsensus) VerifyHeader(block interfaces.IBlock, ledger interfaces.ILedger, world interfaces.IWorld, node interfaces.INode, isUncle bool) (timeConsumed int64, err error) {
+	timeConsumed, err = c.IConsensus.VerifyHeader(block, ledger, world, node, isUncle)
+	if err == nil && !isUncle {
+		if block.Header().Time()%c.config.SlotTime() != 0 || c.proposer(c.slotOf(block.Header()), world) != block.Header().MinerId() {
+			return timeConsumed, interfaces.ErrInvalidHeader
+		}
+	}
+	return timeConsumed, err
+}
+
+// CalcDifficulty gives all blocks the same weight as forks are chosen by LMD-GHOST and not by total difficulty.
+func (c *GasperConsensus) CalcDifficulty(parentHeader interfaces.IBlockHeader, time int64, world interfaces.IWorld) int {
+	return 1
+}
+
+// CheckReorg never reorgs by total difficulty, the head is set by the fork choice after the insert.
+func (c *GasperConsensus) CheckReorg(localTd int, externalTd int, block interfaces.IBlock, currentHead interfaces.IBlock, node interfaces.INode) bool {
+	return false
+}
+
+// ConsensusStats returns the justified and finalized checkpoints and the fork choice reorgs in the view of the node.
+func (c *GasperConsensus) ConsensusStats(node interfaces.INode, world interfaces.IWorld) map[string]map[string]float64 {
+	head := node.Ledger().Head(node)
+	stats := map[string]float64{
+		"justifiedEpoch":   float64(c.justified.epoch),
+		"finalizedEpoch":   float64(c.finalized.epoch),
+		"proposedBlocks":   float64(c.proposedBlocks),
+		"forkChoiceReorgs": float64(c.forkChoiceReorgs),
+		"emptySlots":       float64(c.slotOf(head.Header()) - head.Header().Number()),
+	}
+	if finalized := node.Ledger().GetBlock(node, c.finalized.hash); finalized != nil {
+		stats["finalizedNumber"] = float64(finalized.Header().Number())
+	}
+	return map[string]map[string]float64{node.Id(): stats}
+}
+
+func (c *GasperConsensus) propose(node interfaces.INode, slot int, world interfaces.IWorld) {
+	c.updateHead(node, world)
+	ledger := node.Ledger()
+	if c.slotOf(ledger.Head(node).Header()) >= slot {
+		return
+	}
+	timestamp := node.Consensus().GetTimestamp(ledger.Head(node).Header(), int64(slot)*c.config.SlotTime(), node, world)
+	block := newBlock(ledger, node, world, timestamp, false)
+	c.proposedBlocks++
+	logger.Audit(node.Id(), "BLOCK_PROPOSED", block.Hash(), fmt.Sprintf("%v", slot), node.Time())
+	world.Queue().Add(events.NewNewBlockEvent(event.NewEvent(node.Time(), node.Id(), interfaces.NEW_BLOCK_EVENT), block, node.Time()))
+}
+
+func (c *GasperConsensus) attest(node interfaces.INode, slot int, world interfaces.IWorld) {
+	c.updateHead(node, world)
+	head := node.Ledger().Head(node)
+	epoch := slot / c.config.SlotsPerEpoch()
+	target := c.checkpointBlock(node, head, epoch)
+	attestation := ledg.NewAttestation(node.Id(), slot, head.Hash(), c.justified.epoch, c.justified.hash, epoch, target.Hash())
+	c.markAttestationSeen(attestation, node.Id())
+	c.onAttestation(node, attestation, world)
+	c.broadcastAttestations(node, []interfaces.IAttestation{attestation}, false, world)
+}
+
+// broadcastAttestations sends own attestations to all peers and propagates received ones to the square root of the peers like blocks and txs.
+func (c *GasperConsensus) broadcastAttestations(node interfaces.INode, attestations []interfaces.IAttestation, propagate bool, world interfaces.IWorld) {
+	targets := make([]interfaces.INode, 0, len(node.Peers()))
+	for _, peer := range node.Peers() {
+		for _, attestation := range attestations {
+			if !c.attestationSeen[attestation.Id()][peer.Id()] {
+				targets = append(targets, peer)
+				break
+			}
+		}
+	}
+	if propagate {
+		targets = targets[:int(math.Min(math.Sqrt(float64(len(node.Peers()))), float64(len(targets))))]
+	}
+	for _, target := range targets {
+		for _, attestation := range attestations {
+			c.markAttestationSeen(attestation, target.Id())
+		}
+	}
+	node.Network().BroadcastAttestations(attestations, node, world, targets...)
+}
+
+// onAttestation updates the latest message of the attester and counts its FFG vote.
+func (c *GasperConsensus) onAttestation(node interfaces.INode, attestation interfaces.IAttestation, world interfaces.IWorld) {
+	c.initValidators(world)
+	if latest, ok := c.latestMessages[attestation.AttesterId()]; !ok || attestation.Slot() > latest.Slot() {
+		c.latestMessages[attestation.AttesterId()] = attestation
+		c.updateWeight(node, attestation.AttesterId())
+	}
+
+	if attestation.TargetEpoch() <= c.finalized.epoch {
+		return
+	}
+	if c.ffgVoted[attestation.TargetEpoch()] == nil {
+		c.ffgVoted[attestation.TargetEpoch()] = make(map[string]bool)
+	}
+	if c.ffgVoted[attestation.TargetEpoch()][attestation.AttesterId()] {
+		return
+	}
+	c.ffgVoted[attestation.TargetEpoch()][attestation.AttesterId()] = true
+	source, target := checkpoint{attestation.SourceEpoch(), attestation.SourceHash()}, checkpoint{attestation.TargetEpoch(), attestation.TargetHash()}
+	if c.ffgVotes[source] == nil {
+		c.ffgVotes[source] = make(map[checkpoint]float64)
+	}
+	c.ffgVotes[source][target] += c.stakes[attestation.AttesterId()]
+	c.processJustification(node)
+}
+
+// processJustification justifies targets with 2/3 of the stake voting from any justified checkpoint and finalizes the
+// source of such a link if the target is its direct successor epoch. The links are applied lowest target first.
+func (c *GasperConsensus) processJustification(node interfaces.INode) {
+	for {
+		var source, target *checkpoint
+		for s, targets := range c.ffgVotes {
+			if !c.justifiedSet[s] {
+				continue
+			}
+			for t, stake := range targets {
+				if t.epoch <= s.epoch || 3*stake < 2*c.totalStake {
+					continue
+				}
+				if c.justifiedSet[t] && (t.epoch != s.epoch+1 || s.epoch <= c.finalized.epoch) {
+					continue // applied already
+				}
+				if target == nil || t.before(*target) || (t == *target && s.before(*source)) {
+					sCopy, tCopy := s, t
+					source, target = &sCopy, &tCopy
+				}
+			}
+		}
+		if target == nil {
+			return
+		}
+		if !c.justifiedSet[*target] {
+			c.justifiedSet[*target] = true
+			if target.epoch > c.justified.epoch {
+				c.justified = *target
+			}
+			logger.Audit(node.Id(), "CHECKPOINT_JUSTIFIED", target.hash, fmt.Sprintf("%v", target.epoch), node.Time())
+		}
+		if target.epoch == source.epoch+1 && source.epoch > c.finalized.epoch {
+			c.finalize(node, *source)
+		}
+	}
+}
+
+// finalize sets the finalized checkpoint and forgets the votes and justified checkpoints it makes obsolete.
+func (c *GasperConsensus) finalize(node interfaces.INode, finalized checkpoint) {
+	c.finalized = finalized
+	logger.Audit(node.Id(), "CHECKPOINT_FINALIZED", c.finalized.hash, fmt.Sprintf("%v", c.finalized.epoch), node.Time())
+	for epoch := range c.ffgVoted {
+		if epoch <= c.finalized.epoch {
+			delete(c.ffgVoted, epoch)
+		}
+	}
+	for source := range c.ffgVotes {
+		if source.epoch < c.finalized.epoch {
+			delete(c.ffgVotes, source)
+		}
+	}
+	for cp := range c.justifiedSet {
+		if cp.epoch < c.finalized.epoch {
+			delete(c.justifiedSet, cp)
+		}
+	}
+}
+
+// updateHead sets the head chosen by LMD-GHOST starting at the justified checkpoint and returns if the head changed.
+func (c *GasperConsensus) updateHead(node interfaces.INode, world interfaces.IWorld) bool {
+	c.initValidators(world)
+	ledger := node.Ledger()
+	root := ledger.GetBlock(node, c.justified.hash)
+	if root == nil {
+		root = ledger.GetBlock(node, c.finalized.hash)
+	}
+	if root == nil {
+		root = ledger.CurrentLedgerByHeight()[0]
+	}
+
+	c.countPendingWeights(node)
+	head := root
+	for {
+		var best interfaces.IBlock
+		for _, childHash := range c.children[head.Hash()] {
+			child := ledger.GetBlock(node, childHash)
+			if best == nil || c.weights[child.Hash()] > c.weights[best.Hash()] || (c.weights[child.Hash()] == c.weights[best.Hash()] && preferredOnTie(child, best, node)) {
+				best = child
+			}
+		}
+		if best == nil {
+			break
+		}
+		head = best
+	}
+
+	currentHead := ledger.Head(node)
+	if head.Hash() == currentHead.Hash() {
+		return false
+	}
+	// verify the state of the blocks not yet in the current chain
+	for block := head; block != nil && !ledger.CurrentHasBlock(node, block.Hash()); block = ledger.GetBlock(node, block.ParentHash()) {
+		if !ledger.State()[block.Hash()] && !node.Consensus().VerifyState(block, node, world.SimConfig().CheckPastTxWhenVerifyingState()) {
+			logger.Audit(node.Id(), "FORK_CHOICE_INVALID_STATE", block.Hash(), "", node.Time())
+			return false
+		}
+	}
+	isReorg := !isAncestor(currentHead, head, node)
+	if !ledger.ReorgTo(node, head, world) {
+		logger.Audit(node.Id(), "FORK_CHOICE_REORG_ERROR", head.Hash(), "", node.Time())
+		return false
+	}
+	if isReorg {
+		c.forkChoiceReorgs++
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_WRITTEN_REORG, node.Id()), 1)
+		logger.Audit(node.Id(), "FORK_CHOICE_REORG", head.Hash(), currentHead.Hash(), node.Time())
+	} else {
+		logger.Audit(node.Id(), "FORK_CHOICE_HEAD", head.Hash(), "", node.Time())
+	}
+	return true
+}
+
+// updateWeight moves the stake of the validator from the chain of the block it is counted for to the chain of its latest
+// message, up to their common ancestor. The latest message waits if its block is not written yet.
+func (c *GasperConsensus) updateWeight(node interfaces.INode, validatorId string) {
+	ledger := node.Ledger()
+	headHash := c.latestMessages[validatorId].HeadHash()
+	to := ledger.GetBlock(node, headHash)
+	if to == nil {
+		c.pendingWeights[headHash] = append(c.pendingWeights[headHash], validatorId)
+		return
+	}
+	from := ledger.GetBlock(node, c.weighted[validatorId])
+	c.weighted[validatorId] = headHash
+	stake := int64(math.Round(c.stakes[validatorId]))
+	for from != nil || to != nil {
+		if from != nil && to != nil && from.Hash() == to.Hash() {
+			return
+		}
+		if from != nil && (to == nil || from.Header().Number() >= to.Header().Number()) {
+			c.weights[from.Hash()] -= stake
+			from = ledger.GetBlock(node, from.ParentHash())
+		} else {
+			c.weights[to.Hash()] += stake
+			to = ledger.GetBlock(node, to.ParentHash())
+		}
+	}
+}
+
+// countPendingWeights counts the latest messages whose block was written since they arrived.
+func (c *GasperConsensus) countPendingWeights(node interfaces.INode) {
+	for hash, validatorIds := range c.pendingWeights {
+		if !node.Ledger().HasBlock(node, hash) {
+			continue
+		}
+		delete(c.pendingWeights, hash)
+		for _, validatorId := range validatorIds {
+			if c.latestMessages[validatorId].HeadHash() == hash && c.weighted[validatorId] != hash {
+				c.updateWeight(node, validatorId)
+			}
+		}
+	}
+}
+
+// preferredOnTie keeps the current chain on equal weight, otherwise the smaller hash wins for determinism.
+func preferredOnTie(block interfaces.IBlock, best interfaces.IBlock, node interfaces.INode) bool {
+	blockCurrent, bestCurrent := node.Ledger().CurrentHasBlock(node, block.Hash()), node.Ledger().CurrentHasBlock(node, best.Hash())
+	if blockCurrent != bestCurrent {
+		return blockCurrent
+	}
+	return block.Hash() < best.Hash()
+}
+
+func isAncestor(ancestor interfaces.IBlock, block interfaces.IBlock, node interfaces.INode) bool {
+	for ; block != nil && block.Header().Number() >= ancestor.Header().Number(); block = node.Ledger().GetBlock(node, block.ParentHash()) {
+		if block.Hash() == ancestor.Hash() {
+			return true
+		}
+	}
+	return false
+}
+
+// index adds a block written to the ledger to the children of its parent and returns if it was not indexed before.
+func (c *GasperConsensus) index(block interfaces.IBlock, node interfaces.INode) bool {
+	if c.indexed[block.Hash()] || !node.Ledger().HasBlock(node, block.Hash()) {
+		return false
+	}
+	c.indexed[block.Hash()] = true
+	c.children[block.ParentHash()] = append(c.children[block.ParentHash()], block.Hash())
+	return true
+}
+
+// checkpointBlock returns the epoch boundary block of the chain of the head, the last block at or before the first slot of the epoch.
+func (c *GasperConsensus) checkpointBlock(node interfaces.INode, head interfaces.IBlock, epoch int) interfaces.IBlock {
+	block := head
+	for c.slotOf(block.Header()) > epoch*c.config.SlotsPerEpoch() {
+		block = node.Ledger().GetBlock(node, block.ParentHash())
+	}
+	return block
+}
+
+func (c *GasperConsensus) markAttestationSeen(attestation interfaces.IAttestation, peerId string) {
+	if c.attestationSeen[attestation.Id()] == nil {
+		c.attestationSeen[attestation.Id()] = make(map[string]bool)
+		c.attestationSlots[attestation.Slot()] = append(c.attestationSlots[attestation.Slot()], attestation.Id())
+	}
+	c.attestationSeen[attestation.Id()][peerId] = true
+}
+
+// pruneAttestations forgets the seen attestations older than two epochs, older attestations are ignored anyway.
+func (c *GasperConsensus) pruneAttestations(slot int) {
+	for ; c.prunedSlot < slot-2*c.config.SlotsPerEpoch(); c.prunedSlot++ {
+		for _, id := range c.attestationSlots[c.prunedSlot] {
+			delete(c.attestationSeen, id)
+		}
+		delete(c.attestationSlots, c.prunedSlot)
+	}
+}
+
+func (c *GasperConsensus) initValidators(world interfaces.IWorld) {
+	if c.validatorIds != nil {
+		return
+	}
+	c.validatorIds = world.NodeIds()
+	c.cumulatedStakes = make([]float64, 0, len(c.validatorIds))
+	for _, nId := range c.validatorIds {
+		c.stakes[nId] = world.Nodes()[nId].HashPower()
+		c.totalStake += c.stakes[nId]
+		c.cumulatedStakes = append(c.cumulatedStakes, c.totalStake)
+	}
+}
+
+// proposer returns the proposer of the slot picked weighted by stake, the same for all nodes without using the random number generators.
+func (c *GasperConsensus) proposer(slot int, world interfaces.IWorld) string {
+	c.initValidators(world)
+	pick := seededUniform(world.SimConfig().Seed(), "proposer", slot) * c.totalStake
+	i := sort.Search(len(c.cumulatedStakes), func(i int) bool { return c.cumulatedStakes[i] > pick })
+	if i == len(c.validatorIds) {
+		i--
+	}
+	return c.validatorIds[i]
+}
+
+// attestationSlot returns the slot within the epoch the validator attests in.
+func (c *GasperConsensus) attestationSlot(validatorId string, epoch int, world interfaces.IWorld) int {
+	return int(seededUniform(world.SimConfig().Seed(), "attester", epoch, validatorId) * float64(c.config.SlotsPerEpoch()))
+}
+
+// before orders checkpoints by epoch and hash for determinism.
+func (cp checkpoint) before(other checkpoint) bool {
+	return cp.epoch < other.epoch || (cp.epoch == other.epoch && cp.hash < other.hash)
+}
+
+func (c *GasperConsensus) slotOf(header interfaces.IBlockHeader) int {
+	return int(header.Time() / c.config.SlotTime())
+}
+
+func (c *GasperConsensus) slotNanos() int64 {
+	return c.config.SlotTime() * 1000000000
+}
+
+// seededUniform returns a uniform number in [0, 1) derived from the seed and the parts (like RANDAO for all nodes alike).
+func seededUniform(seed uint64, parts ...interface{}) float64 {
+	hash := sha256.Sum256([]byte(fmt.Sprint(seed, parts)))
+	return float64(binary.BigEndian.Uint64(hash[:8])>>11) / (1 << 53)
+}
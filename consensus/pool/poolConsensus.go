@@ -0,0 +1,229 @@
+package pool
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+	"ethattacksim/util/random"
+	"fmt"
+	"math"
+)
+
+// PoolMember is a miner of a mining pool submitting shares to the pool operator.
+// Withholding members submit their shares but never the full solutions they find.
+type PoolMember struct {
+	id             string
+	ownerId        string // receives the payouts of an infiltrator, empty for normal members
+	hashPower      float64
+	withholding    bool
+	shares         float64
+	foundBlocks    int
+	withheldBlocks int
+}
+
+func NewPoolMember(id string, ownerId string, hashPower float64, withholding bool) *PoolMember {
+	return &PoolMember{id: id, ownerId: ownerId, hashPower: hashPower, withholding: withholding}
+}
+
+// shareChunk holds the shares submitted by each member since the previous chunk.
+type shareChunk struct {
+	shares []float64
+	total  float64
+}
+
+// PoolConsensus models the members of a mining pool. The member finding a block is picked weighted by hash power,
+// blocks found by withholding members are discarded and the pool keeps mining on the same parent.
+// At the end the payouts of the members are computed under PPS (pay per share) and PPLNS (pay per last N shares).
+type PoolConsensus struct {
+	interfaces.IConsensus
+	members       []*PoolMember
+	config        interfaces.IPoolMembersConfig
+	lastShareTime int64
+	chunks        []*shareChunk        // most recent last, only as many as needed for the PPLNS window
+	windows       map[string][]float64 // share of each member in the PPLNS window of the blocks found by the pool
+}
+
+func NewPoolConsensus(consensus interfaces.IConsensus, members []*PoolMember, config interfaces.IPoolMembersConfig) interfaces.IConsensus {
+	return &PoolConsensus{IConsensus: consensus, members: members, config: config, windows: make(map[string][]float64)}
+}
+
+// NewBlockEvent decides which member found the block and drops it if the member withholds it.
+func (c *PoolConsensus) NewBlockEvent(node interfaces.INode, block interfaces.IBlock, world interfaces.IWorld, evTime int64) {
+	if !node.IsOnline() || len(c.members) == 0 {
+		c.IConsensus.NewBlockEvent(node, block, world, evTime)
+		return
+	}
+	c.accountShares(world.Time())
+	finder := c.pickFinder()
+	if finder.withholding {
+		finder.withheldBlocks++
+		logger.Audit(node.Id(), "POOL_BLOCK_WITHHELD", block.Hash(), finder.id, node.Time())
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_POOL_BLOCK_WITHHELD, node.Id()), 1)
+		if node.Ledger().Head(node).Hash() == block.ParentHash() {
+			// otherwise mining was already restarted on the new head
+			node.Consensus().MineBlock(node.Ledger(), node, world)
+		}
+		return
+	}
+	finder.foundBlocks++
+	c.windows[block.Hash()] = c.pplnsWindow()
+	c.IConsensus.NewBlockEvent(node, block, world, evTime)
+}
+
+// ConsensusStats returns the shares and payouts of each member, the revenue of the pool and the payouts of infiltrators to their owners.
+func (c *PoolConsensus) ConsensusStats(node interfaces.INode, world interfaces.IWorld) map[string]map[string]float64 {
+	stats := make(map[string]map[string]float64)
+	poolStats := make(map[string]float64)
+	stats[node.Id()] = poolStats
+	if len(c.members) == 0 {
+		return stats
+	}
+	c.accountShares(world.Time())
+	config := world.SimConfig()
+	fee := c.config.PoolFee()
+
+	// revenue of the pool and of the whole chain in the view of the pool
+	poolRevenue, chainRevenue := 0.0, 0.0
+	payoutsPPLNS := make([]float64, len(c.members))
+	for _, block := range node.Ledger().CurrentLedgerByHeight() {
+		if block.Header().Number() == 0 {
+			continue
+		}
+		revenue := config.BlockReward()
+		if len(block.Body().Uncles()) > 0 {
+			revenue += config.BlockNephewReward()
+		}
+		for _, tx := range block.Body().Transactions() {
+			if tx.SenderId() != block.Header().MinerId() {
+				revenue += float64(tx.GasUsed()*tx.EffectiveTip(block.Header().BaseFee())) / 1000000000000000000 // wei to eth
+			}
+		}
+		chainRevenue += revenue
+		if block.Header().MinerId() == node.Id() {
+			poolRevenue += revenue
+			c.addPPLNSPayouts(payoutsPPLNS, block.Hash(), revenue*(1-fee))
+		}
+		for _, uncle := range block.Body().Uncles() {
+			uncleReward := float64(uncle.Number()+8-block.Header().Number()) * config.BlockReward() / 8
+			chainRevenue += uncleReward
+			if uncle.MinerId() == node.Id() {
+				poolRevenue += uncleReward
+				c.addPPLNSPayouts(payoutsPPLNS, uncle.Hash(), uncleReward*(1-fee))
+			}
+		}
+	}
+
+	// PPS pays the expected revenue of a share in the network
+	seconds := float64(world.Time()) / 1000000000
+	ppsRate := 0.0
+	if seconds > 0 {
+		ppsRate = chainRevenue / (config.OverallHashPower() * seconds) * c.config.ShareDifficulty() * (1 - fee)
+	}
+
+	poolShares, poolWithheldBlocks, payoutPPS := 0.0, 0, 0.0
+	for i, member := range c.members {
+		memberPPS := member.shares * ppsRate
+		poolShares += member.shares
+		poolWithheldBlocks += member.withheldBlocks
+		payoutPPS += memberPPS
+		withholding := 0.0
+		if member.withholding {
+			withholding = 1
+		}
+		stats[member.id] = map[string]float64{
+			"hashRatePercentage": member.hashPower / node.HashPower() * 100,
+			"withholding":        withholding,
+			"shares":             member.shares,
+			"foundBlocks":        float64(member.foundBlocks),
+			"withheldBlocks":     float64(member.withheldBlocks),
+			"payoutPPS":          memberPPS,
+			"payoutPPLNS":        payoutsPPLNS[i],
+		}
+		if member.ownerId != "" {
+			if _, ok := stats[member.ownerId]; !ok {
+				stats[member.ownerId] = make(map[string]float64)
+			}
+			stats[member.ownerId][fmt.Sprintf("infiltrationPayoutPPS_%v", node.Id())] += memberPPS
+			stats[member.ownerId][fmt.Sprintf("infiltrationPayoutPPLNS_%v", node.Id())] += payoutsPPLNS[i]
+		}
+	}
+	poolStats["poolShares"] = poolShares
+	poolStats["poolWithheldBlocks"] = float64(poolWithheldBlocks)
+	poolStats["poolRevenue"] = poolRevenue
+	poolStats["poolOperatorProfitPPS"] = poolRevenue - payoutPPS
+	poolStats["poolOperatorProfitPPLNS"] = poolRevenue * fee
+	return stats
+}
+
+// accountShares adds the shares submitted by every member since the last accounting, normal approximation of the poisson distribution.
+func (c *PoolConsensus) accountShares(now int64) {
+	if now <= c.lastShareTime {
+		return
+	}
+	seconds := float64(now-c.lastShareTime) / 1000000000
+	c.lastShareTime = now
+	chunk := &shareChunk{shares: make([]float64, len(c.members))}
+	for i, member := range c.members {
+		lambda := member.hashPower * seconds / c.config.ShareDifficulty()
+		shares := math.Max(0, math.Round(lambda+math.Sqrt(lambda)*random.Normal()))
+		member.shares += shares
+		chunk.shares[i] = shares
+		chunk.total += shares
+	}
+	c.chunks = append(c.chunks, chunk)
+
+	// drop chunks older than the PPLNS window
+	window, start := float64(c.config.PplnsWindow()), len(c.chunks)
+	for start > 0 && window > 0 {
+		start--
+		window -= c.chunks[start].total
+	}
+	c.chunks = c.chunks[start:]
+}
+
+// pplnsWindow returns the share of each member in the last N shares.
+func (c *PoolConsensus) pplnsWindow() []float64 {
+	window := make([]float64, len(c.members))
+	remaining, total := float64(c.config.PplnsWindow()), 0.0
+	for i := len(c.chunks) - 1; i >= 0 && remaining > 0; i-- {
+		chunk := c.chunks[i]
+		if chunk.total == 0 {
+			continue
+		}
+		fraction := math.Min(1, remaining/chunk.total)
+		for m, shares := range chunk.shares {
+			window[m] += shares * fraction
+		}
+		remaining -= chunk.total * fraction
+		total += chunk.total * fraction
+	}
+	if total > 0 {
+		for m := range window {
+			window[m] /= total
+		}
+	}
+	return window
+}
+
+func (c *PoolConsensus) addPPLNSPayouts(payouts []float64, hash string, revenue float64) {
+	if window, ok := c.windows[hash]; ok {
+		for m, share := range window {
+			payouts[m] += revenue * share
+		}
+	}
+}
+
+func (c *PoolConsensus) pickFinder() *PoolMember {
+	total := 0.0
+	for _, member := range c.members {
+		total += member.hashPower
+	}
+	pick := random.Uniform() * total
+	for _, member := range c.members {
+		if pick < member.hashPower {
+			return member
+		}
+		pick -= member.hashPower
+	}
+	return c.members[len(c.members)-1]
+}
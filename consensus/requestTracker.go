@@ -0,0 +1,92 @@
+package consensus
+
+import (
+	"ethattacksim/interfaces"
+)
+
+// Request is a retrieval sent to a peer that is matched to its response by id.
+type Request struct {
+	id        int
+	kind      string
+	peerId    string
+	blockHash string
+	hashes    []string
+	num       int
+	retries   int
+}
+
+func (r *Request) Id() int {
+	return r.id
+}
+
+func (r *Request) Kind() string {
+	return r.kind
+}
+
+func (r *Request) PeerId() string {
+	return r.peerId
+}
+
+func (r *Request) BlockHash() string {
+	return r.blockHash
+}
+
+func (r *Request) Hashes() []string {
+	return r.hashes
+}
+
+func (r *Request) Num() int {
+	return r.num
+}
+
+func (r *Request) Retries() int {
+	return r.retries
+}
+
+// RequestTracker holds the outstanding requests of a node, the ids are only unique per node like eth/66 request ids.
+type RequestTracker struct {
+	requests map[int]interfaces.IRequest
+	timeouts map[string]int // peer id to consecutive unanswered requests
+	lastId   int
+}
+
+func NewRequestTracker() interfaces.IRequestTracker {
+	return &RequestTracker{requests: make(map[int]interfaces.IRequest, 20), timeouts: make(map[string]int)}
+}
+
+func (t *RequestTracker) New(kind string, peerId string, blockHash string, hashes []string, num int, retries int) interfaces.IRequest {
+	t.lastId++
+	return &Request{t.lastId, kind, peerId, blockHash, hashes, num, retries}
+}
+
+func (t *RequestTracker) Add(kind string, peerId string, blockHash string, hashes []string, num int, retries int) interfaces.IRequest {
+	request := t.New(kind, peerId, blockHash, hashes, num, retries)
+	t.requests[request.Id()] = request
+	return request
+}
+
+func (t *RequestTracker) Get(requestId int) interfaces.IRequest {
+	return t.requests[requestId]
+}
+
+func (t *RequestTracker) Remove(requestId int) interfaces.IRequest {
+	request, ok := t.requests[requestId]
+	if !ok {
+		return nil
+	}
+	delete(t.requests, requestId)
+	return request
+}
+
+func (t *RequestTracker) AddTimeout(peerId string) int {
+	t.timeouts[peerId]++
+	return t.timeouts[peerId]
+}
+
+func (t *RequestTracker) ResetTimeouts(peerId string) {
+	delete(t.timeouts, peerId)
+}
+
+func (t *RequestTracker) Len() int {
+	return len(t.requests)
+}
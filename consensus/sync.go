@@ -0,0 +1,311 @@
+package consensus
+
+import (
+	"ethattacksim/interfaces"
+	ledg "ethattacksim/ledger"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+	"fmt"
+	ti "time"
+)
+
+// Sync catches a node up with a peer far ahead like geth's downloader: a skeleton of every syncBatch-th header down from
+// the announced head is retrieved from the peer, its gaps are filled and the bodies are retrieved in batches, both spread
+// over the peers that have the blocks. The blocks are imported in order as soon as they are complete, side blocks
+// that are the parents of uncles are retrieved on the way as their uncles are dangling otherwise.
+type Sync struct {
+	peerId    string
+	head      interfaces.IBlock
+	startTime int64
+	from      int                             // first block number synced, the number after the local head at the start
+	next      int                             // next block number to import
+	skeleton  bool                            // skeleton requested and not received yet
+	fills     map[string]bool                 // skeleton header hashes whose gap is not filled yet
+	numbers   map[string]int                  // header hash to block number
+	headers   map[int]interfaces.IBlockHeader // by block number
+	bodies    map[string]interfaces.IBlockBody
+	sides     map[string]interfaces.IBlockHeader // requested side blocks, the header is nil until it is received
+	assigned  int                                // requests assigned to peers, for the round robin
+}
+
+func NewSync() interfaces.ISync {
+	return &Sync{}
+}
+
+// StartSync syncs with the peer if the total difficulty of the head it announced is more than limits syncThreshold blocks
+// (at the difficulty of the local head) ahead, it returns if a sync was started. Without the limit the node never syncs.
+func StartSync(node interfaces.INode, peer interfaces.INode, head interfaces.IBlock, world interfaces.IWorld) bool {
+	threshold, ok := world.SimConfig().Limits()["syncThreshold"]
+	localHead := node.Ledger().Head(node)
+	if !ok || peer == nil || peer.Id() == node.Id() || localHead == nil || node.Consensus().Sync().Active() {
+		return false
+	}
+	if head.Header().Number() <= localHead.Header().Number() || head.TotalDifficulty() <= localHead.TotalDifficulty()+threshold*localHead.Header().Difficulty() {
+		return false
+	}
+	node.Consensus().Sync().Start(node, peer, head, world)
+	return true
+}
+
+func (s *Sync) Active() bool {
+	return s.head != nil
+}
+
+func (s *Sync) PeerId() string {
+	return s.peerId
+}
+
+func (s *Sync) Start(node interfaces.INode, peer interfaces.INode, head interfaces.IBlock, world interfaces.IWorld) {
+	s.peerId = peer.Id()
+	s.head = head
+	s.startTime = node.Time()
+	s.from = node.Ledger().Head(node).Header().Number() + 1
+	s.next = s.from
+	s.skeleton = true
+	s.fills = make(map[string]bool)
+	s.numbers = map[string]int{head.Hash(): head.Header().Number()}
+	s.headers = make(map[int]interfaces.IBlockHeader, head.Header().Number()-s.from+1)
+	s.bodies = make(map[string]interfaces.IBlockBody, head.Header().Number()-s.from+1)
+	s.sides = make(map[string]interfaces.IBlockHeader)
+	logger.Audit(node.Id(), "SYNC_START", head.Hash(), fmt.Sprintf("%v:%v-%v", peer.Id(), s.from, head.Header().Number()), node.Time())
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_SYNC_STARTED, node.Id()), 1)
+	num := (head.Header().Number()-s.from)/syncBatch(world) + 1 // every syncBatch-th header down from the head
+	SendRequest(node, peer, world, interfaces.REQUEST_SKELETON, head.Hash(), []string{head.Hash()}, num)
+}
+
+func (s *Sync) ReceivedHeaders(node interfaces.INode, request interfaces.IRequest, headers []interfaces.IBlockHeader, world interfaces.IWorld) {
+	if !s.Active() || len(headers) == 0 {
+		return // an empty response is retried with another peer
+	}
+	switch request.Kind() {
+	case interfaces.REQUEST_SKELETON:
+		if !s.skeleton || request.BlockHash() != s.head.Hash() {
+			return
+		}
+		if len(headers) != request.Num() || headers[len(headers)-1].Hash() != s.head.Hash() {
+			s.Abort(node, "invalid skeleton")
+			return
+		}
+		s.skeleton = false
+		// fill the gap below every skeleton header, the lowest one down to the first synced block
+		for i, header := range headers {
+			num := syncBatch(world)
+			if i == 0 {
+				num = header.Number() - s.from + 1
+			}
+			s.numbers[header.Hash()] = header.Number()
+			s.fills[header.Hash()] = true
+			if !s.request(node, world, interfaces.REQUEST_SYNC_HEADERS, header.Hash(), []string{header.Hash()}, num) {
+				return
+			}
+		}
+	case interfaces.REQUEST_SYNC_HEADERS:
+		if side, ok := s.sides[request.BlockHash()]; ok && side == nil {
+			if headers[0].Hash() != request.BlockHash() {
+				s.Abort(node, "invalid headers")
+				return
+			}
+			s.sides[request.BlockHash()] = headers[0]
+			if !isEmpty(headers[0]) && !s.request(node, world, interfaces.REQUEST_SYNC_BODIES, "", []string{request.BlockHash()}, 0) {
+				return
+			}
+			s.importBlocks(node, world)
+			return
+		}
+		if !s.fills[request.BlockHash()] {
+			return
+		}
+		if len(headers) != request.Num() || headers[len(headers)-1].Hash() != request.BlockHash() {
+			s.Abort(node, "invalid headers")
+			return
+		}
+		for i, header := range headers {
+			if i > 0 && header.ParentHash() != headers[i-1].Hash() {
+				s.Abort(node, "invalid headers")
+				return
+			}
+			s.numbers[header.Hash()] = header.Number()
+			s.headers[header.Number()] = header
+		}
+		delete(s.fills, request.BlockHash())
+		if len(s.fills) == 0 {
+			s.fetchBodies(node, world)
+		}
+	}
+}
+
+// fetchBodies checks that the filled gaps link up and retrieves the bodies of the blocks that are not empty.
+func (s *Sync) fetchBodies(node interfaces.INode, world interfaces.IWorld) {
+	hashes := make([]string, 0, len(s.headers))
+	for n := s.from; n <= s.head.Header().Number(); n++ {
+		header := s.headers[n]
+		if n > s.from && header.ParentHash() != s.headers[n-1].Hash() {
+			s.Abort(node, "headers do not link")
+			return
+		}
+		if !isEmpty(header) {
+			hashes = append(hashes, header.Hash())
+		}
+	}
+	batch := syncBatch(world)
+	for i := 0; i < len(hashes); i += batch {
+		end := i + batch
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		if !s.request(node, world, interfaces.REQUEST_SYNC_BODIES, "", hashes[i:end], 0) {
+			return
+		}
+	}
+	s.importBlocks(node, world) // the empty blocks at the start
+}
+
+func (s *Sync) ReceivedBodies(node interfaces.INode, request interfaces.IRequest, bodies []interfaces.IBlockBody, world interfaces.IWorld) {
+	if !s.Active() {
+		return
+	}
+	for _, body := range bodies {
+		if _, ok := s.numbers[body.BlockHash()]; ok {
+			s.bodies[body.BlockHash()] = body
+		}
+	}
+	s.importBlocks(node, world)
+}
+
+// importBlocks inserts the complete blocks following the last imported one, the sync is done with the head.
+func (s *Sync) importBlocks(node interfaces.INode, world interfaces.IWorld) {
+	for s.Active() && s.next <= s.head.Header().Number() {
+		header := s.headers[s.next]
+		if !node.Ledger().HasBlock(node, header.Hash()) && !s.insert(node, header, world) {
+			return
+		}
+		s.next++
+	}
+	if s.Active() {
+		logger.Audit(node.Id(), "SYNC_DONE", s.head.Hash(), s.peerId, node.Time())
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_SYNC_BLOCKS, node.Id()), int64(s.head.Header().Number()-s.from+1))
+		metrics.Timer(interfaces.METRIC_SYNC_DURATION.String(), ti.Duration(node.Time()-s.startTime))
+		s.head = nil
+	}
+}
+
+// insert inserts the block once its body and the parents of its uncles are there, it returns if the block was inserted.
+func (s *Sync) insert(node interfaces.INode, header interfaces.IBlockHeader, world interfaces.IWorld) bool {
+	body, ok := s.bodies[header.Hash()]
+	if isEmpty(header) {
+		body, ok = ledg.NewBlockBody(header.Hash(), make([]interfaces.ITransaction, 0), make([]interfaces.IBlockHeader, 0), true, 0), true
+	}
+	if !ok {
+		return false // wait for the body
+	}
+	ready := true
+	for _, uncle := range body.Uncles() {
+		ready = s.sideBlock(node, uncle.ParentHash(), uncle.Number()-1, world) && ready
+	}
+	if !ready {
+		return false
+	}
+	// totalDifficulty will be set later on when verifying header
+	if _, ok := node.Consensus().InsertBlock(ledg.NewBlock(header, body, -1), node, node.Ledger(), world, s.peerId, -1); !ok && !node.Ledger().HasBlock(node, header.Hash()) {
+		s.Abort(node, "import failed")
+		return false
+	}
+	return true
+}
+
+// sideBlock returns if the node has the block, otherwise the block and its missing ancestors are retrieved and inserted.
+func (s *Sync) sideBlock(node interfaces.INode, hash string, number int, world interfaces.IWorld) bool {
+	if node.Ledger().HasBlock(node, hash) {
+		return true
+	}
+	header, requested := s.sides[hash]
+	if !requested {
+		logger.Audit(node.Id(), "SYNC_SIDE_BLOCK", hash, "", node.Time())
+		s.sides[hash] = nil
+		s.numbers[hash] = number
+		s.request(node, world, interfaces.REQUEST_SYNC_HEADERS, hash, []string{hash}, 1)
+		return false
+	}
+	if header == nil || !s.sideBlock(node, header.ParentHash(), header.Number()-1, world) {
+		return false
+	}
+	return s.insert(node, header, world) && node.Ledger().HasBlock(node, hash)
+}
+
+func (s *Sync) Missing(request interfaces.IRequest) []string {
+	if !s.Active() {
+		return nil
+	}
+	missing := make([]string, 0, len(request.Hashes()))
+	switch request.Kind() {
+	case interfaces.REQUEST_SKELETON:
+		if s.skeleton && request.BlockHash() == s.head.Hash() {
+			missing = append(missing, request.BlockHash())
+		}
+	case interfaces.REQUEST_SYNC_HEADERS:
+		if side, ok := s.sides[request.BlockHash()]; s.fills[request.BlockHash()] || ok && side == nil {
+			missing = append(missing, request.BlockHash())
+		}
+	case interfaces.REQUEST_SYNC_BODIES:
+		for _, h := range request.Hashes() {
+			if _, ok := s.bodies[h]; !ok {
+				missing = append(missing, h)
+			}
+		}
+	}
+	return missing
+}
+
+func (s *Sync) PeerHas(peer interfaces.INode, hash string) bool {
+	_, ok := s.numbers[hash]
+	return ok && peer.Ledger().HasBlock(peer, hash)
+}
+
+func (s *Sync) Abort(node interfaces.INode, reason string) {
+	if !s.Active() {
+		return
+	}
+	logger.Audit(node.Id(), "SYNC_FAILED", s.head.Hash(), reason, node.Time())
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_SYNC_FAILED, node.Id()), 1)
+	s.head = nil
+}
+
+// request sends the request to the next peer that has the last block of the hashes, the sync is aborted if no peer is
+// left. It returns if the request was sent.
+func (s *Sync) request(node interfaces.INode, world interfaces.IWorld, kind string, blockHash string, hashes []string, num int) bool {
+	peer := s.nextPeer(node, hashes[len(hashes)-1])
+	if peer == nil {
+		s.Abort(node, "no peer")
+		return false
+	}
+	SendRequest(node, peer, world, kind, blockHash, hashes, num)
+	return true
+}
+
+// nextPeer returns the next peer in turn that has the block, the sync peer if there is none and nil if the sync peer
+// was dropped as well.
+func (s *Sync) nextPeer(node interfaces.INode, hash string) interfaces.INode {
+	peers := make([]interfaces.INode, 0, len(node.Peers()))
+	var syncPeer interfaces.INode
+	for _, peer := range node.Peers() {
+		if peer.Id() == s.peerId {
+			syncPeer = peer
+		}
+		if s.PeerHas(peer, hash) {
+			peers = append(peers, peer)
+		}
+	}
+	if len(peers) == 0 {
+		return syncPeer
+	}
+	s.assigned++
+	return peers[s.assigned%len(peers)]
+}
+
+func isEmpty(header interfaces.IBlockHeader) bool {
+	return header.TxHash() == "" && header.UncleHash() == ""
+}
+
+func syncBatch(world interfaces.IWorld) int {
+	return world.SimConfig().Limits()["syncBatch"]
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"ethattacksim/event"
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+	"ethattacksim/util/random"
+)
+
+/*
+*
+event that takes a node of a class with churn offline or back online and schedules its next change
+*/
+type ChurnEvent struct {
+	interfaces.IEvent
+	online bool
+	class  string
+}
+
+func NewChurnEvent(ev interfaces.IEvent, online bool, class string) *ChurnEvent {
+	return &ChurnEvent{ev, online, class}
+}
+
+func (ev *ChurnEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	var duration int64
+	if ev.online {
+		setOnline(node, ev.Time(), world)
+		duration, _ = random.ChurnSession(ev.class)
+	} else {
+		setOffline(node, ev.Time(), world)
+		duration, _ = random.ChurnDowntime(ev.class)
+	}
+	world.Queue().Add(NewChurnEvent(event.NewEvent(ev.Time()+duration, node.Id(), interfaces.CHURN_EVENT), !ev.online, ev.class))
+}
+
+/*
+*
+event that takes nodes offline or back online at a fixed time
+*/
+type OnlineChangeEvent struct {
+	interfaces.IEvent
+	change interfaces.IOnlineChangeConfig
+}
+
+func NewOnlineChangeEvent(ev interfaces.IEvent, change interfaces.IOnlineChangeConfig) *OnlineChangeEvent {
+	return &OnlineChangeEvent{ev, change}
+}
+
+func (ev *OnlineChangeEvent) Execute(world interfaces.IWorld) {
+	for _, nId := range ev.change.Nodes() {
+		if ev.change.Online() {
+			setOnline(world.Nodes()[nId], ev.Time(), world)
+		} else {
+			setOffline(world.Nodes()[nId], ev.Time(), world)
+		}
+	}
+}
+
+// setOffline stops mining, the peers notice the closed connections and replace the node with another peer.
+func setOffline(node interfaces.INode, time int64, world interfaces.IWorld) {
+	if !node.IsOnline() {
+		return
+	}
+	logger.Audit(node.Id(), "NODE_OFFLINE", "", "", time)
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_NODE_OFFLINE, node.Id()), 1)
+	node.SetOnline(false)
+	world.Queue().DeleteOneOfTypeForNode(interfaces.NEW_BLOCK_EVENT, node)
+	peers := append([]interfaces.INode{}, node.Peers()...)
+	for _, peer := range peers {
+		peer.Network().DropPeer(peer, node.Id(), world)
+	}
+}
+
+// setOnline connects the node to new peers, it catches up with them and restarts mining.
+func setOnline(node interfaces.INode, time int64, world interfaces.IWorld) {
+	if node.IsOnline() {
+		return
+	}
+	if time > node.Time() {
+		node.SetTime(time)
+	}
+	logger.Audit(node.Id(), "NODE_ONLINE", "", "", node.Time())
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_NODE_ONLINE, node.Id()), 1)
+	node.SetOnline(true)
+	node.Network().ConnectToPeers(node.Id(), world)
+	node.Consensus().RejoinEvent(node, world)
+}
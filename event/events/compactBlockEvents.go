@@ -0,0 +1,84 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+	"fmt"
+)
+
+/*
+*
+event that receives a compact block (header and short tx ids), the receiver reconstructs it from its tx pool
+*/
+type ReceivedCompactBlockEvent struct {
+	interfaces.IEvent
+	block    interfaces.IBlock
+	senderId string
+}
+
+func NewReceivedCompactBlockEvent(ev interfaces.IEvent, block interfaces.IBlock, senderId string) *ReceivedCompactBlockEvent {
+	return &ReceivedCompactBlockEvent{ev, block, senderId}
+}
+
+func (ev *ReceivedCompactBlockEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if ev.Time() > node.Time() {
+		node.SetTime(ev.Time())
+	}
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_COMPACT_BLOCK_RECEIVED, ev.TargetId()), 1)
+	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), ev.block.Hash(), "", node.Time())
+	node.Consensus().ReceivedCompactBlockEvent(node, ev.block, ev.senderId, world)
+}
+
+/*
+*
+event that receives the request for the txs of a compact block missing in the tx pool of the sender
+*/
+type RetrieveBlockTxsEvent struct {
+	interfaces.IEvent
+	requestId int
+	blockHash string
+	txIds     []string
+	senderId  string
+}
+
+func NewRetrieveBlockTxsEvent(ev interfaces.IEvent, requestId int, blockHash string, txIds []string, senderId string) *RetrieveBlockTxsEvent {
+	return &RetrieveBlockTxsEvent{ev, requestId, blockHash, txIds, senderId}
+}
+
+func (ev *RetrieveBlockTxsEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if ev.Time() > node.Time() {
+		node.SetTime(ev.Time())
+	}
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_TXS_RETRIEVAL, ev.TargetId()), 1)
+	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), ev.blockHash, fmt.Sprintf("%v", len(ev.txIds)), node.Time())
+	node.Consensus().RetrieveBlockTxsEvent(node, ev.requestId, ev.blockHash, ev.txIds, ev.senderId, world)
+}
+
+/*
+*
+event that receives the missing txs of a compact block
+*/
+type ReceivedBlockTxsEvent struct {
+	interfaces.IEvent
+	requestId int
+	blockHash string
+	txs       []interfaces.ITransaction
+	senderId  string
+}
+
+func NewReceivedBlockTxsEvent(ev interfaces.IEvent, requestId int, blockHash string, txs []interfaces.ITransaction, senderId string) *ReceivedBlockTxsEvent {
+	return &ReceivedBlockTxsEvent{ev, requestId, blockHash, txs, senderId}
+}
+
+func (ev *ReceivedBlockTxsEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if ev.Time() > node.Time() {
+		node.SetTime(ev.Time())
+	}
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_TXS_RECEIVED, ev.TargetId()), int64(len(ev.txs)))
+	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), ev.blockHash, "", node.Time())
+	node.Consensus().ReceivedBlockTxsEvent(node, ev.requestId, ev.blockHash, ev.txs, ev.senderId, world)
+}
@@ -0,0 +1,39 @@
+package events
+
+import (
+	"ethattacksim/event"
+	"ethattacksim/interfaces"
+)
+
+/*
+*
+event of a message arriving at a node with a link, the download is reserved on arrival so the messages are downloaded in
+the order they arrive, the message event is executed once the download is done
+*/
+type DownloadEvent struct {
+	interfaces.IEvent
+	message    interfaces.IEvent
+	bytes      int
+	downloaded bool
+}
+
+func NewDownloadEvent(ev interfaces.IEvent, message interfaces.IEvent, bytes int) *DownloadEvent {
+	return &DownloadEvent{ev, message, bytes, false}
+}
+
+func (ev *DownloadEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if ev.downloaded {
+		if ev.Time() > node.Time() {
+			node.SetTime(ev.Time())
+		}
+		ev.message.Execute(world)
+		return
+	}
+	if !node.IsOnline() {
+		ev.message.Execute(world) // dropped by the consensus of the offline node
+		return
+	}
+	done := node.Network().Link().Download(ev.Time(), ev.bytes)
+	world.Queue().Add(&DownloadEvent{event.NewEvent(done, ev.TargetId(), interfaces.DOWNLOAD_EVENT), ev.message, ev.bytes, true})
+}
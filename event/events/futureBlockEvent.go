@@ -0,0 +1,38 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+)
+
+/*
+*
+event that retries the import of a queued block once its timestamp is no longer too far ahead of the node's time
+*/
+type FutureBlockEvent struct {
+	interfaces.IEvent
+	hash string
+}
+
+func NewFutureBlockEvent(ev interfaces.IEvent, hash string) *FutureBlockEvent {
+	return &FutureBlockEvent{ev, hash}
+}
+
+func (ev *FutureBlockEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if !node.IsOnline() {
+		return
+	}
+	if ev.Time() > node.Time() {
+		node.SetTime(ev.Time())
+	}
+	queue := node.Consensus().FutureBlocks()
+	block := queue.Get(ev.hash)
+	if block == nil {
+		return // already imported or dropped
+	}
+	senderId := queue.SenderId(ev.hash)
+	queue.Remove(ev.hash)
+	logger.Audit(node.Id(), "IMPORT_FUTURE", ev.hash, "", node.Time())
+	node.Consensus().InsertBlock(block, node, node.Ledger(), world, senderId, -1)
+}
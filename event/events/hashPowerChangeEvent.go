@@ -0,0 +1,38 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"fmt"
+)
+
+/*
+*
+event that multiplies the hash power of nodes, i.e. miners joining or leaving
+*/
+type HashPowerChangeEvent struct {
+	interfaces.IEvent
+	change interfaces.IHashPowerChangeConfig
+}
+
+func NewHashPowerChangeEvent(ev interfaces.IEvent, change interfaces.IHashPowerChangeConfig) *HashPowerChangeEvent {
+	return &HashPowerChangeEvent{ev, change}
+}
+
+func (ev *HashPowerChangeEvent) Execute(world interfaces.IWorld) {
+	for _, nId := range ev.change.Nodes() {
+		node := world.Nodes()[nId]
+		hashPower := node.HashPower() * ev.change.Factor()
+		logger.Audit(nId, "HASH_POWER_CHANGED", "", fmt.Sprintf("%v->%v", node.HashPower(), hashPower), ev.Time())
+		node.SetHashPower(hashPower)
+		if !node.IsOnline() {
+			continue
+		}
+		if ev.Time() > node.Time() {
+			node.SetTime(ev.Time())
+		}
+		// the pending block was drawn with the old hash power, mining is memoryless so it is simply drawn again
+		world.Queue().DeleteOneOfTypeForNode(interfaces.NEW_BLOCK_EVENT, node)
+		node.Consensus().MineBlock(node.Ledger(), node, world)
+	}
+}
@@ -32,5 +32,5 @@ func (ev *NewTxEvent) Execute(world interfaces.IWorld) {
 	if world.SimConfig().AuditLogTxMessages() {
 		logger.AuditEvent(node.Id(), ev.Type(), ev.tx.Id(), "", node.Time())
 	}
-	node.Consensus().ReceivedTxsEvent(node, []interfaces.ITransaction{ev.tx}, ev.senderId, world)
+	node.Consensus().ReceivedTxsEvent(node, 0, []interfaces.ITransaction{ev.tx}, ev.senderId, world)
 }
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"ethattacksim/event"
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"fmt"
+)
+
+const partitionCheckInterval = int64(1000000000) // nanos
+
+/*
+*
+event that heals a network partition and records the heads of all groups
+*/
+type PartitionHealEvent struct {
+	interfaces.IEvent
+}
+
+func NewPartitionHealEvent(ev interfaces.IEvent) *PartitionHealEvent {
+	return &PartitionHealEvent{ev}
+}
+
+func (ev *PartitionHealEvent) Execute(world interfaces.IWorld) {
+	partition := world.SimConfig().Partition()
+	stats := &PartitionStats{healTime: ev.Time(), reconvergenceTime: -1}
+	for i := range partition.Groups() {
+		group := &partitionGroup{}
+		for _, nId := range world.NodeIds() {
+			n := world.Nodes()[nId]
+			if partition.Group(n.Location()) != i {
+				continue
+			}
+			group.nodes++
+			group.hashPower += n.HashPower()
+			if group.head == nil || n.Ledger().Head(n).TotalDifficulty() > group.head.TotalDifficulty() {
+				group.head, group.headNode = n.Ledger().Head(n), n
+			}
+		}
+		stats.groups = append(stats.groups, group)
+		if group.head != nil {
+			logger.Audit("WORLD", "PARTITION_HEALED", group.head.Hash(), fmt.Sprintf("group%v", i), ev.Time())
+		}
+	}
+	world.AddStatsProvider(stats)
+	world.Queue().Add(NewPartitionCheckEvent(event.NewEvent(ev.Time()+partitionCheckInterval, "WORLD", interfaces.PARTITION_CHECK_EVENT), stats))
+}
+
+/*
+*
+event that checks periodically after the heal of a network partition if all nodes agree on a chain again
+*/
+type PartitionCheckEvent struct {
+	interfaces.IEvent
+	stats *PartitionStats
+}
+
+func NewPartitionCheckEvent(ev interfaces.IEvent, stats *PartitionStats) *PartitionCheckEvent {
+	return &PartitionCheckEvent{ev, stats}
+}
+
+func (ev *PartitionCheckEvent) Execute(world interfaces.IWorld) {
+	// the chains reconverged when all online nodes have the same block at the height of the highest head at the heal
+	height := 0
+	for _, group := range ev.stats.groups {
+		if group.head != nil && group.head.Header().Number() > height {
+			height = group.head.Header().Number()
+		}
+	}
+	var converged interfaces.INode
+	for _, nId := range world.NodeIds() {
+		n := world.Nodes()[nId]
+		if !n.IsOnline() {
+			continue
+		}
+		if n.Ledger().Length(n) <= height {
+			converged = nil
+			break
+		}
+		if converged == nil {
+			converged = n
+		} else if n.Ledger().CurrentLedgerByHeight()[height].Hash() != converged.Ledger().CurrentLedgerByHeight()[height].Hash() {
+			converged = nil
+			break
+		}
+	}
+	if converged == nil {
+		world.Queue().Add(NewPartitionCheckEvent(event.NewEvent(ev.Time()+partitionCheckInterval, "WORLD", interfaces.PARTITION_CHECK_EVENT), ev.stats))
+		return
+	}
+
+	ev.stats.reconvergenceTime = ev.Time() - ev.stats.healTime
+	for _, group := range ev.stats.groups {
+		// blocks of the group's chain that are not part of the agreed chain
+		block := group.head
+		for block != nil && !converged.Ledger().CurrentHasBlock(converged, block.Hash()) {
+			group.orphanedBlocks++
+			block = group.headNode.Ledger().GetBlock(group.headNode, block.ParentHash())
+		}
+	}
+	logger.Audit("WORLD", "PARTITION_RECONVERGED", converged.Ledger().CurrentLedgerByHeight()[height].Hash(), "", ev.Time())
+}
+
+// PartitionStats reports the reorg depth, the orphaned blocks per group and the time to reconverge after a network partition.
+type PartitionStats struct {
+	healTime          int64
+	reconvergenceTime int64 // nanos, -1 if the chains did not reconverge until the end of the sim
+	groups            []*partitionGroup
+}
+
+type partitionGroup struct {
+	nodes          int
+	hashPower      float64
+	head           interfaces.IBlock // best head within the group at the heal
+	headNode       interfaces.INode
+	orphanedBlocks int
+}
+
+func (s *PartitionStats) Stats(world interfaces.IWorld) map[string]map[string]float64 {
+	stats := make(map[string]float64)
+	stats["reconvergenceTime"] = -1
+	if s.reconvergenceTime >= 0 {
+		stats["reconvergenceTime"] = float64(s.reconvergenceTime) / 1000000000 // seconds
+	}
+	reorgDepth := 0
+	for i, group := range s.groups {
+		stats[fmt.Sprintf("group%vNodes", i)] = float64(group.nodes)
+		stats[fmt.Sprintf("group%vHashRatePercentage", i)] = group.hashPower / world.SimConfig().OverallHashPower() * 100
+		if group.head != nil {
+			stats[fmt.Sprintf("group%vHeadNumber", i)] = float64(group.head.Header().Number())
+		}
+		if s.reconvergenceTime >= 0 {
+			stats[fmt.Sprintf("group%vOrphanedBlocks", i)] = float64(group.orphanedBlocks)
+			if group.orphanedBlocks > reorgDepth {
+				reorgDepth = group.orphanedBlocks
+			}
+		}
+	}
+	if s.reconvergenceTime >= 0 {
+		stats["reorgDepth"] = float64(reorgDepth)
+	}
+	return map[string]map[string]float64{"partition": stats}
+}
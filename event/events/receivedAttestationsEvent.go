@@ -0,0 +1,39 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"ethattacksim/util/metrics"
+)
+
+type ReceivedAttestationsEvent struct {
+	interfaces.IEvent
+	attestations []interfaces.IAttestation
+	senderId     string
+}
+
+func NewReceivedAttestationsEvent(ev interfaces.IEvent, attestations []interfaces.IAttestation, senderId string) *ReceivedAttestationsEvent {
+	return &ReceivedAttestationsEvent{ev, attestations, senderId}
+}
+
+func (ev *ReceivedAttestationsEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if ev.Time() > node.Time() {
+		node.SetTime(ev.Time())
+	}
+	metrics.Counter(metrics.NameFormat(interfaces.METRIC_ATTESTATION_RECEIVED, ev.TargetId()), int64(len(ev.attestations)))
+	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), AttestationIds(ev.attestations), "", node.Time())
+	node.Consensus().ReceivedAttestationsEvent(node, ev.attestations, ev.senderId, world)
+}
+
+// AttestationIds joins the ids of the attestations for the audit log.
+func AttestationIds(attestations []interfaces.IAttestation) string {
+	ids := ""
+	for i, attestation := range attestations {
+		ids += attestation.Id()
+		if i != len(attestations)-1 {
+			ids += ","
+		}
+	}
+	return ids
+}
@@ -8,12 +8,13 @@ import (
 
 type ReceivedBlockBodiesEvent struct {
 	interfaces.IEvent
-	bodies   []interfaces.IBlockBody
-	senderId string
+	requestId int
+	bodies    []interfaces.IBlockBody
+	senderId  string
 }
 
-func NewReceivedBlockBodiesEvent(ev interfaces.IEvent, bodies []interfaces.IBlockBody, senderId string) *ReceivedBlockBodiesEvent {
-	return &ReceivedBlockBodiesEvent{ev, bodies, senderId}
+func NewReceivedBlockBodiesEvent(ev interfaces.IEvent, requestId int, bodies []interfaces.IBlockBody, senderId string) *ReceivedBlockBodiesEvent {
+	return &ReceivedBlockBodiesEvent{ev, requestId, bodies, senderId}
 }
 
 func (ev *ReceivedBlockBodiesEvent) Execute(world interfaces.IWorld) {
@@ -30,5 +31,5 @@ func (ev *ReceivedBlockBodiesEvent) Execute(world interfaces.IWorld) {
 		}
 	}
 	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), logId, "", node.Time())
-	node.Consensus().ReceivedBlockBodiesEvent(node, ev.bodies, ev.senderId, world)
+	node.Consensus().ReceivedBlockBodiesEvent(node, ev.requestId, ev.bodies, ev.senderId, world)
 }
@@ -8,12 +8,13 @@ import (
 
 type ReceivedBlockHeadersEvent struct {
 	interfaces.IEvent
-	headers  []interfaces.IBlockHeader
-	senderId string
+	requestId int
+	headers   []interfaces.IBlockHeader
+	senderId  string
 }
 
-func NewReceivedBlockHeadersEvent(ev interfaces.IEvent, headers []interfaces.IBlockHeader, senderId string) *ReceivedBlockHeadersEvent {
-	return &ReceivedBlockHeadersEvent{ev, headers, senderId}
+func NewReceivedBlockHeadersEvent(ev interfaces.IEvent, requestId int, headers []interfaces.IBlockHeader, senderId string) *ReceivedBlockHeadersEvent {
+	return &ReceivedBlockHeadersEvent{ev, requestId, headers, senderId}
 }
 
 func (ev *ReceivedBlockHeadersEvent) Execute(world interfaces.IWorld) {
@@ -30,5 +31,5 @@ func (ev *ReceivedBlockHeadersEvent) Execute(world interfaces.IWorld) {
 		}
 	}
 	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), headerIds, "", node.Time())
-	node.Consensus().ReceivedBlockHeadersEvent(node, ev.headers, ev.senderId, world)
+	node.Consensus().ReceivedBlockHeadersEvent(node, ev.requestId, ev.headers, ev.senderId, world)
 }
@@ -8,12 +8,13 @@ import (
 
 type ReceivedTxsEvent struct {
 	interfaces.IEvent
-	txs      []interfaces.ITransaction
-	senderId string
+	requestId int // 0 for broadcast txs
+	txs       []interfaces.ITransaction
+	senderId  string
 }
 
-func NewReceivedTxsEvent(ev interfaces.IEvent, txs []interfaces.ITransaction, senderId string) *ReceivedTxsEvent {
-	return &ReceivedTxsEvent{ev, txs, senderId}
+func NewReceivedTxsEvent(ev interfaces.IEvent, requestId int, txs []interfaces.ITransaction, senderId string) *ReceivedTxsEvent {
+	return &ReceivedTxsEvent{ev, requestId, txs, senderId}
 }
 
 func (ev *ReceivedTxsEvent) Execute(world interfaces.IWorld) {
@@ -32,5 +33,5 @@ func (ev *ReceivedTxsEvent) Execute(world interfaces.IWorld) {
 		}
 		logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), txHashes, "", node.Time())
 	}
-	node.Consensus().ReceivedTxsEvent(node, ev.txs, ev.senderId, world)
+	node.Consensus().ReceivedTxsEvent(node, ev.requestId, ev.txs, ev.senderId, world)
 }
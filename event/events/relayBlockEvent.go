@@ -0,0 +1,37 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+)
+
+/*
+*
+event that lets a node relay a block to certain peers at a later point in time (i.e. delayed relaying of an attacker)
+*/
+type RelayBlockEvent struct {
+	interfaces.IEvent
+	block     interfaces.IBlock
+	targetIds []string
+}
+
+func NewRelayBlockEvent(ev interfaces.IEvent, block interfaces.IBlock, targetIds []string) *RelayBlockEvent {
+	return &RelayBlockEvent{ev, block, targetIds}
+}
+
+func (ev *RelayBlockEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if node.IsOnline() {
+		if ev.Time() > node.Time() {
+			node.SetTime(ev.Time())
+		}
+		logger.AuditEvent(node.Id(), ev.Type(), ev.block.Hash(), "", node.Time())
+		targets := make([]interfaces.INode, 0, len(ev.targetIds))
+		for _, targetId := range ev.targetIds {
+			if target, ok := world.Nodes()[targetId]; ok {
+				targets = append(targets, target)
+			}
+		}
+		node.Network().BroadcastBlock(ev.block, node, world, targets...)
+	}
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+)
+
+/*
+*
+event that lets a node relay transactions to certain peers at a later point in time (i.e. delayed relaying of an attacker)
+*/
+type RelayTxsEvent struct {
+	interfaces.IEvent
+	txs       []interfaces.ITransaction
+	targetIds []string
+}
+
+func NewRelayTxsEvent(ev interfaces.IEvent, txs []interfaces.ITransaction, targetIds []string) *RelayTxsEvent {
+	return &RelayTxsEvent{ev, txs, targetIds}
+}
+
+func (ev *RelayTxsEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if node.IsOnline() {
+		if ev.Time() > node.Time() {
+			node.SetTime(ev.Time())
+		}
+		if world.SimConfig().AuditLogTxMessages() {
+			for _, tx := range ev.txs {
+				logger.AuditEvent(node.Id(), ev.Type(), tx.Id(), "", node.Time())
+			}
+		}
+		targets := make([]interfaces.INode, 0, len(ev.targetIds))
+		for _, targetId := range ev.targetIds {
+			if target, ok := world.Nodes()[targetId]; ok {
+				targets = append(targets, target)
+			}
+		}
+		node.Network().BroadcastTxs(ev.txs, node, world, targets...)
+	}
+}
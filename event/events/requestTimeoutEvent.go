@@ -0,0 +1,26 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+)
+
+/*
+*
+event that checks if a request to a peer was answered within the request timeout
+*/
+type RequestTimeoutEvent struct {
+	interfaces.IEvent
+	requestId int
+}
+
+func NewRequestTimeoutEvent(ev interfaces.IEvent, requestId int) *RequestTimeoutEvent {
+	return &RequestTimeoutEvent{ev, requestId}
+}
+
+func (ev *RequestTimeoutEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if ev.Time() > node.Time() {
+		node.SetTime(ev.Time())
+	}
+	node.Consensus().RequestTimeoutEvent(node, ev.requestId, world)
+}
@@ -9,12 +9,13 @@ import (
 
 type RetrieveBlockBodiesEvent struct {
 	interfaces.IEvent
-	hashes   []string
-	senderId string
+	requestId int
+	hashes    []string
+	senderId  string
 }
 
-func NewRetrieveBlockBodiesEvent(ev interfaces.IEvent, hashes []string, senderId string) *RetrieveBlockBodiesEvent {
-	return &RetrieveBlockBodiesEvent{ev, hashes, senderId}
+func NewRetrieveBlockBodiesEvent(ev interfaces.IEvent, requestId int, hashes []string, senderId string) *RetrieveBlockBodiesEvent {
+	return &RetrieveBlockBodiesEvent{ev, requestId, hashes, senderId}
 }
 
 func (ev *RetrieveBlockBodiesEvent) Execute(world interfaces.IWorld) {
@@ -24,5 +25,5 @@ func (ev *RetrieveBlockBodiesEvent) Execute(world interfaces.IWorld) {
 	}
 	metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_BODY_RETRIEVAL, ev.TargetId()), 1)
 	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), strings.Join(ev.hashes, ","), "", node.Time())
-	node.Consensus().RetrieveBlockBodiesEvent(node, ev.hashes, ev.senderId, world)
+	node.Consensus().RetrieveBlockBodiesEvent(node, ev.requestId, ev.hashes, ev.senderId, world)
 }
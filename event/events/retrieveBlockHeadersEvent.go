@@ -9,6 +9,7 @@ import (
 
 type RetrieveBlockHeadersEvent struct {
 	interfaces.IEvent
+	requestId       int
 	originBlockHash string
 	num             int
 	reverse         bool
@@ -16,8 +17,8 @@ type RetrieveBlockHeadersEvent struct {
 	senderId        string
 }
 
-func NewRetrieveBlockHeadersEvent(ev interfaces.IEvent, originBlockHash string, num int, reverse bool, skip int, senderId string) *RetrieveBlockHeadersEvent {
-	return &RetrieveBlockHeadersEvent{ev, originBlockHash, num, reverse, skip, senderId}
+func NewRetrieveBlockHeadersEvent(ev interfaces.IEvent, requestId int, originBlockHash string, num int, reverse bool, skip int, senderId string) *RetrieveBlockHeadersEvent {
+	return &RetrieveBlockHeadersEvent{ev, requestId, originBlockHash, num, reverse, skip, senderId}
 }
 
 func (ev *RetrieveBlockHeadersEvent) Execute(world interfaces.IWorld) {
@@ -27,5 +28,5 @@ func (ev *RetrieveBlockHeadersEvent) Execute(world interfaces.IWorld) {
 	}
 	metrics.Counter(metrics.NameFormat(interfaces.METRIC_BLOCK_HEADER_RETRIEVAL, ev.TargetId()), 1)
 	logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), fmt.Sprintf("originHash:%v,num:%v,reverse:%v,skip%v", ev.originBlockHash, ev.num, ev.reverse, ev.skip), "", node.Time())
-	node.Consensus().RetrieveBlockHeadersEvent(node, ev.originBlockHash, ev.num, ev.reverse, ev.skip, ev.senderId, world)
+	node.Consensus().RetrieveBlockHeadersEvent(node, ev.requestId, ev.originBlockHash, ev.num, ev.reverse, ev.skip, ev.senderId, world)
 }
@@ -9,12 +9,13 @@ import (
 
 type RetrieveTxsEvent struct {
 	interfaces.IEvent
-	txHashes []string
-	senderId string
+	requestId int
+	txHashes  []string
+	senderId  string
 }
 
-func NewRetrieveTxsEventEvent(ev interfaces.IEvent, txHashes []string, senderId string) *RetrieveTxsEvent {
-	return &RetrieveTxsEvent{ev, txHashes, senderId}
+func NewRetrieveTxsEventEvent(ev interfaces.IEvent, requestId int, txHashes []string, senderId string) *RetrieveTxsEvent {
+	return &RetrieveTxsEvent{ev, requestId, txHashes, senderId}
 }
 
 func (ev *RetrieveTxsEvent) Execute(world interfaces.IWorld) {
@@ -26,5 +27,5 @@ func (ev *RetrieveTxsEvent) Execute(world interfaces.IWorld) {
 	if world.SimConfig().AuditLogTxMessages() {
 		logger.AuditEventReceived(ev.TargetId(), ev.senderId, ev.Type(), strings.Join(ev.txHashes, ","), "", node.Time())
 	}
-	node.Consensus().RetrieveTxsEvent(node, ev.txHashes, ev.senderId, world)
+	node.Consensus().RetrieveTxsEvent(node, ev.requestId, ev.txHashes, ev.senderId, world)
 }
@@ -0,0 +1,29 @@
+package events
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/logger"
+	"fmt"
+)
+
+// SlotEvent starts a slot of a proof of stake node (proposal) or the attestation of the node within the slot.
+type SlotEvent struct {
+	interfaces.IEvent
+	slot   int
+	attest bool
+}
+
+func NewSlotEvent(ev interfaces.IEvent, slot int, attest bool) *SlotEvent {
+	return &SlotEvent{ev, slot, attest}
+}
+
+func (ev *SlotEvent) Execute(world interfaces.IWorld) {
+	node := world.Nodes()[ev.TargetId()]
+	if ev.Time() > node.Time() {
+		node.SetTime(ev.Time())
+	}
+	if ev.attest {
+		logger.AuditEvent(node.Id(), ev.Type(), fmt.Sprintf("%v", ev.slot), "attest", node.Time())
+	}
+	node.Consensus().SlotEvent(node, ev.slot, ev.attest, world)
+}
@@ -7,6 +7,8 @@ import (
 	"ethattacksim/util/metrics"
 	"ethattacksim/util/random"
 	"fmt"
+	"math"
+	"sort"
 	ti "time"
 )
 
@@ -21,8 +23,18 @@ func NewTxCreationEvent(ev interfaces.IEvent) *TxCreationEvent {
 	return &TxCreationEvent{ev}
 }
 
+// plannedTx is a user tx drawn by the TxCreationEvent that is not created yet.
+type plannedTx struct {
+	time     int64
+	senderId string
+	nonce    int
+	gas      int
+	targetId string
+}
+
 func (ev *TxCreationEvent) Execute(world interfaces.IWorld) {
 	txPerMin := world.SimConfig().TxPerMin()
+	planned := make([]*plannedTx, 0, int(txPerMin))
 
 	for i := 0; i < int(txPerMin); i++ {
 		randTime := int64(random.Uniform() * 60000000000) // tx will be created in the next 60 seconde
@@ -34,25 +46,65 @@ func (ev *TxCreationEvent) Execute(world interfaces.IWorld) {
 			txGas = world.SimConfig().Limits()["initialGasLimit"] - 500000
 		}
 
-		gasPrice := int(random.GasPrice())
-		specialTxStateComputation := -1.0 // stays at -1 (= not used) for honest nodes
-
 		randTarget := nodeOracle(world.Nodes(), world.NodeIds()).Id()
-		tx := ledger.NewTx(fmt.Sprintf("%v_%v", randSenderId, senderNonce), senderNonce, randSenderId, txGas, gasPrice, true, specialTxStateComputation)
-		world.Queue().Add(NewNewTxEvent(event.NewEvent(ev.Time()+randTime, randTarget, interfaces.RECEIVED_TXS_EVENT), tx, randSenderId))
+		planned = append(planned, &plannedTx{ev.Time() + randTime, randSenderId, senderNonce, txGas, randTarget})
+	}
+	if world.SimConfig().AccountState() {
+		// a user sends its txs in nonce order, so the nonces drawn for a user are handed out by creation time
+		nonces := make(map[string][]int)
+		for _, p := range planned {
+			nonces[p.senderId] = append(nonces[p.senderId], p.nonce)
+		}
+		sort.SliceStable(planned, func(i, j int) bool {
+			return planned[i].time < planned[j].time
+		})
+		for _, p := range planned {
+			p.nonce, nonces[p.senderId] = nonces[p.senderId][0], nonces[p.senderId][1:]
+		}
+	}
+
+	specialTxStateComputation := -1.0 // stays at -1 (= not used) for honest nodes
+
+	for _, p := range planned {
+		tx := NewUserTx(fmt.Sprintf("%v_%v", p.senderId, p.nonce), p.nonce, p.senderId, p.gas, specialTxStateComputation, p.time, world)
+		world.Queue().Add(NewNewTxEvent(event.NewEvent(p.time, p.targetId, interfaces.RECEIVED_TXS_EVENT), tx, p.senderId))
 	}
 	// fire event every minute
 	world.Queue().Add(NewTxCreationEvent(event.NewEvent(ev.Time()+60000000000, "", interfaces.NEW_TX_EVENT)))
 }
 
-func CreateRandomTransactionsForBlock(gasUsed int, world interfaces.IWorld, gasLimit int) ([]interfaces.ITransaction, int) {
+// NewUserTx creates a tx with fees drawn for the fee market of the simulation.
+func NewUserTx(id string, nonce int, senderId string, gasUsed int, specialTxStateComputation float64, creationTime int64, world interfaces.IWorld) interfaces.ITransaction {
+	gasPrice := int(random.GasPrice())
+	if world.SimConfig().FeeMarket() == interfaces.FEE_MARKET_EIP1559 {
+		priorityFee := int(math.Min(float64(random.PriorityFee()), float64(gasPrice)))
+		return ledger.NewEip1559Tx(id, nonce, senderId, gasUsed, gasPrice, priorityFee, true, specialTxStateComputation, creationTime)
+	}
+	return ledger.NewTx(id, nonce, senderId, gasUsed, gasPrice, true, specialTxStateComputation, creationTime)
+}
+
+// CreateRandomTransactionsForBlock creates txs worth the gas up to the gas limit, txs not paying the base fee (wei) are left out.
+// With EIP-1559 the base fee thereby settles where half of the demand is willing to pay it.
+// With account state (pending not nil) the txs are sent from separate accounts of the users, so the nonces of the users' own txs have no gaps,
+// and they are applied to pending.
+func CreateRandomTransactionsForBlock(gasUsed int, world interfaces.IWorld, gasLimit int, baseFee int, pending interfaces.IAccounts, minerId string) ([]interfaces.ITransaction, int) {
 	txs := make([]interfaces.ITransaction, 0)
 	gas := 0
+	demand := 0
 
-	for gas+gasUsed < gasLimit {
-		randSenderId, senderNonce := txSenderOracle(world.Users(), world.UserIds())
+	for demand+gasUsed < gasLimit {
+		var randSenderId, txId string
+		var senderNonce int
+		if pending == nil {
+			randSenderId, senderNonce = txSenderOracle(world.Users(), world.UserIds())
+			txId = fmt.Sprintf("R%v_%v", randSenderId, senderNonce)
+		} else {
+			randSenderId = "R" + userOracle(world.UserIds())
+			senderNonce = pending.Nonce(randSenderId)
+			txId = fmt.Sprintf("%v_%v", randSenderId, senderNonce) // unique in every chain
+		}
 		txGas := int(random.TxGas(world.SimConfig().Limits()["minTxGas"]))
-		if gas+gasUsed+txGas > gasLimit {
+		if demand+gasUsed+txGas > gasLimit {
 			break
 		}
 		if txGas > world.SimConfig().Limits()["initialGasLimit"]-500000 {
@@ -61,10 +113,19 @@ func CreateRandomTransactionsForBlock(gasUsed int, world interfaces.IWorld, gasL
 			txGas = world.SimConfig().Limits()["initialGasLimit"] - 500000
 		}
 
-		gasPrice := int(random.GasPrice())
 		specialTxStateComputation := -1.0 // stays at -1 (= not used) for honest nodes
 
-		tx := ledger.NewTx(fmt.Sprintf("R%v_%v", randSenderId, senderNonce), senderNonce, randSenderId, txGas, gasPrice, true, specialTxStateComputation)
+		tx := NewUserTx(txId, senderNonce, randSenderId, txGas, specialTxStateComputation, -1, world)
+		demand += txGas
+		if tx.EffectiveTip(baseFee) < 0 {
+			continue
+		}
+		if pending != nil {
+			if pending.CheckTx(tx, baseFee) != nil {
+				continue
+			}
+			pending.ApplyTx(tx, minerId, baseFee)
+		}
 		txs = append(txs, tx)
 		gas += txGas
 	}
@@ -84,6 +145,10 @@ func nodeOracle(nodes map[string]interfaces.INode, keySet []string) (selectedNod
 	return
 }
 
+func userOracle(userKeySet []string) string {
+	return userKeySet[int(random.Uniform()*float64(len(userKeySet)))]
+}
+
 func txSenderOracle(users map[string]int, userKeySet []string) (selectedSenderId string, nonce int) {
 	// TX by default are not sent by mining nodes, only by users
 	i := int(random.Uniform() * float64(len(userKeySet)))
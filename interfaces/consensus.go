@@ -4,23 +4,92 @@ import (
 	"errors"
 )
 
+// IFutureBlockQueue holds blocks with an unknown parent or a timestamp too far ahead, keyed by block hash.
+type IFutureBlockQueue interface {
+	// Add queues the block and returns if it was not queued yet and the block dropped because the queue was full.
+	Add(block IBlock, senderId string, maxBlocks int) (added bool, dropped IBlock)
+	Get(hash string) IBlock
+	SenderId(hash string) string
+	Remove(hash string)
+	// Children returns the queued blocks with the parent hash in a deterministic order.
+	Children(parentHash string) []IBlock
+	Len() int
+}
+
+// IRequest is a retrieval sent to a peer, its response carries the request id like the eth/66 messages.
+type IRequest interface {
+	Id() int
+	Kind() string   // REQUEST_HEADERS, REQUEST_BODIES, REQUEST_TXS or REQUEST_BLOCK_TXS
+	PeerId() string // peer the request was sent to
+	// BlockHash returns the origin of the headers or the block of the block txs.
+	BlockHash() string
+	// Hashes returns the requested block hashes (headers and bodies), tx hashes or tx ids of the block.
+	Hashes() []string
+	Num() int     // number of headers
+	Retries() int // times the request was re-sent to another peer
+}
+
+// IRequestTracker holds the requests that are not answered yet and counts the unanswered requests per peer.
+type IRequestTracker interface {
+	// New returns a request under the next request id without storing it, for requests whose answer is not awaited.
+	New(kind string, peerId string, blockHash string, hashes []string, num int, retries int) IRequest
+	// Add stores a new request under the next request id.
+	Add(kind string, peerId string, blockHash string, hashes []string, num int, retries int) IRequest
+	Get(requestId int) IRequest
+	// Remove deletes the request and returns it, nil if it was answered or timed out already.
+	Remove(requestId int) IRequest
+	// AddTimeout counts an unanswered request of the peer and returns the peer's consecutive timeouts.
+	AddTimeout(peerId string) int
+	ResetTimeouts(peerId string)
+	Len() int
+}
+
+// ISync catches a node up with a peer whose total difficulty is far ahead, a node runs one sync at a time.
+type ISync interface {
+	Active() bool
+	PeerId() string
+	// Start syncs up to head, the block the peer announced.
+	Start(node INode, peer INode, head IBlock, world IWorld)
+	// ReceivedHeaders processes the response to a REQUEST_SKELETON or REQUEST_SYNC_HEADERS request.
+	ReceivedHeaders(node INode, request IRequest, headers []IBlockHeader, world IWorld)
+	// ReceivedBodies processes the response to a REQUEST_SYNC_BODIES request and imports the blocks that are complete.
+	ReceivedBodies(node INode, request IRequest, bodies []IBlockBody, world IWorld)
+	// Missing returns the hashes of the request the sync is still waiting for.
+	Missing(request IRequest) []string
+	// PeerHas returns if the peer has the block of a header known to the sync, a peer on another fork does not.
+	PeerHas(peer INode, hash string) bool
+	Abort(node INode, reason string)
+}
+
 type IConsensus interface {
 	BlockSeen() map[string]map[string]bool
 	TxSeen() map[string]map[string]bool
 	RetrievingHeaders() map[string]bool
 	RetrievingBodies() map[string]IBlockHeader
-	FutureBlockQueue() map[string]IBlock
-	FutureBlockQueueSenderId() map[string]string
+	FutureBlocks() IFutureBlockQueue
+	ReconstructingBlocks() map[string]IBlock // compact blocks waiting for their missing txs
+	Requests() IRequestTracker
+	Sync() ISync
 	ReceivedBlockEvent(node INode, block IBlock, senderId string, world IWorld)
 	NewBlockEvent(node INode, block IBlock, world IWorld, evTime int64)
 	ReceivedBlockHashesEvent(node INode, hashes []string, numbers []int, senderId string, world IWorld)
-	RetrieveBlockHeadersEvent(node INode, originBlockHash string, num int, reverse bool, skip int, senderId string, world IWorld)
-	ReceivedTxsEvent(node INode, txs []ITransaction, senderId string, world IWorld)
+	RetrieveBlockHeadersEvent(node INode, requestId int, originBlockHash string, num int, reverse bool, skip int, senderId string, world IWorld)
+	ReceivedTxsEvent(node INode, requestId int, txs []ITransaction, senderId string, world IWorld)
 	ReceivedTxHashesEvent(node INode, txHashes []string, senderId string, world IWorld)
-	RetrieveTxsEvent(node INode, txHashes []string, senderId string, world IWorld)
-	ReceivedBlockHeadersEvent(node INode, headers []IBlockHeader, senderId string, world IWorld)
-	RetrieveBlockBodiesEvent(node INode, hashes []string, senderId string, world IWorld)
-	ReceivedBlockBodiesEvent(node INode, bodies []IBlockBody, senderId string, world IWorld)
+	RetrieveTxsEvent(node INode, requestId int, txHashes []string, senderId string, world IWorld)
+	ReceivedBlockHeadersEvent(node INode, requestId int, headers []IBlockHeader, senderId string, world IWorld)
+	RetrieveBlockBodiesEvent(node INode, requestId int, hashes []string, senderId string, world IWorld)
+	ReceivedBlockBodiesEvent(node INode, requestId int, bodies []IBlockBody, senderId string, world IWorld)
+	ReceivedCompactBlockEvent(node INode, block IBlock, senderId string, world IWorld)
+	RetrieveBlockTxsEvent(node INode, requestId int, blockHash string, txIds []string, senderId string, world IWorld)
+	ReceivedBlockTxsEvent(node INode, requestId int, blockHash string, txs []ITransaction, senderId string, world IWorld)
+	// RequestTimeoutEvent re-sends an unanswered request to another peer that announced the hashes and penalizes the peer.
+	RequestTimeoutEvent(node INode, requestId int, world IWorld)
+	// RejoinEvent is fired when the node comes back online, it catches up with its peers and restarts mining.
+	RejoinEvent(node INode, world IWorld)
+	// SlotEvent is fired at the start of each slot and, if the node attests in the slot, with attest set a third into the slot (proof of stake only).
+	SlotEvent(node INode, slot int, attest bool, world IWorld)
+	ReceivedAttestationsEvent(node INode, attestations []IAttestation, senderId string, world IWorld)
 	// InsertBlock processes a new block.
 	// It returns if a new head was written and if the process was ok.
 	InsertBlock(block IBlock, node INode, ledger ILedger, world IWorld, peerId string, evTime int64) (newHead bool, ok bool)
@@ -30,6 +99,8 @@ type IConsensus interface {
 	// InsertToChain inserts a block to the ledger (only subsequent block numbers allowed.
 	// It returns if a new head was written and if the process was ok.
 	InsertToChain(blocks []IBlock, node INode, ledger ILedger, world IWorld) (newHead bool, ok bool)
+	// ForkChoiceWeights returns the weights of the chain of the current head and of the chain of the block compared by CheckReorg.
+	ForkChoiceWeights(node INode, ledger ILedger, world IWorld, currentHead IBlock, block IBlock) (localWeight int, externalWeight int)
 	CheckReorg(localTd int, externalTd int, block IBlock, currentHead IBlock, node INode) bool
 	WriteBlock(block IBlock, ledger ILedger, node INode, auditPrefix string)
 	AppendBlock(block IBlock, ledger ILedger, node INode, auditPrefix string)
@@ -41,28 +112,48 @@ type IConsensus interface {
 	VerifyState(block IBlock, node INode, checkPastTx bool) (ok bool)
 	VerifyTx(tx ITransaction, node INode) (ok bool)
 	CalcDifficulty(parentHeader IBlockHeader, time int64, world IWorld) int
+	// CalcBaseFee returns the base fee (wei) of the block after parentHeader, 0 without EIP-1559.
+	CalcBaseFee(parentHeader IBlockHeader, world IWorld) int
 	TotalDifficulty(node INode, hash string, ledger ILedger) int
 	MarkBlockSeen(node INode, hash string, peerId string)
 	MarkTxSeen(node INode, hash string, peerId string)
 	RetrieveHeaders(node INode, ledger ILedger, originBlockHash string, num int, reverse bool, skip int) []IBlockHeader
 	RetrieveBodies(node INode, ledger ILedger, hashes []string) []IBlockBody
-	BroadcastNewBlockTargets(node INode, block IBlock, propagate bool, excludeIds ...string) (targets []INode)
-	BroadcastReceivedBlockTargets(node INode, block IBlock, propagate bool, excludeIds ...string) (targets []INode)
-	BroadcastTxTargets(node INode, tx ITransaction, propagate bool, excludeIds ...string) (targets []INode)
+	BroadcastNewBlockTargets(node INode, block IBlock, world IWorld, propagate bool, excludeIds ...string) (targets []INode)
+	BroadcastReceivedBlockTargets(node INode, block IBlock, world IWorld, propagate bool, excludeIds ...string) (targets []INode)
+	BroadcastTxTargets(node INode, tx ITransaction, world IWorld, propagate bool, excludeIds ...string) (targets []INode)
 	MineBlock(ledger ILedger, node INode, world IWorld)
-	GetTxsForBlock(gasUsed int, txs []ITransaction, gasLimit int) (transactions []ITransaction, gasAmount int)
+	// GetMiningTime returns the timestamp (seconds) of the next block found by the node on top of parentHeader and the delay (nanos) until it is found.
+	GetMiningTime(parentHeader IBlockHeader, node INode, world IWorld) (blockTimeStamp int64, miningTimeDelay int64)
+	// GetTxsForBlock selects the txs by effective tip until the gas limit is reached, txs not paying the base fee (wei) are skipped.
+	// With account state (pending not nil) the nonce order of each sender is kept and the selected txs are applied to pending.
+	GetTxsForBlock(gasUsed int, txs []ITransaction, gasLimit int, baseFee int, pending IAccounts, minerId string) (transactions []ITransaction, gasAmount int)
+	// GetTimestamp returns the timestamp (seconds) for the new block, minedTimestamp is the time the block was found.
+	GetTimestamp(parentHeader IBlockHeader, minedTimestamp int64, node INode, world IWorld) (timestamp int64)
 	// GetGasLimit returns the gas limit for the new block.
 	GetGasLimit(currentHead IBlockHeader, world IWorld) (gasLimit int)
 }
 
+// IConsensusStats is implemented by consensus implementations (i.e. attacks) that add their own stats to the stats overview.
+// The returned stats are per node id and per stat type.
+type IConsensusStats interface {
+	ConsensusStats(node INode, world IWorld) map[string]map[string]float64
+}
+
 var (
-	ErrUnknownAncestor = errors.New("unknown ancestor")
-	ErrPrunedAncestor  = errors.New("pruned ancestor")
-	ErrFutureBlock     = errors.New("block in the future")
-	ErrKnownBlock      = errors.New("known block")
-	ErrDanglingUncle   = errors.New("dangling uncle")
-	ErrInvalidHeader   = errors.New("invalid header")
-	ErrInvalidBody     = errors.New("invalid body")
-	ErrUncleIsAncestor = errors.New("uncle is ancestor")
-	ErrOlderBlock      = errors.New("older than parent")
+	ErrUnknownAncestor      = errors.New("unknown ancestor")
+	ErrPrunedAncestor       = errors.New("pruned ancestor")
+	ErrFutureBlock          = errors.New("block in the future")
+	ErrKnownBlock           = errors.New("known block")
+	ErrDanglingUncle        = errors.New("dangling uncle")
+	ErrInvalidHeader        = errors.New("invalid header")
+	ErrInvalidBody          = errors.New("invalid body")
+	ErrUncleIsAncestor      = errors.New("uncle is ancestor")
+	ErrOlderBlock           = errors.New("older than parent")
+	ErrNonceTooLow          = errors.New("nonce too low")
+	ErrNonceTooHigh         = errors.New("nonce too high")
+	ErrInsufficientFunds    = errors.New("insufficient funds for gas * price + value")
+	ErrUnderpriced          = errors.New("transaction underpriced")
+	ErrReplaceUnderpriced   = errors.New("replacement transaction underpriced")
+	ErrAccountLimitExceeded = errors.New("account limit exceeded")
 )
@@ -39,4 +39,19 @@ const (
 	RECEIVED_BLOCK_HASH_EVENT    = eventType("ReceivedBlockHashEvent")
 	RECEIVED_TXS_EVENT           = eventType("ReceivedTxsEvent")
 	RECEIVED_TX_HASHES_EVENT     = eventType("ReceivedTxHashesEvent")
+	RELAY_BLOCK_EVENT            = eventType("RelayBlockEvent")
+	RELAY_TXS_EVENT              = eventType("RelayTxsEvent")
+	PARTITION_HEAL_EVENT         = eventType("PartitionHealEvent")
+	PARTITION_CHECK_EVENT        = eventType("PartitionCheckEvent")
+	SLOT_EVENT                   = eventType("SlotEvent")
+	RECEIVED_ATTESTATIONS_EVENT  = eventType("ReceivedAttestationsEvent")
+	HASH_POWER_CHANGE_EVENT      = eventType("HashPowerChangeEvent")
+	FUTURE_BLOCK_EVENT           = eventType("FutureBlockEvent")
+	RECEIVED_COMPACT_BLOCK_EVENT = eventType("ReceivedCompactBlockEvent")
+	RETRIEVE_BLOCK_TXS_EVENT     = eventType("RetrieveBlockTxsEvent")
+	RECEIVED_BLOCK_TXS_EVENT     = eventType("ReceivedBlockTxsEvent")
+	REQUEST_TIMEOUT_EVENT        = eventType("RequestTimeoutEvent")
+	CHURN_EVENT                  = eventType("ChurnEvent")
+	ONLINE_CHANGE_EVENT          = eventType("OnlineChangeEvent")
+	DOWNLOAD_EVENT               = eventType("DownloadEvent")
 )
@@ -11,10 +11,14 @@ type ILedger interface {
 	PossibleUncles() map[string]IBlockHeader
 	Uncles() map[string]bool
 	Reorg(node INode, newHead IBlock, world IWorld) bool
+	// ReorgTo sets the new head even if more than one block has to be imported to the current chain.
+	ReorgTo(node INode, newHead IBlock, world IWorld) bool
 	// AppendBlockToCurrent writes to both current and normal ledger with state.
 	AppendBlockToCurrent(node INode, block IBlock)
 	WriteBlock(node INode, block IBlock, withState bool)
 	GetBlock(node INode, hash string) IBlock
+	// Children returns the blocks written with the block as parent.
+	Children(node INode, hash string) []IBlock
 	HasBlock(node INode, hash string) bool
 	CurrentHasBlock(node INode, hash string) bool
 	CurrentGetBlockByNumber(node INode, number int) IBlock
@@ -22,12 +26,33 @@ type ILedger interface {
 	HeadHash() string
 	SetHeadHash(headHash string)
 	AddTxsToQueue(node INode, txs ...ITransaction)
+	// AddTxToPool adds a tx to the tx pool if it fits into the limits, the replaced and evicted txs are returned.
+	AddTxToPool(node INode, tx ITransaction) (dropped []ITransaction, err error)
 	QueuedTxs() map[string]ITransaction
 	AddTxs(node INode, txs ...ITransaction)
 	SortedTxByLocalAndRemote(node INode) ([]ITransaction, []ITransaction)
 	KnowsQueuedTx(node INode, hash string) bool
 	GetTx(node INode, hash string) ITransaction
 	Length(node INode) int
+	// Accounts returns the account state after the head, nil without account state.
+	Accounts() IAccounts
+	// AccountsAt returns a copy of the account state after the block, nil without account state.
+	AccountsAt(node INode, hash string) IAccounts
+}
+
+// IAccounts is the balance (gwei) and the nonce of every account, accounts not seen yet have the initial balance.
+type IAccounts interface {
+	Balance(id string) int
+	Nonce(id string) int
+	// CheckTx returns an error if the tx cannot be executed next with the base fee (wei) of its block.
+	CheckTx(tx ITransaction, baseFee int) error
+	// ApplyTx executes the tx without checks, the miner gets the tip.
+	ApplyTx(tx ITransaction, minerId string, baseFee int)
+	// RevertTx exactly undoes ApplyTx.
+	RevertTx(tx ITransaction, minerId string, baseFee int)
+	ApplyBlock(block IBlock)
+	RevertBlock(block IBlock)
+	Copy() IAccounts
 }
 
 type IBlock interface {
@@ -47,6 +72,7 @@ type IBlockHeader interface {
 	Difficulty() int
 	GasUsed() int
 	GasLimit() int
+	BaseFee() int // wei, 0 without EIP-1559
 	MinerId() string
 	Time() int64
 	Number() int
@@ -64,12 +90,28 @@ type IBlockBody interface {
 	IsValid() bool // instead of really computing verification
 }
 
+// IAttestation is the vote of a validator for a head block (LMD-GHOST) and a link from a source to a target checkpoint (Casper FFG).
+type IAttestation interface {
+	Id() string
+	AttesterId() string
+	Slot() int
+	HeadHash() string
+	SourceEpoch() int
+	SourceHash() string
+	TargetEpoch() int
+	TargetHash() string
+}
+
 type ITransaction interface {
 	Id() string
-	Nonce() int // is not used in simulator for now, implement if necessary
+	Nonce() int // checked with account state only
 	SenderId() string
 	GasUsed() int
-	GasPrice() int
+	GasPrice() int       // gwei, max fee per gas for EIP-1559 txs
+	MaxPriorityFee() int // gwei, equals the gas price for legacy txs
+	// EffectiveTip returns the tip per gas (wei) paid to the miner with the base fee (wei), negative if the tx cannot pay the base fee.
+	EffectiveTip(baseFee int) int
 	IsValid() bool             // instead of really computing verification
 	SpecialTxStateComputation() float64 // special tx state computation delay for attacks
+	CreationTime() int64                // nanoseconds, -1 if unknown (i.e. random txs filling blocks)
 }
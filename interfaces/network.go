@@ -1,16 +1,38 @@
 package interfaces
 
 type INetwork interface {
+	// BroadcastBlock sends the full block, or a compact block with PROPAGATION_COMPACT.
 	BroadcastBlock(block IBlock, node INode, world IWorld, targets ...INode)
 	BroadcastBlockHash(hash string, number int, node INode, world IWorld, targets ...INode)
-	RetrieveBlockHeaders(node INode, peer INode, world IWorld, originBlockHash string, num int, reverse bool, skip int)
-	RetrieveBlockBodies(node INode, peer INode, world IWorld, hashes []string)
-	SendBlockHeaders(node INode, peer INode, world IWorld, headers []IBlockHeader)
-	SendBlockBodies(node INode, peer INode, world IWorld, bodies []IBlockBody)
+	RetrieveBlockHeaders(node INode, peer INode, world IWorld, requestId int, originBlockHash string, num int, reverse bool, skip int)
+	RetrieveBlockBodies(node INode, peer INode, world IWorld, requestId int, hashes []string)
+	SendBlockHeaders(node INode, peer INode, world IWorld, requestId int, headers []IBlockHeader)
+	SendBlockBodies(node INode, peer INode, world IWorld, requestId int, bodies []IBlockBody)
+	// RetrieveBlockTxs requests the txs of a compact block missing in the tx pool of the node.
+	RetrieveBlockTxs(node INode, peer INode, world IWorld, requestId int, blockHash string, txIds []string)
+	SendBlockTxs(node INode, peer INode, world IWorld, requestId int, blockHash string, txs []ITransaction)
 	BroadcastTxs(transaction []ITransaction, node INode, world IWorld, targets ...INode)
 	BroadcastTxHashes(txHashes []string, node INode, world IWorld, targets ...INode)
-	RetrieveTxs(txHashes []string, node INode, peer INode, world IWorld)
+	BroadcastAttestations(attestations []IAttestation, node INode, world IWorld, targets ...INode)
+	RetrieveTxs(txHashes []string, node INode, peer INode, world IWorld, requestId int)
+	// SendTxs answers RetrieveTxs, unlike BroadcastTxs the txs are sent with the request id.
+	SendTxs(txs []ITransaction, node INode, peer INode, world IWorld, requestId int)
 	MaxPeers() int
 	ConnectToPeers(nodeId string, world IWorld)
 	DropPeer(node INode, peerId string, world IWorld)
+	// Link returns the access link of the node, nil without bandwidth limits.
+	Link() ILink
+	SetLink(link ILink)
+}
+
+// ILink is the upload and download capacity of a node shared by all its transfers, a transfer waits until the transfers
+// handed over before it are done.
+type ILink interface {
+	// Uploaded returns the time a message of bytes handed over at time would have left the node, nothing is reserved.
+	Uploaded(time int64, bytes int) int64
+	// Upload reserves the upload of the message and returns the time it has left the node.
+	Upload(time int64, bytes int) int64
+	// Download reserves the download of the message whose first bit arrives at time and returns the time it has been
+	// received, downloads are reserved when the messages arrive so they are served in the order of their arrival.
+	Download(time int64, bytes int) int64
 }
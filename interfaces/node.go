@@ -2,10 +2,16 @@ package interfaces
 
 type INode interface {
 	HashPower() float64
+	SetHashPower(hashPower float64)
 	CpuPower() float64
 	Time() int64
 	IncrementTime(time int64)
 	SetTime(time int64)
+	// ClockTime returns the local clock of the node in nanos, the simulated time shifted by the clock offset and drift.
+	// It is used for block timestamps and the future block check, events are still scheduled in simulated time.
+	ClockTime() int64
+	// SetClock sets the offset (nanos) and the drift (ppm) of the local clock.
+	SetClock(offset int64, drift float64)
 	IsOnline() bool
 	SetOnline(isOnline bool)
 	Id() string
@@ -57,17 +63,7 @@ func (l location) String() string {
 	return string(l)
 }
 
-// add locations here
-const (
-	TOKIO   = location("Tokio")
-	IRELAND = location("Ireland")
-	OHIO    = location("Ohio")
-)
-
-var LOCATION_MAP = map[interface{}]ILocation{
-	"Tokio":   TOKIO,
-	"Ireland": IRELAND,
-	"Ohio":    OHIO,
-	"":        TOKIO,
-	nil:       OHIO,
+// NewLocation returns the region of the name, regions are the locations of delays.yml.
+func NewLocation(name string) ILocation {
+	return location(name)
 }
@@ -22,10 +22,38 @@ type IConfig interface {
 	Limits() map[string]int
 	Sizes() map[string]int
 	AttackerActive() bool
-	Attacker() IAttackerConfig
+	Attacker() IAttackerConfig // first attacker group, use Attackers() for coalitions of several groups
+	Attackers() []IAttackerConfig
+	PartitionActive() bool
+	Partition() IPartitionConfig
+	PoolMembersActive() bool
+	PoolMembers() IPoolMembersConfig
+	BlockReward() float64       // eth
+	BlockNephewReward() float64 // eth
+	Consensus() string          // CONSENSUS_POW or CONSENSUS_POS
+	ForkChoice() string         // FORK_CHOICE_TOTAL_DIFFICULTY, FORK_CHOICE_LONGEST_CHAIN or FORK_CHOICE_GHOST, proof of work only
+	Pos() IPosConfig
+	Difficulty() string     // DIFFICULTY_SIMPLE or DIFFICULTY_ETHEREUM
+	GenesisDifficulty() int // MH, proof of work with DIFFICULTY_ETHEREUM only
+	GenesisNumber() uint64  // block number of the genesis block for the difficulty bomb
+	HashPowerChanges() []IHashPowerChangeConfig
+	FeeMarket() string // FEE_MARKET_LEGACY or FEE_MARKET_EIP1559
+	AccountState() bool
+	InitialBalance() float64  // eth of every account with account state
+	ClockSkewActive() bool    // nodes have local clocks with an offset and drift, otherwise they use the simulated time
+	ClockSkew() IClockSkewConfig
+	BlockPropagation() string // PROPAGATION_SQRT, PROPAGATION_FULL, PROPAGATION_ANNOUNCE or PROPAGATION_COMPACT
+	TxPropagation() string    // PROPAGATION_SQRT, PROPAGATION_FULL or PROPAGATION_ANNOUNCE
+	ChurnActive() bool        // nodes go offline and come back online
+	Churn() IChurnConfig
+	TopologyActive() bool     // the initial peers are a loaded or generated graph instead of random picks
+	Topology() ITopologyConfig
+	BandwidthActive() bool    // transfers queue on the upload and download links of the nodes
+	Bandwidth() IBandwidthConfig
 }
 
 type IAttackerConfig interface {
+	Name() string // unique name of the attacker group, defaults to the type
 	Type() string
 	HashPower() []float64
 	MaxPeers() []int
@@ -35,6 +63,156 @@ type IAttackerConfig interface {
 	Strings() map[string]string
 }
 
+type IPartitionConfig interface {
+	Start() int64 // nanos since sim start
+	End() int64   // nanos since sim start
+	Groups() [][]string
+	Group(location ILocation) int // index of the group of the location, -1 if the location is in no group
+	Mode() string
+	Delay() int64 // nanos
+}
+
+const (
+	CONSENSUS_POW = "pow"
+	CONSENSUS_POS = "pos" // Gasper, the hash power of the nodes is used as their stake
+)
+
+const (
+	FORK_CHOICE_TOTAL_DIFFICULTY = "totalDifficulty"
+	FORK_CHOICE_LONGEST_CHAIN    = "longestChain"
+	FORK_CHOICE_GHOST            = "ghost" // heaviest subtree, siblings and uncles count toward the weight
+)
+
+const (
+	DIFFICULTY_SIMPLE   = "simple"   // fixed adjustment, mining time only depends on the share of the hash power
+	DIFFICULTY_ETHEREUM = "ethereum" // Byzantium formula with difficulty bomb, mining time depends on difficulty and hash power
+)
+
+const (
+	FEE_MARKET_LEGACY  = "legacy"  // first price auction, the whole gas price goes to the miner
+	FEE_MARKET_EIP1559 = "eip1559" // base fee is burnt, only the tip goes to the miner
+)
+
+const (
+	PROPAGATION_SQRT     = "sqrt"     // full object to sqrt(peers), hash to the rest
+	PROPAGATION_FULL     = "full"     // full object to all peers
+	PROPAGATION_ANNOUNCE = "announce" // hash to all peers, they fetch the object
+	PROPAGATION_COMPACT  = "compact"  // compact block to all peers, they fetch only the txs missing in their tx pool (blocks only)
+)
+
+const (
+	TOPOLOGY_FILE            = "file"           // GraphML or edge list
+	TOPOLOGY_ERDOS_RENYI     = "erdosRenyi"     // random graph
+	TOPOLOGY_BARABASI_ALBERT = "barabasiAlbert" // scale-free graph by preferential attachment
+	TOPOLOGY_SMALL_WORLD     = "smallWorld"     // Watts-Strogatz ring with rewired edges
+)
+
+const (
+	NODE_CLASS_NODE     = "node"
+	NODE_CLASS_POOL     = "pool"
+	NODE_CLASS_ATTACKER = "attacker"
+)
+
+const (
+	REQUEST_HEADERS      = "headers"
+	REQUEST_BODIES       = "bodies"
+	REQUEST_TXS          = "txs"
+	REQUEST_BLOCK_TXS    = "blockTxs"    // missing txs of a compact block
+	REQUEST_SKELETON     = "skeleton"    // every syncBatch-th header down from the sync head
+	REQUEST_SYNC_HEADERS = "syncHeaders" // headers filling a gap of the skeleton
+	REQUEST_SYNC_BODIES  = "syncBodies"
+)
+
+const (
+	EIP1559_ELASTICITY_MULTIPLIER       = 2 // gas limit is twice the gas target
+	EIP1559_BASE_FEE_CHANGE_DENOMINATOR = 8 // max change of the base fee per block is 1/8
+)
+
+// IDistributionConfig is a distribution by name with its parameters as in delays.yml.
+type IDistributionConfig interface {
+	Name() string
+	Parameters() []float64
+}
+
+// IClockSkewConfig holds the distributions the local clock of every node is drawn from.
+type IClockSkewConfig interface {
+	Offset() IDistributionConfig // millis the clock is ahead of the simulated time
+	Drift() IDistributionConfig  // ppm the clock runs fast
+}
+
+// IHashPowerChangeConfig multiplies the hash power of nodes at a point in time, i.e. to simulate miners joining or leaving.
+type IHashPowerChangeConfig interface {
+	Time() int64 // nanos since sim start
+	Nodes() []string
+	Factor() float64
+}
+
+// IChurnConfig holds the distributions of the online and offline times per node class and fixed online changes.
+type IChurnConfig interface {
+	Classes() map[string]IChurnClassConfig // NODE_CLASS_NODE, NODE_CLASS_POOL or NODE_CLASS_ATTACKER
+	Schedule() []IOnlineChangeConfig
+}
+
+type IChurnClassConfig interface {
+	Session() IDistributionConfig  // seconds a node stays online
+	Downtime() IDistributionConfig // seconds a node stays offline
+}
+
+// ITopologyConfig selects the graph of the initial peers, a file or a generator.
+type ITopologyConfig interface {
+	Model() string     // TOPOLOGY_FILE, TOPOLOGY_ERDOS_RENYI, TOPOLOGY_BARABASI_ALBERT or TOPOLOGY_SMALL_WORLD
+	File() string      // GraphML (.graphml) or edge list with TOPOLOGY_FILE
+	Degree() int       // mean degree of the generated graphs
+	Rewiring() float64 // probability an edge is rewired with TOPOLOGY_SMALL_WORLD
+}
+
+// IBandwidthConfig holds the link capacities per node class, nodes of a class without link keep the throughputs of their
+// locations.
+type IBandwidthConfig interface {
+	Classes() map[string]ILinkConfig // NODE_CLASS_NODE, NODE_CLASS_POOL or NODE_CLASS_ATTACKER
+}
+
+type ILinkConfig interface {
+	Upload() float64   // Mbit/s
+	Download() float64 // Mbit/s
+}
+
+// IOnlineChangeConfig is a fixed change of the online state of nodes, applied in addition to the churn of the node classes.
+type IOnlineChangeConfig interface {
+	Time() int64 // nanos since sim start
+	Nodes() []string
+	Online() bool
+}
+
+type IPosConfig interface {
+	SlotTime() int64 // seconds
+	SlotsPerEpoch() int
+}
+
+type IPoolMembersConfig interface {
+	ShareDifficulty() float64 // MH per share
+	PoolFee() float64         // share of the rewards kept by the pool operator, 0.01 = 1%
+	PplnsWindow() int         // last N shares paid with PPLNS
+	Pools() []IPoolConfig
+}
+
+type IPoolConfig interface {
+	Pool() int // index of the pool in miningPoolsHashPower, 1 = node_pool1
+	Members() []float64
+	Withholders() []float64
+	Infiltrators() []IPoolInfiltratorConfig
+}
+
+type IPoolInfiltratorConfig interface {
+	Owner() int         // index of the infiltrating pool in miningPoolsHashPower
+	HashPower() float64 // MH/s moved from the owner into the infiltrated pool
+}
+
+// IStatsProvider is implemented by everything adding own stats to the stats overview (i.e. scenarios like a network partition).
+type IStatsProvider interface {
+	Stats(world IWorld) map[string]map[string]float64
+}
+
 type metricName string
 
 type IMetricName interface {
@@ -67,7 +245,7 @@ const (
 	METRIC_BLOCK_HASH_RECEIVED    = metricName("BlockHashReceived")
 	METRIC_BLOCK_HEADER_RETRIEVAL = metricName("BlockHeaderRetrieval")
 	METRIC_BLOCK_BODY_RETRIEVAL   = metricName("BlockBodyRetrieval")
-	METRIC_BLOCK_INSERT  		  = metricName("BlockInsert")
+	METRIC_BLOCK_INSERT           = metricName("BlockInsert")
 	METRIC_TX_GAS                 = metricName("TxGas")
 	METRIC_TX_PRICE               = metricName("TxPrice")
 	METRIC_TX_RETRIEVAL           = metricName("TxRetrieval")
@@ -75,12 +253,36 @@ const (
 	METRIC_TX_SENT                = metricName("TxSent")
 	METRIC_TX_HASH_RECEIVED       = metricName("TxHashReceived")
 	METRIC_TX_HASH_SENT           = metricName("TxHashSent")
+	METRIC_TX_DROPPED             = metricName("TxDropped")
+	METRIC_TX_EVICTED             = metricName("TxEvicted")
+	METRIC_TX_REPLACED            = metricName("TxReplaced")
 	METRIC_BLOCK_SENT             = metricName("BlockSent")
+	METRIC_COMPACT_BLOCK_SENT     = metricName("CompactBlockSent")
+	METRIC_COMPACT_BLOCK_RECEIVED = metricName("CompactBlockReceived")
+	METRIC_COMPACT_BLOCK_MISSING  = metricName("CompactBlockMissingTxs")
+	METRIC_BLOCK_TXS_RETRIEVAL    = metricName("BlockTxsRetrieval")
+	METRIC_BLOCK_TXS_RECEIVED     = metricName("BlockTxsReceived")
 	METRIC_BLOCK_HASH_SENT        = metricName("BlockHashSent")
 	METRIC_BLOCK_APPENDED         = metricName("BlockAppended")
 	METRIC_BLOCK_WRITTEN          = metricName("BlockWritten")
 	METRIC_BLOCK_WRITTEN_REORG    = metricName("BlockWrittenReorg")
+	METRIC_BLOCK_FUTURE_DISMISSED = metricName("BlockFutureDismissed")
+	METRIC_BLOCK_FUTURE_QUEUED    = metricName("BlockFutureQueued")
+	METRIC_MESSAGE_PARTITIONED    = metricName("MessagePartitioned")
+	METRIC_POOL_BLOCK_WITHHELD    = metricName("PoolBlockWithheld")
+	METRIC_ATTESTATION_SENT       = metricName("AttestationSent")
+	METRIC_ATTESTATION_RECEIVED   = metricName("AttestationReceived")
 	METRIC_PEER_DROPPED           = metricName("PeerDropped")
+	METRIC_REQUEST_TIMEOUT        = metricName("RequestTimeout")
+	METRIC_REQUEST_RETRY          = metricName("RequestRetry")
+	METRIC_NODE_OFFLINE           = metricName("NodeOffline")
+	METRIC_NODE_ONLINE            = metricName("NodeOnline")
+	METRIC_SYNC_STARTED           = metricName("SyncStarted")
+	METRIC_SYNC_FAILED            = metricName("SyncFailed")
+	METRIC_SYNC_BLOCKS            = metricName("SyncBlocks")
+	METRIC_SYNC_DURATION          = metricName("SyncDuration")
 	METRIC_PEER_ADDED             = metricName("PeerAdded")
+	METRIC_UPLOAD_QUEUED          = metricName("UploadQueued")
+	METRIC_DOWNLOAD_QUEUED        = metricName("DownloadQueued")
 	METRIC_EVENT_REAL_TIME        = metricName("EventRealTime")
 )
@@ -21,4 +21,8 @@ type IWorld interface {
 	NewBlockHash() string
 	NewTxId() string
 	SimConfig() IConfig
+	AttackerGroups() map[string][]string // node ids per attacker group name
+	AddAttackerGroupNodes(group string, nodeIds ...string)
+	StatsProviders() []IStatsProvider
+	AddStatsProvider(provider IStatsProvider)
 }
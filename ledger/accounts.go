@@ -0,0 +1,90 @@
+package ledger
+
+import (
+	"ethattacksim/interfaces"
+)
+
+// Accounts is a lightweight account state, block rewards are not credited as only tx execution is of interest.
+type Accounts struct {
+	initialBalance int            // gwei
+	balances       map[string]int // gwei, only accounts that differ from the initial balance
+	nonces         map[string]int
+}
+
+func NewAccounts(initialBalance int) interfaces.IAccounts {
+	return &Accounts{initialBalance, make(map[string]int), make(map[string]int)}
+}
+
+func (accounts *Accounts) Balance(id string) int {
+	if balance, ok := accounts.balances[id]; ok {
+		return balance
+	}
+	return accounts.initialBalance
+}
+
+func (accounts *Accounts) Nonce(id string) int {
+	return accounts.nonces[id]
+}
+
+func (accounts *Accounts) CheckTx(tx interfaces.ITransaction, baseFee int) error {
+	switch {
+	case tx.Nonce() < accounts.Nonce(tx.SenderId()):
+		return interfaces.ErrNonceTooLow
+	case tx.Nonce() > accounts.Nonce(tx.SenderId()):
+		return interfaces.ErrNonceTooHigh
+	case tx.EffectiveTip(baseFee) < 0 || MaxTxCost(tx) > accounts.Balance(tx.SenderId()):
+		return interfaces.ErrInsufficientFunds
+	}
+	return nil
+}
+
+func (accounts *Accounts) ApplyTx(tx interfaces.ITransaction, minerId string, baseFee int) {
+	burnt, tip := txFees(tx, baseFee)
+	accounts.balances[tx.SenderId()] = accounts.Balance(tx.SenderId()) - burnt - tip
+	accounts.balances[minerId] = accounts.Balance(minerId) + tip
+	accounts.nonces[tx.SenderId()] = tx.Nonce() + 1
+}
+
+func (accounts *Accounts) RevertTx(tx interfaces.ITransaction, minerId string, baseFee int) {
+	burnt, tip := txFees(tx, baseFee)
+	accounts.balances[minerId] = accounts.Balance(minerId) - tip
+	accounts.balances[tx.SenderId()] = accounts.Balance(tx.SenderId()) + burnt + tip
+	accounts.nonces[tx.SenderId()] = tx.Nonce()
+}
+
+func (accounts *Accounts) ApplyBlock(block interfaces.IBlock) {
+	for _, tx := range block.Body().Transactions() {
+		accounts.ApplyTx(tx, block.Header().MinerId(), block.Header().BaseFee())
+	}
+}
+
+func (accounts *Accounts) RevertBlock(block interfaces.IBlock) {
+	txs := block.Body().Transactions()
+	for i := len(txs) - 1; i >= 0; i-- {
+		accounts.RevertTx(txs[i], block.Header().MinerId(), block.Header().BaseFee())
+	}
+}
+
+func (accounts *Accounts) Copy() interfaces.IAccounts {
+	balances := make(map[string]int, len(accounts.balances))
+	for id, balance := range accounts.balances {
+		balances[id] = balance
+	}
+	nonces := make(map[string]int, len(accounts.nonces))
+	for id, nonce := range accounts.nonces {
+		nonces[id] = nonce
+	}
+	return &Accounts{accounts.initialBalance, balances, nonces}
+}
+
+// MaxTxCost returns the gwei a sender needs to be able to pay the tx.
+func MaxTxCost(tx interfaces.ITransaction) int {
+	return tx.GasUsed() * tx.GasPrice()
+}
+
+// txFees returns the burnt base fee and the tip of the miner in gwei.
+func txFees(tx interfaces.ITransaction, baseFee int) (burnt int, tip int) {
+	burnt = int(float64(tx.GasUsed()) * float64(baseFee) / 1000000000) // wei to gwei
+	tip = int(float64(tx.GasUsed()) * float64(tx.EffectiveTip(baseFee)) / 1000000000)
+	return
+}
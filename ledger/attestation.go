@@ -0,0 +1,53 @@
+package ledger
+
+import (
+	"ethattacksim/interfaces"
+	"fmt"
+)
+
+type Attestation struct {
+	attesterId  string
+	slot        int
+	headHash    string
+	sourceEpoch int
+	sourceHash  string
+	targetEpoch int
+	targetHash  string
+}
+
+func NewAttestation(attesterId string, slot int, headHash string, sourceEpoch int, sourceHash string, targetEpoch int, targetHash string) interfaces.IAttestation {
+	return &Attestation{attesterId, slot, headHash, sourceEpoch, sourceHash, targetEpoch, targetHash}
+}
+
+// Id is unique as a validator attests only once per slot.
+func (a *Attestation) Id() string {
+	return fmt.Sprintf("%v_%v", a.attesterId, a.slot)
+}
+
+func (a *Attestation) AttesterId() string {
+	return a.attesterId
+}
+
+func (a *Attestation) Slot() int {
+	return a.slot
+}
+
+func (a *Attestation) HeadHash() string {
+	return a.headHash
+}
+
+func (a *Attestation) SourceEpoch() int {
+	return a.sourceEpoch
+}
+
+func (a *Attestation) SourceHash() string {
+	return a.sourceHash
+}
+
+func (a *Attestation) TargetEpoch() int {
+	return a.targetEpoch
+}
+
+func (a *Attestation) TargetHash() string {
+	return a.targetHash
+}
@@ -17,6 +17,7 @@ type BlockHeader struct {
 	BDifficulty int    `json:"d"`
 	BGasUsed    int    `json:"g"`
 	BGasLimit   int    `json:"l"`
+	BBaseFee    int    `json:"bf"` // wei
 	BTime       int64  `json:"t"`
 	BNumber     int    `json:"n"`
 	BSize       int    `json:"s"`
@@ -36,25 +37,32 @@ type Transaction struct {
 	nonce                     int
 	senderId                  string
 	gasUsed                   int
-	gasPrice                  int     // gwei
+	gasPrice                  int     // gwei, max fee per gas for EIP-1559 txs
+	maxPriorityFee            int     // gwei
 	TValid                    bool    `json:"v"`
 	specialTxStateComputation float64 // special tx state computation delay for attacks
+	creationTime              int64   // nanoseconds, -1 if unknown
 }
 
 func NewBlock(header interfaces.IBlockHeader, body interfaces.IBlockBody, totalDifficulty int) interfaces.IBlock {
 	return &Block{header, body, totalDifficulty}
 }
 
-func NewBlockHeader(hash string, txHash string, uncleHash string, parentHash string, minerId string, difficulty int, gasUsed int, gasLimit int, time int64, height int, size int, valid bool) interfaces.IBlockHeader {
-	return &BlockHeader{hash, txHash, uncleHash, parentHash, minerId, difficulty, gasUsed, gasLimit, time, height, size, valid}
+func NewBlockHeader(hash string, txHash string, uncleHash string, parentHash string, minerId string, difficulty int, gasUsed int, gasLimit int, baseFee int, time int64, height int, size int, valid bool) interfaces.IBlockHeader {
+	return &BlockHeader{hash, txHash, uncleHash, parentHash, minerId, difficulty, gasUsed, gasLimit, baseFee, time, height, size, valid}
 }
 
 func NewBlockBody(blockHash string, txs []interfaces.ITransaction, uncles []interfaces.IBlockHeader, valid bool, txCount int) interfaces.IBlockBody {
 	return &BlockBody{blockHash, txs, uncles, valid, txCount}
 }
 
-func NewTx(id string, nonce int, senderId string, gasUsed int, gasPrice int, valid bool, specialTxStateComputation float64) interfaces.ITransaction {
-	return &Transaction{id, nonce, senderId, gasUsed, gasPrice, valid, specialTxStateComputation}
+func NewTx(id string, nonce int, senderId string, gasUsed int, gasPrice int, valid bool, specialTxStateComputation float64, creationTime int64) interfaces.ITransaction {
+	return &Transaction{id, nonce, senderId, gasUsed, gasPrice, gasPrice, valid, specialTxStateComputation, creationTime}
+}
+
+// NewEip1559Tx creates a tx paying the base fee plus at most maxPriorityFee, but not more than maxFeePerGas in total (gwei).
+func NewEip1559Tx(id string, nonce int, senderId string, gasUsed int, maxFeePerGas int, maxPriorityFee int, valid bool, specialTxStateComputation float64, creationTime int64) interfaces.ITransaction {
+	return &Transaction{id, nonce, senderId, gasUsed, maxFeePerGas, maxPriorityFee, valid, specialTxStateComputation, creationTime}
 }
 
 func (block *Block) Hash() string {
@@ -153,6 +161,10 @@ func (header *BlockHeader) GasLimit() int {
 	return header.BGasLimit
 }
 
+func (header *BlockHeader) BaseFee() int {
+	return header.BBaseFee
+}
+
 func (header *BlockHeader) IsValid() bool {
 	return header.BValid
 }
@@ -177,6 +189,18 @@ func (tx *Transaction) GasPrice() int {
 	return tx.gasPrice
 }
 
+func (tx *Transaction) MaxPriorityFee() int {
+	return tx.maxPriorityFee
+}
+
+func (tx *Transaction) EffectiveTip(baseFee int) int {
+	tip := tx.maxPriorityFee * 1000000000 // gwei to wei
+	if maxTip := tx.gasPrice*1000000000 - baseFee; maxTip < tip {
+		return maxTip
+	}
+	return tip
+}
+
 func (tx *Transaction) IsValid() bool {
 	return tx.TValid
 }
@@ -184,3 +208,7 @@ func (tx *Transaction) IsValid() bool {
 func (tx *Transaction) SpecialTxStateComputation() float64 {
 	return tx.specialTxStateComputation
 }
+
+func (tx *Transaction) CreationTime() int64 {
+	return tx.creationTime
+}
@@ -13,13 +13,23 @@ type Ledger struct {
 	state                  map[string]bool
 	LCurrentLedgerByHeight []interfaces.IBlock `json:"c"`
 	headHash               string
-	txQueue                map[string]interfaces.ITransaction
+	txPool                 *TxPool
 	possibleUncles         map[string]interfaces.IBlockHeader // possible uncle block headers
 	uncles                 map[string]bool                    // uncle blocks that are included in the current ledger
+	accounts               interfaces.IAccounts               // account state after the head, nil if not simulated
+	children               map[string][]interfaces.IBlock     // block hash to the written children
 }
 
-func NewLedger() interfaces.ILedger {
-	return &Ledger{make(map[string]interfaces.IBlock), make(map[string]interfaces.IBlock), make(map[string]bool), make([]interfaces.IBlock, 0, 100), "", make(map[string]interfaces.ITransaction, 100), make(map[string]interfaces.IBlockHeader, 2), make(map[string]bool, 100)}
+// NewLedger creates a ledger with a tx pool bounded by the txPool limits.
+func NewLedger(limits map[string]int) interfaces.ILedger {
+	return &Ledger{make(map[string]interfaces.IBlock), make(map[string]interfaces.IBlock), make(map[string]bool), make([]interfaces.IBlock, 0, 100), "", NewTxPool(limits), make(map[string]interfaces.IBlockHeader, 2), make(map[string]bool, 100), nil, make(map[string][]interfaces.IBlock)}
+}
+
+// NewLedgerWithAccounts creates a ledger that keeps the account state of the current chain, initialBalance is in gwei.
+func NewLedgerWithAccounts(limits map[string]int, initialBalance int) interfaces.ILedger {
+	ledger := NewLedger(limits).(*Ledger)
+	ledger.accounts = NewAccounts(initialBalance)
+	return ledger
 }
 
 func (ledger *Ledger) Get() map[string]interfaces.IBlock {
@@ -59,6 +69,14 @@ func (ledger *Ledger) Reorg(node interfaces.INode, newHead interfaces.IBlock, wo
 		ledgerByHeightRemove := node.Ledger().CurrentLedgerByHeight()[parentFromHead.Header().Number()+1:]
 		ledgerByHeightNew := node.Ledger().CurrentLedgerByHeight()[:parentFromHead.Header().Number()+1]
 
+		if node.Ledger().Accounts() != nil {
+			// undo the txs of the removed blocks, newest first
+			for i := len(ledgerByHeightRemove) - 1; i >= 0; i-- {
+				node.Ledger().Accounts().RevertBlock(ledgerByHeightRemove[i])
+			}
+			node.Ledger().Accounts().ApplyBlock(newHead)
+		}
+
 		// handle blocks to remove
 		for _, oldBlock := range ledgerByHeightRemove {
 			if _, ok := node.Ledger().GetCurrent()[oldBlock.Hash()]; !ok {
@@ -92,7 +110,7 @@ func (ledger *Ledger) Reorg(node interfaces.INode, newHead interfaces.IBlock, wo
 		// add new head to current ledger
 		node.Ledger().SetCurrentLedgerByHeight(append(ledgerByHeightNew, newHead))
 		node.Ledger().GetCurrent()[newHead.Hash()] = newHead
-		node.Ledger().Get()[newHead.Hash()] = newHead
+		ledger.add(newHead)
 		node.Ledger().State()[newHead.Hash()] = true
 		node.Ledger().SetHeadHash(newHead.Hash())
 		// add uncles of new block and delete them from possible uncles
@@ -103,27 +121,56 @@ func (ledger *Ledger) Reorg(node interfaces.INode, newHead interfaces.IBlock, wo
 		delete(node.Ledger().PossibleUncles(), newHead.Hash())
 		// add txs of new block to current ledger
 		node.Ledger().AddTxs(node, newHead.Body().Transactions()...)
+		ledger.txPool.Reset(node.Id(), node.Ledger().Accounts())
 		return true
 	}
 	return false
 }
 
+func (ledger *Ledger) ReorgTo(node interfaces.INode, newHead interfaces.IBlock, world interfaces.IWorld) bool {
+	// collect blocks not in current ledger (reverse order)
+	blocksToImport := make([]interfaces.IBlock, 0)
+	toImport := newHead
+	for toImport != nil && !node.Ledger().CurrentHasBlock(node, toImport.Hash()) {
+		blocksToImport = append(blocksToImport, toImport)
+		toImport = node.Ledger().GetBlock(node, toImport.ParentHash())
+	}
+	if toImport == nil {
+		return false
+	}
+	if len(blocksToImport) == 0 {
+		// new head is already part of the current ledger
+		return node.Ledger().Reorg(node, newHead, world)
+	}
+	if !node.Ledger().Reorg(node, blocksToImport[len(blocksToImport)-1], world) {
+		return false
+	}
+	for i := len(blocksToImport) - 2; i >= 0; i-- {
+		node.Ledger().AppendBlockToCurrent(node, blocksToImport[i])
+	}
+	return true
+}
+
 func (ledger *Ledger) AppendBlockToCurrent(node interfaces.INode, block interfaces.IBlock) {
-	node.Ledger().Get()[block.Hash()] = block
+	ledger.add(block)
 	node.Ledger().GetCurrent()[block.Hash()] = block
 	node.Ledger().SetCurrentLedgerByHeight(append(node.Ledger().CurrentLedgerByHeight(), block))
 	node.Ledger().SetHeadHash(block.Hash())
 	node.Ledger().State()[block.Hash()] = true
+	if node.Ledger().Accounts() != nil {
+		node.Ledger().Accounts().ApplyBlock(block)
+	}
 	for _, uncle := range block.Body().Uncles() {
 		node.Ledger().Uncles()[uncle.Hash()] = true
 		delete(node.Ledger().PossibleUncles(), uncle.Hash())
 	}
 	delete(node.Ledger().PossibleUncles(), block.Hash())
 	node.Ledger().AddTxs(node, block.Body().Transactions()...)
+	ledger.txPool.Reset(node.Id(), node.Ledger().Accounts())
 }
 
 func (ledger *Ledger) WriteBlock(node interfaces.INode, block interfaces.IBlock, withState bool) {
-	node.Ledger().Get()[block.Hash()] = block
+	ledger.add(block)
 	node.Ledger().State()[block.Hash()] = withState
 	node.Ledger().PossibleUncles()[block.Hash()] = block.Header()
 }
@@ -132,6 +179,18 @@ func (ledger *Ledger) GetBlock(node interfaces.INode, hash string) interfaces.IB
 	return node.Ledger().Get()[hash]
 }
 
+// add writes the block to the ledger and indexes it as child of its parent the first time.
+func (ledger *Ledger) add(block interfaces.IBlock) {
+	if _, exists := ledger.ledger[block.Hash()]; !exists {
+		ledger.children[block.ParentHash()] = append(ledger.children[block.ParentHash()], block)
+	}
+	ledger.ledger[block.Hash()] = block
+}
+
+func (ledger *Ledger) Children(node interfaces.INode, hash string) []interfaces.IBlock {
+	return ledger.children[hash]
+}
+
 func (ledger *Ledger) HasBlock(node interfaces.INode, hash string) bool {
 	_, exists := node.Ledger().Get()[hash]
 	return exists
@@ -162,22 +221,26 @@ func (ledger *Ledger) SetHeadHash(headHash string) {
 	ledger.headHash = headHash
 }
 
+// AddTxsToQueue adds txs to the tx pool, txs not fitting into the limits are silently dropped (i.e. txs of removed blocks).
 func (ledger *Ledger) AddTxsToQueue(node interfaces.INode, txs ...interfaces.ITransaction) {
 	for _, tx := range txs {
-		node.Ledger().QueuedTxs()[tx.Id()] = tx
+		node.Ledger().AddTxToPool(node, tx)
 	}
 }
 
+func (ledger *Ledger) AddTxToPool(node interfaces.INode, tx interfaces.ITransaction) (dropped []interfaces.ITransaction, err error) {
+	return ledger.txPool.Add(tx, node.Id(), node.Ledger().Accounts())
+}
+
+// QueuedTxs returns all txs of the tx pool, pending and queued ones.
 func (ledger *Ledger) QueuedTxs() map[string]interfaces.ITransaction {
-	return ledger.txQueue
+	return ledger.txPool.All()
 }
 
 // also removes from queue if present
 func (ledger *Ledger) AddTxs(node interfaces.INode, txs ...interfaces.ITransaction) {
 	for _, tx := range txs {
-		if _, exists := node.Ledger().QueuedTxs()[tx.Id()]; exists {
-			delete(node.Ledger().QueuedTxs(), tx.Id())
-		}
+		ledger.txPool.Remove(tx, node.Ledger().Accounts())
 	}
 }
 
@@ -186,11 +249,14 @@ func (ledger *Ledger) KnowsQueuedTx(node interfaces.INode, hash string) bool {
 	return existsQueue
 }
 
+// SortedTxByLocalAndRemote returns the pending txs sorted by gas price only, the nonce order of a sender is kept by GetTxsForBlock with account state.
 func (ledger *Ledger) SortedTxByLocalAndRemote(node interfaces.INode) ([]interfaces.ITransaction, []interfaces.ITransaction) {
-	// nonce sorting is not done, implement if necessary
 	locals := make([]interfaces.ITransaction, 0)
 	remotes := make([]interfaces.ITransaction, 0, 50)
 	for _, tx := range node.Ledger().QueuedTxs() {
+		if !ledger.txPool.IsPending(tx) {
+			continue
+		}
 		if tx.SenderId() == node.Id() {
 			locals = append(locals, tx)
 		} else {
@@ -213,3 +279,33 @@ func (ledger *Ledger) Length(node interfaces.INode) int {
 func (ledger *Ledger) GetTx(node interfaces.INode, hash string) interfaces.ITransaction {
 	return node.Ledger().QueuedTxs()[hash]
 }
+
+func (ledger *Ledger) Accounts() interfaces.IAccounts {
+	return ledger.accounts
+}
+
+func (ledger *Ledger) AccountsAt(node interfaces.INode, hash string) interfaces.IAccounts {
+	if node.Ledger().Accounts() == nil {
+		return nil
+	}
+	// collect blocks not in current ledger (reverse order)
+	sideBlocks := make([]interfaces.IBlock, 0)
+	ancestor := node.Ledger().GetBlock(node, hash)
+	for ancestor != nil && !node.Ledger().CurrentHasBlock(node, ancestor.Hash()) {
+		sideBlocks = append(sideBlocks, ancestor)
+		ancestor = node.Ledger().GetBlock(node, ancestor.ParentHash())
+	}
+	if ancestor == nil {
+		return nil
+	}
+	// go back from the head to the common ancestor and forward on the side chain
+	accounts := node.Ledger().Accounts().Copy()
+	currentBlocks := node.Ledger().CurrentLedgerByHeight()
+	for i := len(currentBlocks) - 1; i > ancestor.Header().Number(); i-- {
+		accounts.RevertBlock(currentBlocks[i])
+	}
+	for i := len(sideBlocks) - 1; i >= 0; i-- {
+		accounts.ApplyBlock(sideBlocks[i])
+	}
+	return accounts
+}
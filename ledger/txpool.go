@@ -0,0 +1,233 @@
+package ledger
+
+import (
+	"ethattacksim/interfaces"
+	"sort"
+)
+
+// TxPool holds the txs a node knows that are not in its current chain, like geth's txpool it is split into
+// pending (executable) txs and queued txs that have a nonce gap. Without account state all txs are pending.
+// The limits are taken from the limits config, missing limits are unlimited.
+type TxPool struct {
+	all          map[string]interfaces.ITransaction
+	bySender     map[string][]interfaces.ITransaction // sorted by nonce
+	pending      map[string]int                       // executable txs of the sender, the first ones of bySender
+	queued       map[string]int
+	pendingCount int
+	queuedCount  int
+	globalSlots  int // max pending txs
+	accountSlots int // max pending txs per sender
+	globalQueue  int // max queued txs
+	accountQueue int // max queued txs per sender
+	priceBump    int // percent a replacement tx has to pay more than the tx with the same nonce
+}
+
+func NewTxPool(limits map[string]int) *TxPool {
+	return &TxPool{
+		all:          make(map[string]interfaces.ITransaction, 100),
+		bySender:     make(map[string][]interfaces.ITransaction),
+		pending:      make(map[string]int),
+		queued:       make(map[string]int),
+		globalSlots:  limits["txPoolGlobalSlots"],
+		accountSlots: limits["txPoolAccountSlots"],
+		globalQueue:  limits["txPoolGlobalQueue"],
+		accountQueue: limits["txPoolAccountQueue"],
+		priceBump:    limits["txPoolPriceBump"],
+	}
+}
+
+// Add inserts the tx if it fits into the limits. Txs of localId are never evicted and exempt from the per sender limits.
+// It returns the replaced or evicted txs, the tx itself is not added if an error is returned.
+func (pool *TxPool) Add(tx interfaces.ITransaction, localId string, accounts interfaces.IAccounts) (dropped []interfaces.ITransaction, err error) {
+	if _, ok := pool.all[tx.Id()]; ok {
+		return nil, nil
+	}
+	sender := tx.SenderId()
+	if old := pool.getByNonce(sender, tx.Nonce()); old != nil {
+		if !pool.isBumped(old, tx) {
+			return nil, interfaces.ErrReplaceUnderpriced
+		}
+		pool.remove(old, accounts)
+		dropped = append(dropped, old)
+	}
+	pool.insert(tx, accounts)
+
+	// drop the highest nonces of the sender that exceed the per sender limits
+	for sender != localId && pool.exceedsAccountLimits(sender) {
+		last := pool.bySender[sender][len(pool.bySender[sender])-1]
+		pool.remove(last, accounts)
+		if last == tx {
+			pool.restore(dropped, accounts)
+			return nil, interfaces.ErrAccountLimitExceeded
+		}
+		dropped = append(dropped, last)
+	}
+
+	evicted, ok := pool.evict(localId, tx, accounts)
+	if !ok {
+		pool.restore(dropped, accounts)
+		return nil, interfaces.ErrUnderpriced
+	}
+	return append(dropped, evicted...), nil
+}
+
+// Remove deletes the tx, i.e. because it was included in the current chain.
+func (pool *TxPool) Remove(tx interfaces.ITransaction, accounts interfaces.IAccounts) {
+	if _, ok := pool.all[tx.Id()]; ok {
+		pool.remove(tx, accounts)
+	}
+}
+
+// Reset drops the txs with a nonce already used in the new head's state, moves txs between pending and queued and evicts txs over the limits.
+func (pool *TxPool) Reset(localId string, accounts interfaces.IAccounts) {
+	if accounts == nil && pool.globalSlots == 0 {
+		return // everything stays pending and unlimited
+	}
+	for sender, txs := range pool.bySender {
+		if accounts != nil {
+			stale := 0
+			for stale < len(txs) && txs[stale].Nonce() < accounts.Nonce(sender) {
+				delete(pool.all, txs[stale].Id())
+				stale++
+			}
+			txs = txs[stale:]
+			pool.bySender[sender] = txs
+		}
+		pool.classify(sender, accounts)
+	}
+	pool.evict(localId, nil, accounts)
+}
+
+func (pool *TxPool) All() map[string]interfaces.ITransaction {
+	return pool.all
+}
+
+func (pool *TxPool) IsPending(tx interfaces.ITransaction) bool {
+	txs := pool.bySender[tx.SenderId()]
+	pending := pool.pending[tx.SenderId()]
+	return pending > 0 && tx.Nonce() <= txs[pending-1].Nonce()
+}
+
+func (pool *TxPool) insert(tx interfaces.ITransaction, accounts interfaces.IAccounts) {
+	txs := pool.bySender[tx.SenderId()]
+	i := sort.Search(len(txs), func(i int) bool {
+		return txs[i].Nonce() > tx.Nonce()
+	})
+	txs = append(txs, nil)
+	copy(txs[i+1:], txs[i:])
+	txs[i] = tx
+	pool.bySender[tx.SenderId()] = txs
+	pool.all[tx.Id()] = tx
+	pool.classify(tx.SenderId(), accounts)
+}
+
+func (pool *TxPool) remove(tx interfaces.ITransaction, accounts interfaces.IAccounts) {
+	txs := pool.bySender[tx.SenderId()]
+	for i, t := range txs {
+		if t.Id() == tx.Id() {
+			txs = append(txs[:i:i], txs[i+1:]...)
+			break
+		}
+	}
+	pool.bySender[tx.SenderId()] = txs
+	delete(pool.all, tx.Id())
+	pool.classify(tx.SenderId(), accounts)
+}
+
+// restore re-inserts txs dropped by an add that failed.
+func (pool *TxPool) restore(txs []interfaces.ITransaction, accounts interfaces.IAccounts) {
+	for _, tx := range txs {
+		pool.insert(tx, accounts)
+	}
+}
+
+// classify counts the pending txs of the sender, these have consecutive nonces starting at the nonce of the head's state.
+func (pool *TxPool) classify(sender string, accounts interfaces.IAccounts) {
+	txs := pool.bySender[sender]
+	pending := len(txs)
+	if accounts != nil {
+		pending = 0
+		for pending < len(txs) && txs[pending].Nonce() == accounts.Nonce(sender)+pending {
+			pending++
+		}
+	}
+	queued := len(txs) - pending
+	pool.pendingCount += pending - pool.pending[sender]
+	pool.queuedCount += queued - pool.queued[sender]
+	pool.pending[sender], pool.queued[sender] = pending, queued
+	if len(txs) == 0 {
+		delete(pool.bySender, sender)
+		delete(pool.pending, sender)
+		delete(pool.queued, sender)
+	}
+}
+
+func (pool *TxPool) exceedsAccountLimits(sender string) bool {
+	return (pool.accountSlots > 0 && pool.pending[sender] > pool.accountSlots) || (pool.accountQueue > 0 && pool.queued[sender] > pool.accountQueue)
+}
+
+// evict drops the cheapest txs of other senders than localId while the pending or queued txs exceed the global limits,
+// the local txs may exceed them. Only the tx with the highest nonce of a sender is evicted so no gaps are created.
+// The new tx is a candidate as well, it fails if the new tx is the cheapest and leaves the pool unchanged.
+func (pool *TxPool) evict(localId string, tx interfaces.ITransaction, accounts interfaces.IAccounts) (evicted []interfaces.ITransaction, ok bool) {
+	for {
+		overPending := pool.globalSlots > 0 && pool.pendingCount > pool.globalSlots
+		overQueued := pool.globalQueue > 0 && pool.queuedCount > pool.globalQueue
+		if !overPending && !overQueued {
+			return evicted, true
+		}
+		var cheapest interfaces.ITransaction
+		for sender, txs := range pool.bySender {
+			if sender == localId {
+				continue
+			}
+			candidates := make([]interfaces.ITransaction, 0, 2)
+			if overQueued && pool.queued[sender] > 0 {
+				candidates = append(candidates, txs[len(txs)-1])
+			}
+			if overPending && pool.pending[sender] > 0 {
+				candidates = append(candidates, txs[pool.pending[sender]-1])
+			}
+			if tx != nil && sender == tx.SenderId() && (overQueued && !pool.IsPending(tx) || overPending && pool.IsPending(tx)) {
+				candidates = append(candidates, tx)
+			}
+			for _, candidate := range candidates {
+				if cheapest == nil || isCheaper(candidate, cheapest) {
+					cheapest = candidate
+				}
+			}
+		}
+		if cheapest == nil {
+			return evicted, true
+		}
+		if cheapest == tx {
+			pool.remove(tx, accounts)
+			pool.restore(evicted, accounts)
+			return nil, false
+		}
+		pool.remove(cheapest, accounts)
+		evicted = append(evicted, cheapest)
+	}
+}
+
+func (pool *TxPool) getByNonce(sender string, nonce int) interfaces.ITransaction {
+	for _, tx := range pool.bySender[sender] {
+		if tx.Nonce() == nonce {
+			return tx
+		}
+	}
+	return nil
+}
+
+// isBumped checks the replacement rule, the fee cap and the tip of the new tx have to be priceBump percent higher.
+func (pool *TxPool) isBumped(old interfaces.ITransaction, tx interfaces.ITransaction) bool {
+	return tx.GasPrice()*100 >= old.GasPrice()*(100+pool.priceBump) && tx.MaxPriorityFee()*100 >= old.MaxPriorityFee()*(100+pool.priceBump) && tx.GasPrice() > old.GasPrice()
+}
+
+// isCheaper orders by gas price, ties by id because of determinism.
+func isCheaper(tx interfaces.ITransaction, other interfaces.ITransaction) bool {
+	if tx.GasPrice() != other.GasPrice() {
+		return tx.GasPrice() < other.GasPrice()
+	}
+	return tx.Id() > other.Id()
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"ethattacksim/consensus"
 	attackConsensus "ethattacksim/consensus/attack"
+	"ethattacksim/consensus/pool"
 	"ethattacksim/event"
 	"ethattacksim/event/events"
 	"ethattacksim/interfaces"
@@ -12,94 +13,124 @@ import (
 	"ethattacksim/util/file"
 	"ethattacksim/util/random"
 	"ethattacksim/world"
+	"fmt"
 	"log"
 	"math"
 	"sort"
+	"strings"
 )
 
-func createWorldAndState(config *file.Config) interfaces.IWorld {
+func createWorldAndState(config *file.Config, delaysConfig *file.DelaysConfig) interfaces.IWorld {
 
 	// create new event queue
 	queue := event.NewQueue()
 	var simWorld interfaces.IWorld = world.NewWorld(queue, config)
 
-	delaysConfig := file.LoadDelaysConfig()
 	random.InitializeDelays(config.Seed(), delaysConfig)
 
+	// the attributes of the topology nodes replace the drawn locations, hash powers and max peers
+	var topology *file.Topology
+	topologyNodes := make(map[string]*file.TopologyNode)
+	if config.TopologyActive() && config.Topology().Model() == interfaces.TOPOLOGY_FILE {
+		topology = file.LoadTopology(config.Topology().File())
+		topology.Relabel(simulatorNodeIds(config))
+		for _, n := range topology.Nodes {
+			if _, ok := delaysConfig.Locations[n.Location]; n.Location != "" && !ok {
+				log.Panicf("topology node %v location %v is not a location of delays.yml", n.Id, n.Location)
+			}
+			topologyNodes[n.Id] = n
+		}
+	}
+
 	var freePower float64 = config.OverallHashPower()
 	var location interfaces.ILocation
+	var maxPeers int
 
 	// init mining pools
-	for i, poolPower := range config.MiningPoolsHashPower() {
-		location = locationOracle()
+	poolIds := make([]string, 0, len(config.MiningPoolsHashPower()))
+	for range config.MiningPoolsHashPower() {
+		poolIds = append(poolIds, simWorld.NewSpecialNodeId("pool"))
+	}
+	poolPowers, poolMembers := poolMembersOracle(config, poolIds)
+	for i, poolPower := range poolPowers {
+		location = locationOracle(delaysConfig.Regions, config.PoolLocationShares())
+		location, poolPower, maxPeers = topologyAttributes(topologyNodes[poolIds[i]], location, poolPower, poolPeerCountOracle())
 		poolCpuPower := config.MiningPoolsCpuPower()[i]
-		simWorld.AddNodes(node.NewNode(simWorld.NewSpecialNodeId("pool"), poolPower, poolCpuPower, interfaces.FULL_NODE, location, ledger.NewLedger(), network.NewNetwork(poolPeerCountOracle()), consensus.NewConsensus()))
+		poolConsensus := consensus.NewConfiguredConsensus(config)
+		if poolMembers[i] != nil {
+			poolConsensus = pool.NewPoolConsensus(poolConsensus, poolMembers[i], config.PoolMembers())
+		}
+		simWorld.AddNodes(node.NewNode(poolIds[i], poolPower, poolCpuPower, interfaces.FULL_NODE, location, newLedger(config), network.NewNetwork(maxPeers), poolConsensus))
 		freePower -= poolPower
 	}
 	poolsPower := config.OverallHashPower() - freePower
 
+	attackerNodesInitialized := 0
 	if config.AttackerActive() {
-		var attackerNodeIds []string = make([]string, 0, len(simWorld.Nodes()))
-		for i, attackerNodePower := range config.Attacker().HashPower() {
-			attackerNodeCpuPower := config.Attacker().CpuPower()[i]
-			attackerNodeMaxPeers := config.Attacker().MaxPeers()[i]
-			attackerNodeLocation := interfaces.LOCATION_MAP[config.Attacker().Location()[i]]
-			attackerNodeId := simWorld.NewSpecialNodeId("attacker")
-			attackerNodeIds = append(attackerNodeIds, attackerNodeId)
-			switch config.Attacker().Type() {
-			// create special nodes according to attacker type here
-			case "verifiersDilemma":
-				simWorld.AddNodes(node.NewNode(attackerNodeId, attackerNodePower, attackerNodeCpuPower, interfaces.ATTACKER_NODE, attackerNodeLocation, ledger.NewLedger(), network.NewNetwork(attackerNodeMaxPeers), attackConsensus.NewVerifiersDilemmaConsensus(consensus.NewConsensus())))
-				break
-			case "verifiersDilemmaForced":
-				simWorld.AddNodes(node.NewNode(attackerNodeId, attackerNodePower, attackerNodeCpuPower, interfaces.ATTACKER_NODE, attackerNodeLocation, ledger.NewLedger(), network.NewNetwork(attackerNodeMaxPeers), attackConsensus.NewVerifiersDilemmaConsensusForced(consensus.NewConsensus())))
-				break
-			case "selfishMining":
-				simWorld.AddNodes(node.NewNode(attackerNodeId, attackerNodePower, attackerNodeCpuPower, interfaces.ATTACKER_NODE, attackerNodeLocation, ledger.NewLedger(), network.NewNetwork(attackerNodeMaxPeers), attackConsensus.NewSelfishMiningConsensus(consensus.NewConsensus())))
-				break
-			default:
-				simWorld.AddNodes(node.NewNode(attackerNodeId, attackerNodePower, attackerNodeCpuPower, interfaces.ATTACKER_NODE, attackerNodeLocation, ledger.NewLedger(), network.NewNetwork(attackerNodeMaxPeers), attackConsensus.NewVerifiersDilemmaConsensus(consensus.NewConsensus())))
+		for _, attackerConfig := range config.Attackers() {
+			var attackerNodeIds []string = make([]string, 0, len(attackerConfig.HashPower()))
+			newAttackerConsensus := attackConsensus.NewAttackerGroup(simWorld, attackerConfig)
+			for i, attackerNodePower := range attackerConfig.HashPower() {
+				attackerNodeCpuPower := attackerConfig.CpuPower()[i]
+				attackerNodeId := simWorld.NewSpecialNodeId("attacker")
+				attackerNodeLocation, attackerNodePower, attackerNodeMaxPeers := topologyAttributes(topologyNodes[attackerNodeId], interfaces.NewLocation(attackerConfig.Location()[i]), attackerNodePower, attackerConfig.MaxPeers()[i])
+				attackerNodeIds = append(attackerNodeIds, attackerNodeId)
+				simWorld.AddNodes(node.NewNode(attackerNodeId, attackerNodePower, attackerNodeCpuPower, interfaces.ATTACKER_NODE, attackerNodeLocation, newLedger(config), network.NewNetwork(attackerNodeMaxPeers), newAttackerConsensus()))
+				freePower -= attackerNodePower
 			}
-			freePower -= attackerNodePower
-		}
-
-		// peer attacker nodes all together
-		// use sorted node key array because of determinism
-		sort.Strings(attackerNodeIds)
-		for _, nId := range attackerNodeIds {
-			for _, nId2 := range attackerNodeIds {
-				if nId != nId2 {
-					n1 := simWorld.Nodes()[nId]
-					n2 := simWorld.Nodes()[nId2]
-					if !containsPeer(n1, n2) {
-						n1.AddPeers(n2)
-					}
-					if !containsPeer(n2, n1) {
-						n2.AddPeers(n1)
+			simWorld.AddAttackerGroupNodes(attackerConfig.Name(), attackerNodeIds...)
+			attackerNodesInitialized += len(attackerNodeIds)
+
+			// peer attacker nodes of a group all together
+			// use sorted node key array because of determinism
+			sort.Strings(attackerNodeIds)
+			for _, nId := range attackerNodeIds {
+				for _, nId2 := range attackerNodeIds {
+					if nId != nId2 {
+						n1 := simWorld.Nodes()[nId]
+						n2 := simWorld.Nodes()[nId2]
+						if !containsPeer(n1, n2) {
+							n1.AddPeers(n2)
+						}
+						if !containsPeer(n2, n1) {
+							n2.AddPeers(n1)
+						}
 					}
 				}
 			}
 		}
 	}
-
-	attackerNodesInitialized := len(config.Attacker().HashPower())
 	attackerPower := config.OverallHashPower() - poolsPower - freePower
-	if !config.AttackerActive() {
-		attackerNodesInitialized = 0
-	}
 
 	// init other nodes
 	remainingNodes := int(config.NodeCount()) - len(config.MiningPoolsHashPower()) - attackerNodesInitialized
-	avg := freePower / float64(remainingNodes)
+	if _, set := topologyHashPower(topologyNodes, simulatorNodeIds(config)); set > 0 {
+		// the hash power the topology sets for other nodes is reserved, the rest is distributed to the remaining ones
+		reservedPower, reservedNodes := topologyHashPower(topologyNodes, simulatorNodeIds(config)[len(poolIds)+attackerNodesInitialized:])
+		if freePower-reservedPower < float64(remainingNodes-reservedNodes)*minHashPower {
+			log.Panicf("topology hash powers exceed overallHashPower, %v MH/s are left for %v other nodes without hash power, they need at least %v MH/s each", freePower-reservedPower, remainingNodes-reservedNodes, minHashPower)
+		}
+		freePower -= reservedPower
+		remainingNodes -= reservedNodes
+	}
+	avg := 0.0
+	if remainingNodes > 0 {
+		avg = freePower / float64(remainingNodes)
+	}
 
 	log.Printf("created %v pools with %v TH, %v attackers with %v TH power, distributing %v TH (avg %v TH) to %v other nodes\n", len(config.MiningPoolsHashPower()), poolsPower/1000000, attackerNodesInitialized, attackerPower/1000000, freePower/1000000, avg/1000000, remainingNodes)
 
 	for i := 0; i < int(config.NodeCount())-len(config.MiningPoolsHashPower())-attackerNodesInitialized; i++ {
-		location = locationOracle()
+		nodeId := simWorld.NewNodeId()
+		location = locationOracle(delaysConfig.Regions, config.LocationShares())
 		power := hashPowerOracle(avg, freePower, remainingNodes)
-		simWorld.AddNodes(node.NewNode(simWorld.NewNodeId(), power, cpuPowerOracle(), interfaces.FULL_NODE, location, ledger.NewLedger(), network.NewNetwork(peerCountOracle()), consensus.NewConsensus()))
-		remainingNodes--
-		freePower -= power
+		cpuPower := cpuPowerOracle()
+		location, power, maxPeers = topologyAttributes(topologyNodes[nodeId], location, power, peerCountOracle())
+		simWorld.AddNodes(node.NewNode(nodeId, power, cpuPower, interfaces.FULL_NODE, location, newLedger(config), network.NewNetwork(maxPeers), consensus.NewConfiguredConsensus(config)))
+		if n := topologyNodes[nodeId]; n == nil || n.HashPower <= 0 {
+			remainingNodes--
+			freePower -= power
+		}
 	}
 
 	// use sorted node key array because of determinism
@@ -110,9 +141,39 @@ func createWorldAndState(config *file.Config) interfaces.IWorld {
 	sort.Strings(nodeIds)
 	simWorld.AddNodeIds(nodeIds...)
 
+	// init local clocks
+	if config.ClockSkewActive() {
+		random.InitializeClocks(config.Seed(), config.ClockSkew())
+		for _, nId := range nodeIds {
+			simWorld.Nodes()[nId].SetClock(random.ClockOffset(), random.ClockDrift())
+		}
+	}
+
+	// init access links
+	if config.BandwidthActive() {
+		links := config.Bandwidth().Classes()
+		for _, nId := range nodeIds {
+			if link, ok := links[nodeClass(simWorld.Nodes()[nId])]; ok {
+				simWorld.Nodes()[nId].Network().SetLink(network.NewLink(link.Upload(), link.Download()))
+			}
+		}
+	}
+
 	// init peers
-	for _, nId := range nodeIds {
-		simWorld.Nodes()[nId].Network().ConnectToPeers(nId, simWorld)
+	switch {
+	case topology != nil:
+		if skipped := network.ConnectTopology(topology.Edges, simWorld); skipped > 0 {
+			log.Printf("warning: skipped %v edges of topology file %v to nodes that have maxPeers already\n", skipped, config.Topology().File())
+		}
+	case config.TopologyActive():
+		random.InitializeTopology(config.Seed())
+		if skipped := network.ConnectTopology(topologyOracle(config.Topology(), nodeIds), simWorld); skipped > 0 {
+			log.Printf("warning: skipped %v edges of the %v topology to nodes that have maxPeers already\n", skipped, config.Topology().Model())
+		}
+	default:
+		for _, nId := range nodeIds {
+			simWorld.Nodes()[nId].Network().ConnectToPeers(nId, simWorld)
+		}
 	}
 
 	// init non-node users
@@ -131,11 +192,15 @@ func createWorldAndState(config *file.Config) interfaces.IWorld {
 
 	// init genesis event
 	var genHeader interfaces.IBlockHeader
+	genDifficulty := 8
+	if config.Difficulty() == interfaces.DIFFICULTY_ETHEREUM {
+		genDifficulty = config.GenesisDifficulty()
+	}
 	for _, nId := range nodeIds {
 		n := simWorld.Nodes()[nId]
-		genHeader = ledger.NewBlockHeader("GENESIS", "GENESIS", "", "", "GENESIS", 8, -1, simWorld.SimConfig().Limits()["initialGasLimit"], 0, 0, 0, true)
+		genHeader = ledger.NewBlockHeader("GENESIS", "GENESIS", "", "", "GENESIS", genDifficulty, -1, consensus.GasTargetLimit(config), consensus.InitialBaseFee(config), 0, 0, 0, true)
 		body := ledger.NewBlockBody(genHeader.Hash(), make([]interfaces.ITransaction, 0), make([]interfaces.IBlockHeader, 0), true, 0)
-		block := ledger.NewBlock(genHeader, body, 8)
+		block := ledger.NewBlock(genHeader, body, genDifficulty)
 		queue.Add(events.NewGenesisEvent(event.NewEvent(0, n.Id(), interfaces.GENESIS_EVENT), block))
 	}
 
@@ -144,10 +209,84 @@ func createWorldAndState(config *file.Config) interfaces.IWorld {
 		queue.Add(events.NewTxCreationEvent(event.NewEvent(0, "WORLD", interfaces.TX_CREATION_EVENT)))
 	}
 
+	if config.PartitionActive() {
+		// messages are cut by the network between start and end of the partition
+		queue.Add(events.NewPartitionHealEvent(event.NewEvent(config.Partition().End(), "WORLD", interfaces.PARTITION_HEAL_EVENT)))
+	}
+
+	if config.ChurnActive() {
+		// every node of a class with churn starts online and goes offline after its first session
+		random.InitializeChurn(config.Seed(), config.Churn())
+		for _, nId := range nodeIds {
+			class := nodeClass(simWorld.Nodes()[nId])
+			if session, ok := random.ChurnSession(class); ok {
+				queue.Add(events.NewChurnEvent(event.NewEvent(session, nId, interfaces.CHURN_EVENT), false, class))
+			}
+		}
+		for _, change := range config.Churn().Schedule() {
+			for _, nId := range change.Nodes() {
+				if _, ok := simWorld.Nodes()[nId]; !ok {
+					log.Panicf("churn schedule node %v does not exist", nId)
+				}
+			}
+			queue.Add(events.NewOnlineChangeEvent(event.NewEvent(change.Time(), "WORLD", interfaces.ONLINE_CHANGE_EVENT), change))
+		}
+	}
+
+	for _, change := range config.HashPowerChanges() {
+		for _, nId := range change.Nodes() {
+			if _, ok := simWorld.Nodes()[nId]; !ok {
+				log.Panicf("hashPowerChanges node %v does not exist", nId)
+			}
+		}
+		queue.Add(events.NewHashPowerChangeEvent(event.NewEvent(change.Time(), "WORLD", interfaces.HASH_POWER_CHANGE_EVENT), change))
+	}
+
 	log.Print("init complete")
 	return simWorld
 }
 
+// poolMembersOracle returns the hash power of each pool with the hash power moved by infiltrators from their owners to the infiltrated pools,
+// and the members of the pools configured with pool members (nil otherwise).
+func poolMembersOracle(config *file.Config, poolIds []string) ([]float64, [][]*pool.PoolMember) {
+	powers := append([]float64{}, config.MiningPoolsHashPower()...)
+	members := make([][]*pool.PoolMember, len(powers))
+	if !config.PoolMembersActive() {
+		return powers, members
+	}
+	for _, poolConfig := range config.PoolMembers().Pools() {
+		for _, infiltrator := range poolConfig.Infiltrators() {
+			powers[infiltrator.Owner()-1] -= infiltrator.HashPower()
+		}
+	}
+	for _, poolConfig := range config.PoolMembers().Pools() {
+		i := poolConfig.Pool() - 1
+		// members and withholders share the pool's own hash power, a single honest member owns it if none are configured
+		memberFractions := poolConfig.Members()
+		if len(memberFractions)+len(poolConfig.Withholders()) == 0 {
+			memberFractions = []float64{1}
+		}
+		fractions := 0.0
+		for _, fraction := range append(append([]float64{}, memberFractions...), poolConfig.Withholders()...) {
+			fractions += fraction
+		}
+		poolMembers := make([]*pool.PoolMember, 0, len(memberFractions)+len(poolConfig.Withholders())+len(poolConfig.Infiltrators()))
+		for j, fraction := range memberFractions {
+			poolMembers = append(poolMembers, pool.NewPoolMember(fmt.Sprintf("%v_member%v", poolIds[i], j+1), "", powers[i]*fraction/fractions, false))
+		}
+		for j, fraction := range poolConfig.Withholders() {
+			poolMembers = append(poolMembers, pool.NewPoolMember(fmt.Sprintf("%v_withholder%v", poolIds[i], j+1), "", powers[i]*fraction/fractions, true))
+		}
+		for _, infiltrator := range poolConfig.Infiltrators() {
+			ownerId := poolIds[infiltrator.Owner()-1]
+			poolMembers = append(poolMembers, pool.NewPoolMember(fmt.Sprintf("%v_infiltrator_%v", poolIds[i], ownerId), ownerId, infiltrator.HashPower(), true))
+			powers[i] += infiltrator.HashPower()
+		}
+		members[i] = poolMembers
+	}
+	return powers, members
+}
+
 func cpuPowerOracle() float64 {
 	return math.Max(3.3+random.Uniform()*1.2, 3.3) * 1000 // 3.3 - 4.5 GHz
 }
@@ -162,11 +301,13 @@ func peerCountOracle() int {
 	return int(math.Max(15+random.Uniform()*11, 15))
 }
 
+const minHashPower = 1000.0 // MH/s of each other node
+
 func hashPowerOracle(avg float64, remaining float64, remainingCount int) float64 {
 	if remainingCount == 1 {
 		return remaining
 	}
-	minMH := 1000.0
+	minMH := minHashPower
 	maxTimesAvg := 2.5
 	rand := random.Normal()
 	if rand < -1 {
@@ -178,16 +319,102 @@ func hashPowerOracle(avg float64, remaining float64, remainingCount int) float64
 	return math.Max(correctedPower, 1)                                             // to prevent negative hashpower
 }
 
-func locationOracle() interfaces.ILocation {
-	switch int(random.Uniform() * 3) {
-	case 0:
-		return interfaces.TOKIO
-	case 1:
-		return interfaces.IRELAND
-	case 2:
-		return interfaces.OHIO
+// locationOracle draws a region weighted by its share, all regions have the same share if no share is set.
+func locationOracle(regions []string, shares map[string]float64) interfaces.ILocation {
+	share := func(region string) float64 {
+		if len(shares) == 0 {
+			return 1
+		}
+		return shares[region]
+	}
+	total := 0.0
+	for _, region := range regions {
+		total += share(region)
+	}
+	x := random.Uniform() * total
+	last := regions[0]
+	for _, region := range regions {
+		if share(region) <= 0 {
+			continue
+		}
+		if x < share(region) {
+			return interfaces.NewLocation(region)
+		}
+		x -= share(region)
+		last = region
+	}
+	return interfaces.NewLocation(last)
+}
+
+// topologyAttributes returns the location, hash power and max peers of the topology node where they are set.
+func topologyAttributes(n *file.TopologyNode, location interfaces.ILocation, hashPower float64, maxPeers int) (interfaces.ILocation, float64, int) {
+	if n == nil {
+		return location, hashPower, maxPeers
+	}
+	if n.Location != "" {
+		location = interfaces.NewLocation(n.Location)
+	}
+	if n.HashPower > 0 {
+		hashPower = n.HashPower
+	}
+	if n.MaxPeers > 0 {
+		maxPeers = n.MaxPeers
+	}
+	return location, hashPower, maxPeers
+}
+
+// topologyHashPower returns the hash power the topology sets for the nodes and the number of nodes it sets it for.
+func topologyHashPower(topologyNodes map[string]*file.TopologyNode, nodeIds []string) (float64, int) {
+	power, count := 0.0, 0
+	for _, id := range nodeIds {
+		if n := topologyNodes[id]; n != nil && n.HashPower > 0 {
+			power += n.HashPower
+			count++
+		}
+	}
+	return power, count
+}
+
+// simulatorNodeIds returns the ids the nodes will get, pools first, then attackers and other nodes.
+func simulatorNodeIds(config *file.Config) []string {
+	ids := make([]string, 0, config.NodeCount())
+	for i := range config.MiningPoolsHashPower() {
+		ids = append(ids, fmt.Sprintf("node_pool%v", i+1))
+	}
+	if config.AttackerActive() {
+		for _, attackerConfig := range config.Attackers() {
+			for range attackerConfig.HashPower() {
+				ids = append(ids, fmt.Sprintf("node_attacker%v", len(ids)-len(config.MiningPoolsHashPower())+1))
+			}
+		}
+	}
+	for i := 1; len(ids) < int(config.NodeCount()); i++ {
+		ids = append(ids, fmt.Sprintf("node%v", i))
+	}
+	return ids
+}
+
+// topologyOracle generates the edges of the configured model over the sorted node ids.
+func topologyOracle(topology interfaces.ITopologyConfig, nodeIds []string) [][2]string {
+	switch topology.Model() {
+	case interfaces.TOPOLOGY_ERDOS_RENYI:
+		return network.ErdosRenyi(nodeIds, topology.Degree())
+	case interfaces.TOPOLOGY_BARABASI_ALBERT:
+		return network.BarabasiAlbert(nodeIds, topology.Degree())
+	default:
+		return network.SmallWorld(nodeIds, topology.Degree(), topology.Rewiring())
+	}
+}
+
+// nodeClass returns the class of the node for the churn and the bandwidth, pools are identified by their id.
+func nodeClass(n interfaces.INode) string {
+	switch {
+	case n.Type() == interfaces.ATTACKER_NODE:
+		return interfaces.NODE_CLASS_ATTACKER
+	case strings.HasPrefix(n.Id(), "node_pool"):
+		return interfaces.NODE_CLASS_POOL
 	default:
-		return interfaces.TOKIO
+		return interfaces.NODE_CLASS_NODE
 	}
 }
 
@@ -199,3 +426,10 @@ func containsPeer(n1 interfaces.INode, n2 interfaces.INode) bool {
 	}
 	return false
 }
+
+func newLedger(config *file.Config) interfaces.ILedger {
+	if config.AccountState() {
+		return ledger.NewLedgerWithAccounts(config.Limits(), int(config.InitialBalance()*1000000000)) // eth to gwei
+	}
+	return ledger.NewLedger(config.Limits())
+}
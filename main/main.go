@@ -1,6 +1,7 @@
 package main
 
 import (
+	attackConsensus "ethattacksim/consensus/attack"
 	"ethattacksim/util/file"
 	"ethattacksim/util/logger"
 	"ethattacksim/util/metrics"
@@ -27,7 +28,9 @@ func main() {
 
 	// load config
 	config := file.LoadConfig()
-	validation.ValidateConfig(config)
+	validation.ValidateConfig(config, attackConsensus.ValidateAttackerConfig)
+	delaysConfig := file.LoadDelaysConfig()
+	validation.ValidateDelaysConfig(delaysConfig, config)
 
 	interruptChan := make(chan os.Signal, 1)
 	signal.Notify(interruptChan, os.Interrupt)
@@ -50,7 +53,7 @@ func main() {
 			metrics.Initialize(config)
 
 			// init world
-			simWorld := createWorldAndState(config)
+			simWorld := createWorldAndState(config, delaysConfig)
 
 			stopListeningForInterruptChan := make(chan bool, 1)
 			go func() {
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"ethattacksim/event"
+	"ethattacksim/event/events"
+	"ethattacksim/interfaces"
+	"ethattacksim/util/metrics"
+	"ethattacksim/util/random"
+	ti "time"
+)
+
+// Link is the access link of a node, the transfers in each direction are served one after another, uploads in the order
+// they are handed over and downloads in the order they arrive, so large blocks and tx floods hold up the messages behind
+// them.
+type Link struct {
+	upload       float64 // Mbit/s
+	download     float64 // Mbit/s
+	uploadFree   int64   // time the last upload is done
+	downloadFree int64   // time the last download is done
+}
+
+func NewLink(upload float64, download float64) interfaces.ILink {
+	return &Link{upload: upload, download: download}
+}
+
+func (l *Link) Uploaded(time int64, bytes int) int64 {
+	if l.uploadFree > time {
+		time = l.uploadFree
+	}
+	return time + transferTime(bytes, l.upload)
+}
+
+func (l *Link) Upload(time int64, bytes int) int64 {
+	if l.uploadFree > time {
+		metrics.Timer(interfaces.METRIC_UPLOAD_QUEUED.String(), ti.Duration(l.uploadFree-time))
+	}
+	l.uploadFree = l.Uploaded(time, bytes)
+	return l.uploadFree
+}
+
+func (l *Link) Download(time int64, bytes int) int64 {
+	start := time
+	if l.downloadFree > start {
+		start = l.downloadFree
+		metrics.Timer(interfaces.METRIC_DOWNLOAD_QUEUED.String(), ti.Duration(start-time))
+	}
+	l.downloadFree = start + transferTime(bytes, l.download)
+	return l.downloadFree
+}
+
+func transferTime(bytes int, mbps float64) int64 {
+	mbit := float64(bytes) * 8 / 1000000
+	return int64((mbit / mbps) * 1000000000)
+}
+
+// transmit returns the nanos until the message sent at sendStart has left the node including the latency, the time it
+// arrives at the peer and if it arrives at all. A node with a link uploads its messages in the order they are handed
+// over at the node time, otherwise the send throughput between the locations is drawn. For a peer with a link the
+// event time is the arrival of the first bit, the message is downloaded when it arrives (see deliver), otherwise the
+// receive throughput is drawn. The upload is only reserved for messages the partition does not drop.
+func transmit(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, sendStart int64, messageSize int) (int64, int64, bool) {
+	link, peerLink := node.Network().Link(), peer.Network().Link()
+	latSend := random.Latency(node.Location(), peer.Location())
+	if link == nil {
+		latSend += random.SendThroughput(node.Location(), peer.Location(), messageSize)
+	} else {
+		latSend += link.Uploaded(node.Time(), messageSize) - sendStart
+	}
+	eventTime := sendStart + latSend
+	if peerLink == nil {
+		eventTime += random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
+	}
+	eventTime, delivered := partitionEventTime(node, peer, world, sendStart+latSend, eventTime)
+	if delivered && link != nil {
+		link.Upload(node.Time(), messageSize)
+	}
+	return latSend, eventTime, delivered
+}
+
+// deliver queues the message event for the peer, through the download of its link if it has one.
+func deliver(peer interfaces.INode, world interfaces.IWorld, ev interfaces.IEvent, messageSize int) {
+	if peer.Network().Link() == nil {
+		world.Queue().Add(ev)
+		return
+	}
+	world.Queue().Add(events.NewDownloadEvent(event.NewEvent(ev.Time(), peer.Id(), interfaces.DOWNLOAD_EVENT), ev, messageSize))
+}
@@ -14,59 +14,80 @@ import (
 
 type Network struct {
 	maxPeers int
+	link     interfaces.ILink
 }
 
 func NewNetwork(maxPeers int) interfaces.INetwork {
-	return &Network{maxPeers}
+	return &Network{maxPeers: maxPeers}
 }
 
 func (n *Network) BroadcastBlock(block interfaces.IBlock, node interfaces.INode, world interfaces.IWorld, targets ...interfaces.INode) {
+	if world.SimConfig().BlockPropagation() == interfaces.PROPAGATION_COMPACT {
+		n.broadcastCompactBlock(block, node, world, targets...)
+		return
+	}
 	sendStart := node.Time()
 	for _, peer := range targets {
-		latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), block.Header().Size())
-		eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), block.Header().Size())
+		latSend, eventTime, delivered := transmit(node, peer, world, sendStart, block.Header().Size())
 		ev := events.NewReceivedBlockEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_EVENT), block, node.Id())
-		//node.IncrementTime(latSend) // increment node time for sending? is node busy?
 		logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), block.Hash(), "", sendStart+latSend)
-		world.Queue().Add(ev)
+		if delivered {
+			deliver(peer, world, ev, block.Header().Size())
+		}
 		metrics.Timer(interfaces.METRIC_BLOCK_SENT.String(), ti.Duration(eventTime-sendStart))
 		sendStart += latSend
 	}
 }
 
+// broadcastCompactBlock sends the header, the uncles and a short id per tx instead of the txs.
+func (n *Network) broadcastCompactBlock(block interfaces.IBlock, node interfaces.INode, world interfaces.IWorld, targets ...interfaces.INode) {
+	sizes := world.SimConfig().Sizes()
+	messageSize := sizes["header"] + sizes["shortTxId"]*len(block.Body().Transactions()) + sizes["header"]*len(block.Body().Uncles())
+	sendStart := node.Time()
+	for _, peer := range targets {
+		latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+		ev := events.NewReceivedCompactBlockEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_COMPACT_BLOCK_EVENT), block, node.Id())
+		logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), block.Hash(), "", sendStart+latSend)
+		if delivered {
+			deliver(peer, world, ev, messageSize)
+		}
+		metrics.Timer(interfaces.METRIC_COMPACT_BLOCK_SENT.String(), ti.Duration(eventTime-sendStart))
+		sendStart += latSend
+	}
+}
+
 func (n *Network) BroadcastBlockHash(hash string, number int, node interfaces.INode, world interfaces.IWorld, targets ...interfaces.INode) {
 	sendStart := node.Time()
 	for _, peer := range targets {
 		messageSize := world.SimConfig().Sizes()["hash"]
-		latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-		eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
+		latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
 		ev := events.NewReceivedBlockHashesEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_HASH_EVENT), []string{hash}, []int{number}, node.Id())
-		//node.IncrementTime(latSend) // increment node time for sending? is node busy?
 		logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), hash, "", sendStart+latSend)
-		world.Queue().Add(ev)
+		if delivered {
+			deliver(peer, world, ev, messageSize)
+		}
 		metrics.Timer(interfaces.METRIC_BLOCK_HASH_SENT.String(), ti.Duration(eventTime-sendStart))
 		sendStart += latSend
 	}
 }
 
-func (n *Network) RetrieveBlockHeaders(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, originBlockHash string, num int, reverse bool, skip int) {
+func (n *Network) RetrieveBlockHeaders(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int, originBlockHash string, num int, reverse bool, skip int) {
 	messageSize := world.SimConfig().Sizes()["getHeaders"]
 	sendStart := node.Time()
-	latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-	eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
-	ev := events.NewRetrieveBlockHeadersEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RETRIEVE_BLOCK_HEADERS_EVENT), originBlockHash, num, reverse, skip, node.Id())
-	//node.IncrementTime(latSend) // increment node time for sending? is node busy?
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewRetrieveBlockHeadersEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RETRIEVE_BLOCK_HEADERS_EVENT), requestId, originBlockHash, num, reverse, skip, node.Id())
 	logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), fmt.Sprintf("originHash:%v,num:%v,reverse:%v,skip%v", originBlockHash, num, reverse, skip), "", sendStart+latSend)
-	world.Queue().Add(ev)
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
 	metrics.Timer(interfaces.METRIC_BLOCK_HEADER_RETRIEVAL.String(), ti.Duration(eventTime-sendStart))
 }
 
-func (n *Network) SendBlockHeaders(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, headers []interfaces.IBlockHeader) {
+func (n *Network) SendBlockHeaders(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int, headers []interfaces.IBlockHeader) {
 	messageSize := world.SimConfig().Sizes()["header"] * len(headers)
 	sendStart := node.Time()
-	latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-	eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
-	ev := events.NewReceivedBlockHeadersEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_HEADER_EVENT), headers, node.Id())
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewReceivedBlockHeadersEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_HEADER_EVENT), requestId, headers, node.Id())
 	logId := ""
 	for i, header := range headers {
 		logId += header.Hash()
@@ -74,25 +95,26 @@ func (n *Network) SendBlockHeaders(node interfaces.INode, peer interfaces.INode,
 			logId += ","
 		}
 	}
-	//node.IncrementTime(latSend) // increment node time for sending? is node busy?
 	logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), logId, "", sendStart+latSend)
-	world.Queue().Add(ev)
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
 	metrics.Timer(interfaces.METRIC_BLOCK_HEADER_RECEIVED.String(), ti.Duration(eventTime-sendStart))
 }
 
-func (n *Network) RetrieveBlockBodies(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, hashes []string) {
+func (n *Network) RetrieveBlockBodies(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int, hashes []string) {
 	messageSize := world.SimConfig().Sizes()["hash"] * len(hashes)
 	sendStart := node.Time()
-	latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-	eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
-	ev := events.NewRetrieveBlockBodiesEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RETRIEVE_BLOCK_BODIES_EVENT), hashes, node.Id())
-	//node.IncrementTime(latSend) // increment node time for sending? is node busy?
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewRetrieveBlockBodiesEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RETRIEVE_BLOCK_BODIES_EVENT), requestId, hashes, node.Id())
 	logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), strings.Join(hashes, ","), "", sendStart+latSend)
-	world.Queue().Add(ev)
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
 	metrics.Timer(interfaces.METRIC_BLOCK_BODY_RETRIEVAL.String(), ti.Duration(eventTime-sendStart))
 }
 
-func (n *Network) SendBlockBodies(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, bodies []interfaces.IBlockBody) {
+func (n *Network) SendBlockBodies(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int, bodies []interfaces.IBlockBody) {
 	txCount := 0
 	uncleCount := 0
 	logId := ""
@@ -106,23 +128,45 @@ func (n *Network) SendBlockBodies(node interfaces.INode, peer interfaces.INode,
 	}
 	messageSize := world.SimConfig().Sizes()["tx"]*txCount + world.SimConfig().Sizes()["header"]*uncleCount
 	sendStart := node.Time()
-	latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-	eventTime := node.Time() + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
-	ev := events.NewReceivedBlockBodiesEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_BODIES_EVENT), bodies, node.Id())
-	//node.IncrementTime(latSend) // increment node time for sending? is node busy?
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewReceivedBlockBodiesEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_BODIES_EVENT), requestId, bodies, node.Id())
 	logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), logId, "", sendStart+latSend)
-	world.Queue().Add(ev)
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
 	metrics.Timer(interfaces.METRIC_BLOCK_BODY_RECEIVED.String(), ti.Duration(eventTime-sendStart))
 }
 
+func (n *Network) RetrieveBlockTxs(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int, blockHash string, txIds []string) {
+	messageSize := world.SimConfig().Sizes()["hash"] + world.SimConfig().Sizes()["shortTxId"]*len(txIds)
+	sendStart := node.Time()
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewRetrieveBlockTxsEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RETRIEVE_BLOCK_TXS_EVENT), requestId, blockHash, txIds, node.Id())
+	logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), blockHash, fmt.Sprintf("%v", len(txIds)), sendStart+latSend)
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
+	metrics.Timer(interfaces.METRIC_BLOCK_TXS_RETRIEVAL.String(), ti.Duration(eventTime-sendStart))
+}
+
+func (n *Network) SendBlockTxs(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int, blockHash string, txs []interfaces.ITransaction) {
+	messageSize := world.SimConfig().Sizes()["hash"] + world.SimConfig().Sizes()["tx"]*len(txs)
+	sendStart := node.Time()
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewReceivedBlockTxsEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_TXS_EVENT), requestId, blockHash, txs, node.Id())
+	logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), blockHash, fmt.Sprintf("%v", len(txs)), sendStart+latSend)
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
+	metrics.Timer(interfaces.METRIC_BLOCK_TXS_RECEIVED.String(), ti.Duration(eventTime-sendStart))
+}
+
 func (n *Network) BroadcastTxs(transactions []interfaces.ITransaction, node interfaces.INode, world interfaces.IWorld, targets ...interfaces.INode) {
 	sendStart := node.Time()
 	for _, peer := range targets {
 		messageSize := world.SimConfig().Sizes()["tx"] * len(transactions)
-		latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-		eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
-		ev := events.NewReceivedTxsEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_TXS_EVENT), transactions, node.Id())
-		//node.IncrementTime(latSend) // increment node time for sending? is node busy?
+		latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+		ev := events.NewReceivedTxsEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_TXS_EVENT), 0, transactions, node.Id())
 		if world.SimConfig().AuditLogTxMessages() {
 			txHashes := ""
 			for i, tx := range transactions {
@@ -133,7 +177,9 @@ func (n *Network) BroadcastTxs(transactions []interfaces.ITransaction, node inte
 			}
 			logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), txHashes, "", sendStart+latSend)
 		}
-		world.Queue().Add(ev)
+		if delivered {
+			deliver(peer, world, ev, messageSize)
+		}
 		metrics.Timer(interfaces.METRIC_TX_SENT.String(), ti.Duration(eventTime-sendStart))
 		sendStart += latSend
 	}
@@ -143,46 +189,90 @@ func (n *Network) BroadcastTxHashes(txHashes []string, node interfaces.INode, wo
 	sendStart := node.Time()
 	for _, peer := range targets {
 		messageSize := world.SimConfig().Sizes()["hash"] * len(txHashes)
-		latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-		eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
+		latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
 		ev := events.NewReceivedTxHashesEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_TX_HASHES_EVENT), txHashes, node.Id())
-		//node.IncrementTime(latSend) // increment node time for sending? is node busy?
 		if world.SimConfig().AuditLogTxMessages() {
 			logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), strings.Join(txHashes, ","), "", sendStart+latSend)
 		}
-		world.Queue().Add(ev)
+		if delivered {
+			deliver(peer, world, ev, messageSize)
+		}
 		metrics.Timer(interfaces.METRIC_TX_HASH_SENT.String(), ti.Duration(eventTime-sendStart))
 		sendStart += latSend
 	}
 }
 
-func (n *Network) RetrieveTxs(txHashes []string, node interfaces.INode, peer interfaces.INode, world interfaces.IWorld) {
+func (n *Network) BroadcastAttestations(attestations []interfaces.IAttestation, node interfaces.INode, world interfaces.IWorld, targets ...interfaces.INode) {
+	sendStart := node.Time()
+	for _, peer := range targets {
+		messageSize := world.SimConfig().Sizes()["attestation"] * len(attestations)
+		latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+		ev := events.NewReceivedAttestationsEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_ATTESTATIONS_EVENT), attestations, node.Id())
+		logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), events.AttestationIds(attestations), "", sendStart+latSend)
+		if delivered {
+			deliver(peer, world, ev, messageSize)
+		}
+		metrics.Timer(interfaces.METRIC_ATTESTATION_SENT.String(), ti.Duration(eventTime-sendStart))
+		sendStart += latSend
+	}
+}
+
+func (n *Network) RetrieveTxs(txHashes []string, node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int) {
 	messageSize := world.SimConfig().Sizes()["hash"] * len(txHashes)
 	sendStart := node.Time()
-	latSend := random.Latency(node.Location(), peer.Location()) + random.SendThroughput(node.Location(), peer.Location(), messageSize)
-	eventTime := sendStart + latSend + random.ReceiveThroughput(node.Location(), peer.Location(), messageSize)
-	ev := events.NewRetrieveTxsEventEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_HASH_EVENT), txHashes, node.Id())
-	//node.IncrementTime(latSend) // increment node time for sending? is node busy?
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewRetrieveTxsEventEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_BLOCK_HASH_EVENT), requestId, txHashes, node.Id())
 	if world.SimConfig().AuditLogTxMessages() {
 		logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), strings.Join(txHashes, ","), "", sendStart+latSend)
 	}
-	world.Queue().Add(ev)
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
 	metrics.Timer(interfaces.METRIC_TX_RETRIEVAL.String(), ti.Duration(eventTime-sendStart))
 }
 
+func (n *Network) SendTxs(transactions []interfaces.ITransaction, node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, requestId int) {
+	messageSize := world.SimConfig().Sizes()["tx"] * len(transactions)
+	sendStart := node.Time()
+	latSend, eventTime, delivered := transmit(node, peer, world, sendStart, messageSize)
+	ev := events.NewReceivedTxsEvent(event.NewEvent(eventTime, peer.Id(), interfaces.RECEIVED_TXS_EVENT), requestId, transactions, node.Id())
+	if world.SimConfig().AuditLogTxMessages() {
+		txHashes := ""
+		for i, tx := range transactions {
+			txHashes += tx.Id()
+			if i != len(transactions)-1 {
+				txHashes += ","
+			}
+		}
+		logger.AuditEventSent(node.Id(), peer.Id(), ev.Type(), txHashes, "", sendStart+latSend)
+	}
+	if delivered {
+		deliver(peer, world, ev, messageSize)
+	}
+	metrics.Timer(interfaces.METRIC_TX_SENT.String(), ti.Duration(eventTime-sendStart))
+}
+
+func (n *Network) Link() interfaces.ILink {
+	return n.link
+}
+
+func (n *Network) SetLink(link interfaces.ILink) {
+	n.link = link
+}
+
 func (n *Network) MaxPeers() int {
 	return n.maxPeers
 }
 
+// ConnectToPeers connects the node to random online nodes until it has maxPeers, nodes that have maxPeers already are skipped.
 func (n *Network) ConnectToPeers(nodeId string, world interfaces.IWorld) {
-	// TODO: better peer connection algorithm respecting maxPeerCount
 	localNode := world.Nodes()[nodeId]
 	for i := 0; i < localNode.Network().MaxPeers()*2; i++ {
 		if len(localNode.Peers()) >= localNode.Network().MaxPeers() {
 			break
 		}
 		remoteNode := world.Nodes()[PeerOracle(nodeId, world.NodeIds(), world.Nodes())]
-		if remoteNode.Network().MaxPeers() > len(remoteNode.Peers()) {
+		if remoteNode.Network().MaxPeers() > len(remoteNode.Peers()) && remoteNode.IsOnline() {
 			if !ContainsPeer(localNode, remoteNode) {
 				metrics.Counter(metrics.NameFormat(interfaces.METRIC_PEER_ADDED, localNode.Id()), 1)
 				metrics.Counter(interfaces.METRIC_PEER_ADDED.String(), 1)
@@ -210,7 +300,7 @@ func (n *Network) DropPeer(node interfaces.INode, peerId string, world interface
 		if ContainsPeer(node, remoteNode) {
 			continue
 		}
-		if remoteNode.Network().MaxPeers() > len(remoteNode.Peers()) {
+		if remoteNode.Network().MaxPeers() > len(remoteNode.Peers()) && remoteNode.IsOnline() {
 			node.AddPeersToFront(remoteNode) // add "outgoing" peers to front of slice
 			if !ContainsPeer(remoteNode, node) {
 				remoteNode.AddPeers(node) // add "ingoing" peers to end of slice
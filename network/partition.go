@@ -0,0 +1,28 @@
+package network
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/metrics"
+)
+
+// partitionEventTime returns the time a message sent at sendTime arrives and if it arrives at all,
+// as messages between different groups of a configured network partition are dropped or delayed while the network is cut.
+func partitionEventTime(node interfaces.INode, peer interfaces.INode, world interfaces.IWorld, sendTime int64, eventTime int64) (int64, bool) {
+	if !world.SimConfig().PartitionActive() {
+		return eventTime, true
+	}
+	partition := world.SimConfig().Partition()
+	if sendTime < partition.Start() || sendTime >= partition.End() {
+		return eventTime, true
+	}
+	nodeGroup, peerGroup := partition.Group(node.Location()), partition.Group(peer.Location())
+	if nodeGroup < 0 || peerGroup < 0 || nodeGroup == peerGroup {
+		// nodes in no group are connected to all groups
+		return eventTime, true
+	}
+	metrics.Counter(interfaces.METRIC_MESSAGE_PARTITIONED.String(), 1)
+	if partition.Mode() == "delay" {
+		return eventTime + partition.Delay(), true
+	}
+	return eventTime, false
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"ethattacksim/interfaces"
+	"ethattacksim/util/metrics"
+	"ethattacksim/util/random"
+	"math"
+)
+
+// ConnectTopology connects the nodes of every edge in order, the source gets an "outgoing" peer like in ConnectToPeers.
+// Edges to a node that has maxPeers already are skipped, it returns their count.
+func ConnectTopology(edges [][2]string, world interfaces.IWorld) (skipped int) {
+	for _, edge := range edges {
+		localNode, remoteNode := world.Nodes()[edge[0]], world.Nodes()[edge[1]]
+		if localNode == remoteNode || ContainsPeer(localNode, remoteNode) {
+			continue
+		}
+		if len(localNode.Peers()) >= localNode.Network().MaxPeers() || len(remoteNode.Peers()) >= remoteNode.Network().MaxPeers() {
+			skipped++
+			continue
+		}
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_PEER_ADDED, localNode.Id()), 1)
+		metrics.Counter(metrics.NameFormat(interfaces.METRIC_PEER_ADDED, remoteNode.Id()), 1)
+		metrics.Counter(interfaces.METRIC_PEER_ADDED.String(), 2)
+		localNode.AddPeersToFront(remoteNode) // add "outgoing" peers to front of slice
+		remoteNode.AddPeers(localNode)        // add "ingoing" peers to end of slice
+	}
+	return skipped
+}
+
+// ErdosRenyi connects every pair of nodes with the probability that gives the mean degree.
+func ErdosRenyi(nodeIds []string, degree int) (edges [][2]string) {
+	p := float64(degree) / float64(len(nodeIds)-1)
+	for i := range nodeIds {
+		for j := i + 1; j < len(nodeIds); j++ {
+			if random.TopologyUniform() < p {
+				edges = append(edges, [2]string{nodeIds[i], nodeIds[j]})
+			}
+		}
+	}
+	return edges
+}
+
+// BarabasiAlbert starts with a clique of degree/2+1 nodes, every further node connects to degree/2 nodes chosen by
+// preferential attachment. The mean degree is about degree and the degree distribution follows a power law.
+func BarabasiAlbert(nodeIds []string, degree int) (edges [][2]string) {
+	m := int(math.Max(float64(degree/2), 1))
+	ends := make([]string, 0, 2*m*len(nodeIds)) // every node once per edge, so a uniform pick is proportional to the degree
+	for i := 0; i <= m && i < len(nodeIds); i++ {
+		for j := i + 1; j <= m && j < len(nodeIds); j++ {
+			edges = append(edges, [2]string{nodeIds[i], nodeIds[j]})
+			ends = append(ends, nodeIds[i], nodeIds[j])
+		}
+	}
+	for i := m + 1; i < len(nodeIds); i++ {
+		targets := make(map[string]bool, m)
+		for len(targets) < m {
+			target := ends[int(random.TopologyUniform()*float64(len(ends)))]
+			if !targets[target] {
+				targets[target] = true
+				edges = append(edges, [2]string{nodeIds[i], target})
+			}
+		}
+		for _, edge := range edges[len(edges)-m:] {
+			ends = append(ends, edge[0], edge[1])
+		}
+	}
+	return edges
+}
+
+// SmallWorld connects every node to its degree/2 next nodes on a ring (Watts-Strogatz), every edge is rewired to a random
+// node with the rewiring probability. Short paths appear while most of the clustering of the ring is kept.
+func SmallWorld(nodeIds []string, degree int, rewiring float64) (edges [][2]string) {
+	n := len(nodeIds)
+	connected := make(map[[2]int]bool, n*degree/2)
+	for i := 0; i < n; i++ {
+		for j := 1; j <= degree/2 && j < n; j++ {
+			connected[[2]int{i, (i + j) % n}] = true
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 1; j <= degree/2 && j < n; j++ {
+			target := (i + j) % n
+			if random.TopologyUniform() < rewiring {
+				candidate := int(random.TopologyUniform() * float64(n))
+				if candidate != i && !connected[[2]int{i, candidate}] && !connected[[2]int{candidate, i}] {
+					delete(connected, [2]int{i, target})
+					connected[[2]int{i, candidate}] = true
+					target = candidate
+				}
+			}
+			edges = append(edges, [2]string{nodeIds[i], nodeIds[target]})
+		}
+	}
+	return edges
+}
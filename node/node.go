@@ -17,16 +17,23 @@ type Node struct {
 	network    interfaces.INetwork
 	consensus  interfaces.IConsensus
 	nonce      int
+
+	NClockOffset int64   `json:"co,omitempty"` // nanos the local clock is ahead of the simulated time
+	NClockDrift  float64 `json:"cd,omitempty"` // ppm the local clock runs fast
 }
 
 func NewNode(id string, hashPower float64, cpuPower float64, nodeType interfaces.INodeType, location interfaces.ILocation, ledger interfaces.ILedger, network interfaces.INetwork, consensus interfaces.IConsensus) interfaces.INode {
-	return &Node{id, hashPower, cpuPower, true, 0, make([]interfaces.INode, 0, 10), nodeType, location, ledger, network, consensus, 0}
+	return &Node{id, hashPower, cpuPower, true, 0, make([]interfaces.INode, 0, 10), nodeType, location, ledger, network, consensus, 0, 0, 0}
 }
 
 func (node *Node) HashPower() float64 {
 	return node.NHashPower
 }
 
+func (node *Node) SetHashPower(hashPower float64) {
+	node.NHashPower = hashPower
+}
+
 func (node *Node) CpuPower() float64 {
 	return node.NCpuPower
 }
@@ -51,6 +58,15 @@ func (node *Node) SetTime(time int64) {
 	node.time = time
 }
 
+func (node *Node) ClockTime() int64 {
+	return node.time + node.NClockOffset + int64(float64(node.time)*node.NClockDrift/1000000)
+}
+
+func (node *Node) SetClock(offset int64, drift float64) {
+	node.NClockOffset = offset
+	node.NClockDrift = drift
+}
+
 func (node *Node) Id() string {
 	return node.NId
 }
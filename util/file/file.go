@@ -1,6 +1,7 @@
 package file
 
 import (
+	"encoding/csv"
 	"ethattacksim/interfaces"
 	"fmt"
 	"gopkg.in/yaml.v2"
@@ -10,34 +11,62 @@ import (
 )
 
 type Config struct {
-	CSeed                          uint64          `yaml:"seed"`
-	CUseMetrics                    bool            `yaml:"useMetrics"`
-	CUsePprof                      bool            `yaml:"usePprof"`
-	COutPath                       string          `yaml:"outPath"`
-	CPrintLogToConsole             bool            `yaml:"printLogToConsole"`
-	CPrintAuditLogToConsole        bool            `yaml:"printAuditLogToConsole"`
-	CPrintMemStats                 bool            `yaml:"printMemStats"`
-	CEndTime                       int64           `yaml:"endTime"`
-	CNodeCount                     uint64          `yaml:"nodeCount"`
-	CSimulateTransactionCreation   bool            `yaml:"simulateTransactionCreation"`
-	CCheckPastTxWhenVerifyingState bool            `yaml:"checkPastTxWhenVerifyingState"`
-	CAuditLogTxMessages            bool            `yaml:"auditLogTxMessages"`
-	CNoneNodeUsers                 uint64          `yaml:"noneNodeUsers"`
-	CMaxUncleDist                  uint64          `yaml:"maxUncleDist"`
-	CTxPerMin                      uint64          `yaml:"txPerMin"`
-	CBombDelay                     uint64          `yaml:"bombDelay"`
-	COverallHashPower              float64         `yaml:"overallHashPower"`
-	CMiningPoolsHashPower          []float64       `yaml:"miningPoolsHashPower"`
-	CMiningPoolsCpuPower           []float64       `yaml:"miningPoolsCpuPower"`
-	CBlockNephewReward             float64         `yaml:"blockNephewReward"`
-	CBlockReward                   float64         `yaml:"blockReward"`
-	CLimits                        map[string]int  `yaml:"limits"`
-	CSizes                         map[string]int  `yaml:"sizes"`
-	CAttackerActive                bool            `yaml:"attackerActive"`
-	CAttacker                      *AttackerConfig `yaml:"attacker"`
+	CSeed                          uint64                   `yaml:"seed"`
+	CUseMetrics                    bool                     `yaml:"useMetrics"`
+	CUsePprof                      bool                     `yaml:"usePprof"`
+	COutPath                       string                   `yaml:"outPath"`
+	CPrintLogToConsole             bool                     `yaml:"printLogToConsole"`
+	CPrintAuditLogToConsole        bool                     `yaml:"printAuditLogToConsole"`
+	CPrintMemStats                 bool                     `yaml:"printMemStats"`
+	CEndTime                       int64                    `yaml:"endTime"`
+	CNodeCount                     uint64                   `yaml:"nodeCount"`
+	CSimulateTransactionCreation   bool                     `yaml:"simulateTransactionCreation"`
+	CCheckPastTxWhenVerifyingState bool                     `yaml:"checkPastTxWhenVerifyingState"`
+	CAuditLogTxMessages            bool                     `yaml:"auditLogTxMessages"`
+	CNoneNodeUsers                 uint64                   `yaml:"noneNodeUsers"`
+	CMaxUncleDist                  uint64                   `yaml:"maxUncleDist"`
+	CTxPerMin                      uint64                   `yaml:"txPerMin"`
+	CBombDelay                     uint64                   `yaml:"bombDelay"`
+	COverallHashPower              float64                  `yaml:"overallHashPower"`
+	CMiningPoolsHashPower          []float64                `yaml:"miningPoolsHashPower"`
+	CMiningPoolsCpuPower           []float64                `yaml:"miningPoolsCpuPower"`
+	CBlockNephewReward             float64                  `yaml:"blockNephewReward"`
+	CBlockReward                   float64                  `yaml:"blockReward"`
+	CLimits                        map[string]int           `yaml:"limits"`
+	CSizes                         map[string]int           `yaml:"sizes"`
+	CAttackerActive                bool                     `yaml:"attackerActive"`
+	CAttacker                      *AttackerConfig          `yaml:"attacker"`  // single attacker group, ignored if attackers is set
+	CAttackers                     []*AttackerConfig        `yaml:"attackers"` // attacker groups
+	CPartitionActive               bool                     `yaml:"partitionActive"`
+	CPartition                     *PartitionConfig         `yaml:"partition"`
+	CPoolMembersActive             bool                     `yaml:"poolMembersActive"`
+	CPoolMembers                   *PoolMembersConfig       `yaml:"poolMembers"`
+	CConsensus                     string                   `yaml:"consensus"`
+	CForkChoice                    string                   `yaml:"forkChoice"`
+	CPos                           *PosConfig               `yaml:"pos"`
+	CDifficulty                    string                   `yaml:"difficulty"`
+	CGenesisDifficulty             int                      `yaml:"genesisDifficulty"`
+	CGenesisNumber                 uint64                   `yaml:"genesisNumber"`
+	CHashPowerChanges              []*HashPowerChangeConfig `yaml:"hashPowerChanges"`
+	CFeeMarket                     string                   `yaml:"feeMarket"`
+	CAccountState                  bool                     `yaml:"accountState"`
+	CInitialBalance                float64                  `yaml:"initialBalance"`
+	CClockSkewActive               bool                     `yaml:"clockSkewActive"`
+	CClockSkew                     *ClockSkewConfig         `yaml:"clockSkew"`
+	CBlockPropagation              string                   `yaml:"blockPropagation"`
+	CTxPropagation                 string                   `yaml:"txPropagation"`
+	CChurnActive                   bool                     `yaml:"churnActive"`
+	CChurn                         *ChurnConfig             `yaml:"churn"`
+	CLocationShares                map[string]float64       `yaml:"locationShares"`     // share of the nodes per region of delays.yml
+	CPoolLocationShares            map[string]float64       `yaml:"poolLocationShares"` // share of the pools per region of delays.yml
+	CTopologyActive                bool                     `yaml:"topologyActive"`
+	CTopology                      *TopologyConfig          `yaml:"topology"`
+	CBandwidthActive               bool                     `yaml:"bandwidthActive"`
+	CBandwidth                     *BandwidthConfig         `yaml:"bandwidth"`
 }
 
 type AttackerConfig struct {
+	AName      string             `yaml:"name"`
 	AType      string             `yaml:"type"`
 	AHashPower []float64          `yaml:"hashPower"`
 	AMaxPeers  []int              `yaml:"maxPeers"`
@@ -47,6 +76,13 @@ type AttackerConfig struct {
 	AStrings   map[string]string  `yaml:"strings"`
 }
 
+func (config *AttackerConfig) Name() string {
+	if config.AName == "" {
+		return config.AType
+	}
+	return config.AName
+}
+
 func (config *AttackerConfig) Type() string {
 	return config.AType
 }
@@ -75,6 +111,262 @@ func (config *AttackerConfig) Strings() map[string]string {
 	return config.AStrings
 }
 
+type PartitionConfig struct {
+	PStart  int64      `yaml:"start"`
+	PEnd    int64      `yaml:"end"`
+	PGroups [][]string `yaml:"groups"`
+	PMode   string     `yaml:"mode"`
+	PDelay  int64      `yaml:"delay"`
+}
+
+func (config *PartitionConfig) Start() int64 {
+	return config.PStart
+}
+
+func (config *PartitionConfig) End() int64 {
+	return config.PEnd
+}
+
+func (config *PartitionConfig) Groups() [][]string {
+	return config.PGroups
+}
+
+func (config *PartitionConfig) Group(location interfaces.ILocation) int {
+	for i, group := range config.PGroups {
+		for _, groupLocation := range group {
+			if groupLocation == location.String() {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (config *PartitionConfig) Mode() string {
+	return config.PMode
+}
+
+func (config *PartitionConfig) Delay() int64 {
+	return config.PDelay
+}
+
+type PosConfig struct {
+	PSlotTime      int64 `yaml:"slotTime"`
+	PSlotsPerEpoch int   `yaml:"slotsPerEpoch"`
+}
+
+func (config *PosConfig) SlotTime() int64 {
+	return config.PSlotTime
+}
+
+func (config *PosConfig) SlotsPerEpoch() int {
+	return config.PSlotsPerEpoch
+}
+
+type ClockSkewConfig struct {
+	COffset DistributionConfig `yaml:"offset"`
+	CDrift  DistributionConfig `yaml:"drift"`
+}
+
+func (config *ClockSkewConfig) Offset() interfaces.IDistributionConfig {
+	return &config.COffset
+}
+
+func (config *ClockSkewConfig) Drift() interfaces.IDistributionConfig {
+	return &config.CDrift
+}
+
+type ChurnConfig struct {
+	CClasses  map[string]*ChurnClassConfig `yaml:"classes"`
+	CSchedule []*OnlineChangeConfig        `yaml:"schedule"`
+}
+
+func (config *ChurnConfig) Classes() map[string]interfaces.IChurnClassConfig {
+	classes := make(map[string]interfaces.IChurnClassConfig, len(config.CClasses))
+	for class, classConfig := range config.CClasses {
+		if classConfig == nil {
+			classConfig = &ChurnClassConfig{} // a class without distributions, rejected by the validation
+		}
+		classes[class] = classConfig
+	}
+	return classes
+}
+
+func (config *ChurnConfig) Schedule() []interfaces.IOnlineChangeConfig {
+	schedule := make([]interfaces.IOnlineChangeConfig, 0, len(config.CSchedule))
+	for _, change := range config.CSchedule {
+		schedule = append(schedule, change)
+	}
+	return schedule
+}
+
+type TopologyConfig struct {
+	TModel    string  `yaml:"model"`
+	TFile     string  `yaml:"file"`
+	TDegree   int     `yaml:"degree"`
+	TRewiring float64 `yaml:"rewiring"`
+}
+
+func (config *TopologyConfig) Model() string {
+	return config.TModel
+}
+
+func (config *TopologyConfig) File() string {
+	return config.TFile
+}
+
+func (config *TopologyConfig) Degree() int {
+	return config.TDegree
+}
+
+func (config *TopologyConfig) Rewiring() float64 {
+	return config.TRewiring
+}
+
+type BandwidthConfig struct {
+	BClasses map[string]*LinkConfig `yaml:"classes"`
+}
+
+func (config *BandwidthConfig) Classes() map[string]interfaces.ILinkConfig {
+	classes := make(map[string]interfaces.ILinkConfig, len(config.BClasses))
+	for class, link := range config.BClasses {
+		if link == nil {
+			link = &LinkConfig{} // a class without capacities, rejected by the validation
+		}
+		classes[class] = link
+	}
+	return classes
+}
+
+type LinkConfig struct {
+	LUpload   float64 `yaml:"upload"`
+	LDownload float64 `yaml:"download"`
+}
+
+func (config *LinkConfig) Upload() float64 {
+	return config.LUpload
+}
+
+func (config *LinkConfig) Download() float64 {
+	return config.LDownload
+}
+
+type ChurnClassConfig struct {
+	CSession  DistributionConfig `yaml:"session"`
+	CDowntime DistributionConfig `yaml:"downtime"`
+}
+
+func (config *ChurnClassConfig) Session() interfaces.IDistributionConfig {
+	return &config.CSession
+}
+
+func (config *ChurnClassConfig) Downtime() interfaces.IDistributionConfig {
+	return &config.CDowntime
+}
+
+type OnlineChangeConfig struct {
+	OTime   int64    `yaml:"time"`
+	ONodes  []string `yaml:"nodes"`
+	OOnline bool     `yaml:"online"`
+}
+
+func (config *OnlineChangeConfig) Time() int64 {
+	return config.OTime
+}
+
+func (config *OnlineChangeConfig) Nodes() []string {
+	return config.ONodes
+}
+
+func (config *OnlineChangeConfig) Online() bool {
+	return config.OOnline
+}
+
+type HashPowerChangeConfig struct {
+	HTime   int64    `yaml:"time"`
+	HNodes  []string `yaml:"nodes"`
+	HFactor float64  `yaml:"factor"`
+}
+
+func (config *HashPowerChangeConfig) Time() int64 {
+	return config.HTime
+}
+
+func (config *HashPowerChangeConfig) Nodes() []string {
+	return config.HNodes
+}
+
+func (config *HashPowerChangeConfig) Factor() float64 {
+	return config.HFactor
+}
+
+type PoolMembersConfig struct {
+	PShareDifficulty float64       `yaml:"shareDifficulty"`
+	PPoolFee         float64       `yaml:"poolFee"`
+	PPplnsWindow     int           `yaml:"pplnsWindow"`
+	PPools           []*PoolConfig `yaml:"pools"`
+}
+
+type PoolConfig struct {
+	PPool         int                      `yaml:"pool"`
+	PMembers      []float64                `yaml:"members"`
+	PWithholders  []float64                `yaml:"withholders"`
+	PInfiltrators []*PoolInfiltratorConfig `yaml:"infiltrators"`
+}
+
+type PoolInfiltratorConfig struct {
+	POwner     int     `yaml:"owner"`
+	PHashPower float64 `yaml:"hashPower"`
+}
+
+func (config *PoolMembersConfig) ShareDifficulty() float64 {
+	return config.PShareDifficulty
+}
+
+func (config *PoolMembersConfig) PoolFee() float64 {
+	return config.PPoolFee
+}
+
+func (config *PoolMembersConfig) PplnsWindow() int {
+	return config.PPplnsWindow
+}
+
+func (config *PoolMembersConfig) Pools() []interfaces.IPoolConfig {
+	pools := make([]interfaces.IPoolConfig, 0, len(config.PPools))
+	for _, pool := range config.PPools {
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+func (config *PoolConfig) Pool() int {
+	return config.PPool
+}
+
+func (config *PoolConfig) Members() []float64 {
+	return config.PMembers
+}
+
+func (config *PoolConfig) Withholders() []float64 {
+	return config.PWithholders
+}
+
+func (config *PoolConfig) Infiltrators() []interfaces.IPoolInfiltratorConfig {
+	infiltrators := make([]interfaces.IPoolInfiltratorConfig, 0, len(config.PInfiltrators))
+	for _, infiltrator := range config.PInfiltrators {
+		infiltrators = append(infiltrators, infiltrator)
+	}
+	return infiltrators
+}
+
+func (config *PoolInfiltratorConfig) Owner() int {
+	return config.POwner
+}
+
+func (config *PoolInfiltratorConfig) HashPower() float64 {
+	return config.PHashPower
+}
+
 func (config *Config) Seed() uint64 {
 	return config.CSeed
 }
@@ -172,14 +464,160 @@ func (config *Config) AttackerActive() bool {
 }
 
 func (config *Config) Attacker() interfaces.IAttackerConfig {
-	return config.CAttacker
+	return config.Attackers()[0]
+}
+
+func (config *Config) Attackers() []interfaces.IAttackerConfig {
+	attackers := make([]interfaces.IAttackerConfig, 0, len(config.CAttackers)+1)
+	for _, attacker := range config.CAttackers {
+		attackers = append(attackers, attacker)
+	}
+	if len(attackers) == 0 && config.CAttacker != nil {
+		attackers = append(attackers, config.CAttacker)
+	}
+	if len(attackers) == 0 {
+		attackers = append(attackers, &AttackerConfig{})
+	}
+	return attackers
+}
+
+func (config *Config) PartitionActive() bool {
+	return config.CPartitionActive && config.CPartition != nil
+}
+
+func (config *Config) Partition() interfaces.IPartitionConfig {
+	return config.CPartition
+}
+
+func (config *Config) Consensus() string {
+	if config.CConsensus == "" {
+		return interfaces.CONSENSUS_POW
+	}
+	return config.CConsensus
+}
+
+func (config *Config) ForkChoice() string {
+	if config.CForkChoice == "" {
+		return interfaces.FORK_CHOICE_TOTAL_DIFFICULTY
+	}
+	return config.CForkChoice
+}
+
+func (config *Config) Pos() interfaces.IPosConfig {
+	return config.CPos
+}
+
+func (config *Config) Difficulty() string {
+	if config.CDifficulty == "" {
+		return interfaces.DIFFICULTY_SIMPLE
+	}
+	return config.CDifficulty
+}
+
+// GenesisDifficulty defaults to the difficulty of a 13 second block time with the overall hash power.
+func (config *Config) GenesisDifficulty() int {
+	if config.CGenesisDifficulty == 0 {
+		return int(config.OverallHashPower() * 13)
+	}
+	return config.CGenesisDifficulty
+}
+
+func (config *Config) GenesisNumber() uint64 {
+	return config.CGenesisNumber
+}
+
+func (config *Config) FeeMarket() string {
+	if config.CFeeMarket == "" {
+		return interfaces.FEE_MARKET_LEGACY
+	}
+	return config.CFeeMarket
+}
+
+func (config *Config) AccountState() bool {
+	return config.CAccountState
+}
+
+func (config *Config) InitialBalance() float64 {
+	return config.CInitialBalance
+}
+
+func (config *Config) ClockSkewActive() bool {
+	return config.CClockSkewActive && config.CClockSkew != nil
+}
+
+func (config *Config) ClockSkew() interfaces.IClockSkewConfig {
+	return config.CClockSkew
+}
+
+func (config *Config) BlockPropagation() string {
+	if config.CBlockPropagation == "" {
+		return interfaces.PROPAGATION_SQRT
+	}
+	return config.CBlockPropagation
+}
+
+func (config *Config) TxPropagation() string {
+	if config.CTxPropagation == "" {
+		return interfaces.PROPAGATION_SQRT
+	}
+	return config.CTxPropagation
+}
+
+func (config *Config) ChurnActive() bool {
+	return config.CChurnActive && config.CChurn != nil
+}
+
+func (config *Config) Churn() interfaces.IChurnConfig {
+	return config.CChurn
+}
+
+func (config *Config) TopologyActive() bool {
+	return config.CTopologyActive && config.CTopology != nil
+}
+
+func (config *Config) Topology() interfaces.ITopologyConfig {
+	return config.CTopology
+}
+
+func (config *Config) BandwidthActive() bool {
+	return config.CBandwidthActive && config.CBandwidth != nil
+}
+
+func (config *Config) Bandwidth() interfaces.IBandwidthConfig {
+	return config.CBandwidth
+}
+
+func (config *Config) LocationShares() map[string]float64 {
+	return config.CLocationShares
+}
+
+func (config *Config) PoolLocationShares() map[string]float64 {
+	return config.CPoolLocationShares
+}
+
+func (config *Config) HashPowerChanges() []interfaces.IHashPowerChangeConfig {
+	changes := make([]interfaces.IHashPowerChangeConfig, 0, len(config.CHashPowerChanges))
+	for _, change := range config.CHashPowerChanges {
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func (config *Config) PoolMembersActive() bool {
+	return config.CPoolMembersActive && config.CPoolMembers != nil
+}
+
+func (config *Config) PoolMembers() interfaces.IPoolMembersConfig {
+	return config.CPoolMembers
 }
 
 type DelaysConfig struct {
+	Regions                []string                                  `yaml:"-"` // keys of locations in file order
 	Locations              map[string]map[string]DelayLocationConfig `yaml:"locations"`
 	TimeBetweenBlocks      DistributionConfig                        `yaml:"timeBetweenBlocks"` //in s
 	TxGas                  DistributionConfig                        `yaml:"txGas"`
-	GasPrice               DistributionConfig                        `yaml:"gasPrice"`
+	GasPrice               DistributionConfig                        `yaml:"gasPrice"`    // max fee per gas with EIP-1559
+	PriorityFee            DistributionConfig                        `yaml:"priorityFee"` // max priority fee with EIP-1559
 	TxStateComputation     float64                                   `yaml:"txStateComputation"`
 	BaseHeaderVerification float64                                   `yaml:"baseHeaderVerification"`
 	BaseBodyVerification   float64                                   `yaml:"baseBodyVerification"`
@@ -197,6 +635,14 @@ type DistributionConfig struct {
 	Params       []float64 `yaml:"params"`
 }
 
+func (config *DistributionConfig) Name() string {
+	return config.Distribution
+}
+
+func (config *DistributionConfig) Parameters() []float64 {
+	return config.Params
+}
+
 func LoadConfig() *Config {
 	var config Config
 	yamlFile, err := ioutil.ReadFile("config.yml")
@@ -221,10 +667,39 @@ func LoadDelaysConfig() *DelaysConfig {
 	if err != nil {
 		log.Panic(err)
 	}
+	// the order of the regions is kept as nodes are assigned to them by a random draw
+	var regions struct {
+		Locations yaml.MapSlice `yaml:"locations"`
+	}
+	err = yaml.Unmarshal(yamlFile, &regions)
+	if err != nil {
+		log.Panic(err)
+	}
+	for _, item := range regions.Locations {
+		config.Regions = append(config.Regions, fmt.Sprintf("%v", item.Key))
+	}
 
 	return &config
 }
 
+// LoadCsv loads all records of a csv file, lines starting with # are ignored.
+func LoadCsv(filename string) [][]string {
+	csvFile, err := os.Open(filename)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer csvFile.Close()
+	reader := csv.NewReader(csvFile)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		log.Panic(err)
+	}
+	return records
+}
+
 func WorldFile(config *Config) *os.File {
 	outFile := fmt.Sprintf("%v/%v/world.json", config.OutPath(), config.Seed())
 	if FileExists(outFile) {
@@ -0,0 +1,157 @@
+package file
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Topology is a peer graph loaded from a GraphML file or an edge list (csv with one "source,target" per line).
+type Topology struct {
+	Nodes []*TopologyNode // in file order
+	Edges [][2]string
+}
+
+// TopologyNode holds the optional attributes of a node, unset attributes are empty or 0.
+type TopologyNode struct {
+	Id        string
+	Location  string
+	HashPower float64 // MH/s
+	MaxPeers  int
+}
+
+type graphML struct {
+	Keys  []graphMLKey `xml:"key"`
+	Graph struct {
+		Nodes []struct {
+			Id   string        `xml:"id,attr"`
+			Data []graphMLData `xml:"data"`
+		} `xml:"node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+			Target string `xml:"target,attr"`
+		} `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// LoadTopology loads a GraphML file (.graphml) with the node attributes location, hashPower and maxPeers or an edge list.
+func LoadTopology(filename string) *Topology {
+	if strings.HasSuffix(filename, ".graphml") {
+		return loadGraphML(filename)
+	}
+	topology := &Topology{}
+	known := make(map[string]bool)
+	for _, record := range LoadCsv(filename) {
+		if len(record) < 2 {
+			log.Panicf("topology edge %v needs a source and a target", record)
+		}
+		edge := [2]string{strings.TrimSpace(record[0]), strings.TrimSpace(record[1])}
+		for _, id := range edge {
+			if !known[id] {
+				known[id] = true
+				topology.Nodes = append(topology.Nodes, &TopologyNode{Id: id})
+			}
+		}
+		topology.Edges = append(topology.Edges, edge)
+	}
+	return topology
+}
+
+func loadGraphML(filename string) *Topology {
+	xmlFile, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Panic(err)
+	}
+	var graph graphML
+	err = xml.Unmarshal(xmlFile, &graph)
+	if err != nil {
+		log.Panic(err)
+	}
+	attributes := make(map[string]string, len(graph.Keys)) // key id to attribute name
+	for _, key := range graph.Keys {
+		if key.For == "node" || key.For == "all" {
+			attributes[key.Id] = key.Name
+		}
+	}
+	topology := &Topology{}
+	known := make(map[string]bool, len(graph.Graph.Nodes))
+	for _, n := range graph.Graph.Nodes {
+		node := &TopologyNode{Id: n.Id}
+		for _, data := range n.Data {
+			value := strings.TrimSpace(data.Value)
+			switch attributes[data.Key] {
+			case "location":
+				node.Location = value
+			case "hashPower":
+				node.HashPower, err = strconv.ParseFloat(value, 64)
+			case "maxPeers":
+				node.MaxPeers, err = strconv.Atoi(value)
+			}
+			if err != nil {
+				log.Panicf("topology node %v: %v", n.Id, err)
+			}
+		}
+		if known[n.Id] {
+			log.Panicf("topology node %v is defined twice", n.Id)
+		}
+		known[n.Id] = true
+		topology.Nodes = append(topology.Nodes, node)
+	}
+	for _, e := range graph.Graph.Edges {
+		if !known[e.Source] || !known[e.Target] {
+			log.Panicf("topology edge %v-%v connects an unknown node", e.Source, e.Target)
+		}
+		topology.Edges = append(topology.Edges, [2]string{e.Source, e.Target})
+	}
+	return topology
+}
+
+// Relabel renames the nodes and edges to the simulator's node ids. Nodes named by a simulator id keep it, the others
+// (i.e. crawled node ids) take the remaining simulator ids in file order.
+func (topology *Topology) Relabel(nodeIds []string) {
+	if len(topology.Nodes) != len(nodeIds) {
+		log.Panicf("topology has %v nodes, nodeCount is %v", len(topology.Nodes), len(nodeIds))
+	}
+	names := make(map[string]string, len(nodeIds)) // topology id to simulator id
+	for _, id := range nodeIds {
+		names[id] = ""
+	}
+	for _, node := range topology.Nodes {
+		if _, ok := names[node.Id]; ok {
+			names[node.Id] = node.Id
+		}
+	}
+	free := make([]string, 0, len(nodeIds))
+	for _, id := range nodeIds {
+		if names[id] == "" {
+			free = append(free, id)
+		}
+	}
+	for _, node := range topology.Nodes {
+		if names[node.Id] == "" {
+			names[node.Id] = free[0]
+			free = free[1:]
+		}
+		node.Id = names[node.Id]
+	}
+	for i, edge := range topology.Edges {
+		source, target := names[edge[0]], names[edge[1]]
+		if source == "" || target == "" {
+			log.Panicf("topology edge %v-%v connects a node that is not declared", edge[0], edge[1])
+		}
+		topology.Edges[i] = [2]string{source, target}
+	}
+}
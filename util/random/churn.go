@@ -0,0 +1,41 @@
+package random
+
+import (
+	"ethattacksim/interfaces"
+	"golang.org/x/exp/rand"
+	"math"
+)
+
+var churnSessions map[string]interfaces.IRNG
+var churnDowntimes map[string]interfaces.IRNG
+
+// ChurnSession returns the nanos a node of the class stays online, false if the class has no churn.
+func ChurnSession(class string) (int64, bool) {
+	return churnNanos(churnSessions, class)
+}
+
+// ChurnDowntime returns the nanos a node of the class stays offline, false if the class has no churn.
+func ChurnDowntime(class string) (int64, bool) {
+	return churnNanos(churnDowntimes, class)
+}
+
+func churnNanos(dists map[string]interfaces.IRNG, class string) (int64, bool) {
+	dist, ok := dists[class]
+	if !ok {
+		return 0, false
+	}
+	// at least a millisecond, so a distribution with negative values cannot stall the simulation
+	return int64(math.Max(dist.Rand(), 0.001) * 1000000000), true // seconds to nanos
+}
+
+// must be called before usage, the churn has its own source so the other random numbers are unchanged
+func InitializeChurn(seed uint64, config interfaces.IChurnConfig) {
+	source := rand.NewSource(seed + 2) // the clocks use seed and seed+1
+	classes := config.Classes()
+	churnSessions = make(map[string]interfaces.IRNG, len(classes))
+	churnDowntimes = make(map[string]interfaces.IRNG, len(classes))
+	for class, classConfig := range classes {
+		churnSessions[class] = GetDist(classConfig.Session().Name(), classConfig.Session().Parameters(), source)
+		churnDowntimes[class] = GetDist(classConfig.Downtime().Name(), classConfig.Downtime().Parameters(), source)
+	}
+}
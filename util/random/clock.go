@@ -0,0 +1,26 @@
+package random
+
+import (
+	"ethattacksim/interfaces"
+	"golang.org/x/exp/rand"
+)
+
+var clockOffset interfaces.IRNG
+var clockDrift interfaces.IRNG
+
+// ClockOffset returns the nanos the local clock of a node is ahead of the simulated time, negative if behind.
+func ClockOffset() int64 {
+	return int64(clockOffset.Rand() * 1000000) // millis to nanos
+}
+
+// ClockDrift returns the ppm (microseconds per second) the local clock of a node runs fast, negative if slow.
+func ClockDrift() float64 {
+	return clockDrift.Rand()
+}
+
+// must be called before usage, the clocks have their own sources so the other random numbers are unchanged
+func InitializeClocks(seed uint64, config interfaces.IClockSkewConfig) {
+	clockOffset = GetDist(config.Offset().Name(), config.Offset().Parameters(), rand.NewSource(seed))
+	// another seed as the same source would draw a drift correlated with the offset
+	clockDrift = GetDist(config.Drift().Name(), config.Drift().Parameters(), rand.NewSource(seed+1))
+}
@@ -11,6 +11,7 @@ import (
 //var timeBetweenBlocks interfaces.IRNG
 var txGas interfaces.IRNG
 var gasPrice interfaces.IRNG
+var priorityFee interfaces.IRNG
 var timeBetweenBlocksSource rand.Source
 var cfg *file.DelaysConfig
 
@@ -68,6 +69,18 @@ func TimeBetweenBlocks(totalHashPower float64, hashPower float64, lastBlockTime
 	return
 }
 
+// MiningTime returns the nanos until a node with hashPower (MH/s) finds a block of difficulty (MH).
+func MiningTime(hashPower float64, difficulty float64) int64 {
+	timeBetweenBlocksCount++
+	// exponential distribution with lambda = hashes per second / expected hashes per block
+	miningTime := GetDist(cfg.TimeBetweenBlocks.Distribution, []float64{hashPower / difficulty}, timeBetweenBlocksSource)
+	miningTimeDelay := int64(math.Round(miningTime.Rand() * 1000000000))
+	if miningTimeDelay < 0 || miningTimeDelay > 86400000000000 { // if overflow or bigger than one day
+		miningTimeDelay = 86400000000000
+	}
+	return miningTimeDelay
+}
+
 func TxGas(min int) int64 {
 	txGasCount++
 	gas := int64(math.Round(txGas.Rand()))
@@ -82,6 +95,15 @@ func GasPrice() int64 {
 	return price
 }
 
+// PriorityFee returns the max priority fee (gwei) of an EIP-1559 tx.
+func PriorityFee() int64 {
+	if priorityFee == nil {
+		log.Panic("priorityFee distribution missing in delays config")
+	}
+	fee := int64(math.Round(priorityFee.Rand()))
+	return int64(math.Max(float64(fee), 0))
+}
+
 func Latency(origin interfaces.ILocation, destination interfaces.ILocation) int64 {
 	delaysMapCount++
 	if _, ok := delaysRNGMap[origin]; !ok {
@@ -98,6 +120,9 @@ func Latency(origin interfaces.ILocation, destination interfaces.ILocation) int6
 }
 
 func ReceiveThroughput(origin interfaces.ILocation, destination interfaces.ILocation, bytes int) int64 {
+	if bytes <= 0 {
+		return 0 // empty message, i.e. the empty response to a request that cannot be served
+	}
 	delaysMapCount++
 	if _, ok := delaysRNGMap[origin]; !ok {
 		log.Panic("receivedThroughput of origin " + origin.String() + " not in map")
@@ -115,6 +140,9 @@ func ReceiveThroughput(origin interfaces.ILocation, destination interfaces.ILoca
 }
 
 func SendThroughput(origin interfaces.ILocation, destination interfaces.ILocation, bytes int) int64 {
+	if bytes <= 0 {
+		return 0
+	}
 	delaysMapCount++
 	if _, ok := delaysRNGMap[origin]; !ok {
 		log.Panic("sentThroughput of origin " + origin.String() + " not in map")
@@ -153,11 +181,19 @@ func InitializeDelays(seed uint64, config *file.DelaysConfig) {
 	var gasPriceSource rand.Source = rand.NewSource(seed)
 	gasPrice = GetDist(config.GasPrice.Distribution, config.GasPrice.Params, gasPriceSource)
 
+	priorityFee = nil
+	if config.PriorityFee.Distribution != "" {
+		// another seed as the same source would draw the priority fee in lockstep with the gas price, seed+1 to seed+3
+		// are taken by the clocks, the churn and the topology
+		var priorityFeeSource rand.Source = rand.NewSource(seed + 4)
+		priorityFee = GetDist(config.PriorityFee.Distribution, config.PriorityFee.Params, priorityFeeSource)
+	}
+
 	delaysRNGMap = make(map[interfaces.ILocation]map[interfaces.ILocation]*DelaysRNG)
 	for originKey, destinationMap := range config.Locations {
 		for destinationKey, delaysConfig := range destinationMap {
-			origin := interfaces.LOCATION_MAP[originKey]
-			destination := interfaces.LOCATION_MAP[destinationKey]
+			origin := interfaces.NewLocation(originKey)
+			destination := interfaces.NewLocation(destinationKey)
 			latencyRng := getRNGFromDistributionConfig(seed, &delaysConfig.Latency)
 			sendThroughputRng := getRNGFromDistributionConfig(seed, &delaysConfig.SendThroughput)
 			receiveThroughputRng := getRNGFromDistributionConfig(seed, &delaysConfig.ReceiveThroughput)
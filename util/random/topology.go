@@ -0,0 +1,18 @@
+package random
+
+import (
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+var topologyUniform *distuv.Uniform
+
+// TopologyUniform returns a uniform random number in [0, 1) for the topology generators.
+func TopologyUniform() float64 {
+	return topologyUniform.Rand()
+}
+
+// must be called before usage, the topology has its own source so the other random numbers are unchanged
+func InitializeTopology(seed uint64) {
+	topologyUniform = &distuv.Uniform{Min: 0, Max: 1, Src: rand.NewSource(seed + 3)} // the clocks use seed and seed+1, the churn seed+2
+}
@@ -29,6 +29,8 @@ type StatsOverview struct {
 	StatsPerNodePerType               map[string]map[string]float64
 	PeersPerNode                      map[string]string
 	CurrentLedgerBlockIds             map[string]map[int]string
+	StatsPerAttackerGroup             map[string]map[string]float64
+	ForkChoice                        string // rule the stale blocks and rewards result from
 }
 
 func NewStatsOverview(world interfaces.IWorld, config *file.Config) *StatsOverview {
@@ -59,6 +61,11 @@ func NewStatsOverview(world interfaces.IWorld, config *file.Config) *StatsOvervi
 		statsPerNodePerType[n.Id()]["current"] = float64(n.Ledger().Length(n))
 		statsPerNodePerType[n.Id()]["ledger"] = float64(len(n.Ledger().Get()))
 		statsPerNodePerType[n.Id()]["uncles"] = float64(len(n.Ledger().Uncles()))
+		// blocks known to the node that are not part of its current chain after the fork choice
+		statsPerNodePerType[n.Id()]["staleBlocks"] = float64(len(n.Ledger().Get()) - n.Ledger().Length(n))
+		if len(n.Ledger().Get()) > 1 {
+			statsPerNodePerType[n.Id()]["staleRate"] = statsPerNodePerType[n.Id()]["staleBlocks"] / float64(len(n.Ledger().Get())-1) // without genesis
+		}
 		peerString := ""
 		for i, peer := range n.Peers() {
 			peerString += peer.Id()
@@ -86,6 +93,8 @@ func NewStatsOverview(world interfaces.IWorld, config *file.Config) *StatsOvervi
 		txCount := 0
 		blockSizeCumulated := 0
 		gasPriceCumulated := 0
+		baseFeeCumulated := 0
+		burntFees := 0.0
 		for i, block := range n.Ledger().CurrentLedgerByHeight() {
 			if expectedNum != block.Header().Number() {
 				log.Printf("error with block numbers")
@@ -121,20 +130,32 @@ func NewStatsOverview(world interfaces.IWorld, config *file.Config) *StatsOvervi
 				}
 			}
 
-			// tx costs reward
+			// tx costs reward, the miner only gets the tip with EIP-1559 while the base fee is burnt
 			gasReward := 0.0
 			gasRewardEip1559 := 0.0
+			baseFee := block.Header().BaseFee()
+			baseFeeCumulated += baseFee
 			for _, tx := range block.Body().Transactions() {
-				gasPriceCumulated += tx.GasPrice()
+				tip := tx.EffectiveTip(baseFee)
+				gasPriceCumulated += (baseFee + tip) / 1000000000                       // wei to gwei
+				burnt := float64(tx.GasUsed()) * float64(baseFee) / 1000000000000000000 // wei to eth, floats as the product may overflow int
+				burntFees += burnt
 				// compute rewards if sender is not miner
 				if tx.SenderId() != block.Header().MinerId() {
-					gasReward += float64(tx.GasUsed()*tx.GasPrice()) / 1000000000 // gwei to eth
+					gasReward += float64(tx.GasUsed()*tip) / 1000000000000000000 // wei to eth
 				}
 
-				if tx.SenderId() == block.Header().MinerId() {
+				if config.FeeMarket() == interfaces.FEE_MARKET_EIP1559 {
+					// the miner gets the tips and pays the base fee of the own txs
+					if tx.SenderId() != block.Header().MinerId() {
+						gasRewardEip1559 += float64(tx.GasUsed()*tip) / 1000000000000000000 // wei to eth
+					} else {
+						gasRewardEip1559 -= burnt
+					}
+				} else if tx.SenderId() == block.Header().MinerId() {
 					// here the gas is deducted for post EIP1559 transaction
 					// but only for the ones in the own block, these are the ones interesting us for i.e. verifiers dilemma as they are not broadcasted
-					gasRewardEip1559 -= float64(tx.GasUsed()*tx.GasPrice()) / 1000000000 // gwei to eth
+					gasRewardEip1559 -= float64(tx.GasUsed()*tip) / 1000000000000000000 // wei to eth
 				}
 			}
 			//log.Printf("%v - gas %v - gasEip1559 %v", block.Header().MinerId(), gasReward, gasRewardEip1559)
@@ -159,10 +180,12 @@ func NewStatsOverview(world interfaces.IWorld, config *file.Config) *StatsOvervi
 		daysSimulated := float64(timeSimulated) / 1000000000 / 60 / 60 / 24
 		statsPerNodePerType[n.Id()]["throughput"] = float64(txCount) / secondsSimulated // tx/s
 		statsPerNodePerType[n.Id()]["unclesPerDay"] = statsPerNodePerType[n.Id()]["uncles"] / daysSimulated
-		statsPerNodePerType[n.Id()]["meanBlockTime"] = secondsSimulated / statsPerNodePerType[n.Id()]["current"]   // seconds
-		statsPerNodePerType[n.Id()]["meanBlockSize"] = float64(blockSizeCumulated) / float64(n.Ledger().Length(n)) // bytes
-		statsPerNodePerType[n.Id()]["meanGasPrice"] = float64(gasPriceCumulated) / float64(txCount)                // gwei
-		statsPerNodePerType[n.Id()]["hashRate"] = n.HashPower()                                                    // MH
+		statsPerNodePerType[n.Id()]["meanBlockTime"] = secondsSimulated / statsPerNodePerType[n.Id()]["current"]            // seconds
+		statsPerNodePerType[n.Id()]["meanBlockSize"] = float64(blockSizeCumulated) / float64(n.Ledger().Length(n))          // bytes
+		statsPerNodePerType[n.Id()]["meanGasPrice"] = float64(gasPriceCumulated) / float64(txCount)                         // gwei
+		statsPerNodePerType[n.Id()]["meanBaseFee"] = float64(baseFeeCumulated) / float64(n.Ledger().Length(n)) / 1000000000 // gwei
+		statsPerNodePerType[n.Id()]["burntFees"] = burntFees                                                                // eth
+		statsPerNodePerType[n.Id()]["hashRate"] = n.HashPower()                                                             // MH
 		statsPerNodePerType[n.Id()]["hashRatePercentage"] = n.HashPower() / world.SimConfig().OverallHashPower() * 100
 		statsPerNodePerType[n.Id()]["rewards"] = rewardsPerNodePerNode[n.Id()][n.Id()] // eth
 		statsPerNodePerType[n.Id()]["rewardsPercentage"] = rewardsPerNodePerNode[n.Id()][n.Id()] / overallRewards * 100
@@ -173,7 +196,53 @@ func NewStatsOverview(world interfaces.IWorld, config *file.Config) *StatsOvervi
 		statsPerNodePerType[n.Id()]["overallRewardsAfterEip1559"] = overallRewardsAfterEip1559
 	}
 
-	return &StatsOverview{world.Time(), blockCount, minedBlockCount, rewardsPerNodePerNode, rewardsPerNodePerNodeAfterEip1559, statsPerNodePerType, peersPerNode, currentLedgerBlockIdsPerNode}
+	// add stats of special consensus implementations (i.e. attacks)
+	for _, nId := range nodeIds {
+		if consensusStats, ok := world.Nodes()[nId].Consensus().(interfaces.IConsensusStats); ok {
+			for statsNodeId, nodeStats := range consensusStats.ConsensusStats(world.Nodes()[nId], world) {
+				if _, ok := statsPerNodePerType[statsNodeId]; !ok {
+					statsPerNodePerType[statsNodeId] = make(map[string]float64)
+				}
+				for statsType, value := range nodeStats {
+					statsPerNodePerType[statsNodeId][statsType] = value
+				}
+			}
+		}
+	}
+
+	// add stats of scenarios (i.e. a network partition)
+	for _, provider := range world.StatsProviders() {
+		for statsId, providerStats := range provider.Stats(world) {
+			if _, ok := statsPerNodePerType[statsId]; !ok {
+				statsPerNodePerType[statsId] = make(map[string]float64)
+			}
+			for statsType, value := range providerStats {
+				statsPerNodePerType[statsId][statsType] = value
+			}
+		}
+	}
+
+	// sum up the stats of the attacker groups as seen by the first node of each group
+	statsPerAttackerGroup := make(map[string]map[string]float64)
+	for group, groupNodeIds := range world.AttackerGroups() {
+		if len(groupNodeIds) == 0 {
+			continue
+		}
+		viewId := groupNodeIds[0]
+		groupStats := make(map[string]float64)
+		for _, nId := range groupNodeIds {
+			groupStats["nodes"]++
+			groupStats["hashRatePercentage"] += statsPerNodePerType[nId]["hashRatePercentage"]
+			groupStats["minedBlocks"] += float64(minedBlockCount[viewId][nId])
+			groupStats["rewards"] += rewardsPerNodePerNode[viewId][nId]
+			groupStats["rewardsAfterEip1559"] += rewardsPerNodePerNodeAfterEip1559[viewId][nId]
+		}
+		groupStats["rewardsPercentage"] = groupStats["rewards"] / rewardsPerNodePerNode[viewId]["ALL"] * 100
+		groupStats["rewardsPercentageAfterEip1559"] = groupStats["rewardsAfterEip1559"] / rewardsPerNodePerNodeAfterEip1559[viewId]["ALL"] * 100
+		statsPerAttackerGroup[group] = groupStats
+	}
+
+	return &StatsOverview{world.Time(), blockCount, minedBlockCount, rewardsPerNodePerNode, rewardsPerNodePerNodeAfterEip1559, statsPerNodePerType, peersPerNode, currentLedgerBlockIdsPerNode, statsPerAttackerGroup, world.SimConfig().ForkChoice()}
 }
 
 const float64EqualityThreshold = 1e-9
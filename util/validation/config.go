@@ -1,12 +1,15 @@
 package validation
 
 import (
+	"ethattacksim/interfaces"
 	"ethattacksim/util/file"
+	"fmt"
 	"log"
 	"strings"
 )
 
-func ValidateConfig(config *file.Config) {
+// ValidateConfig panics with all configuration errors, the attacker groups are checked by the attackerValidator (i.e. against the registered attacker types).
+func ValidateConfig(config *file.Config, attackerValidator func(attacker interfaces.IAttackerConfig) []string) {
 	// add config validation here
 	var err []string = make([]string, 0, 2)
 	if config.Seed() < 0 {
@@ -18,6 +21,136 @@ func ValidateConfig(config *file.Config) {
 	if strings.HasSuffix(config.OutPath(), "/") {
 		err = append(err, "OutPath should not end with '/'")
 	}
+	if config.AttackerActive() {
+		attackerNames := make(map[string]bool)
+		for _, attacker := range config.Attackers() {
+			if attackerNames[attacker.Name()] {
+				err = append(err, fmt.Sprintf("Attacker group name %v is not unique", attacker.Name()))
+			}
+			attackerNames[attacker.Name()] = true
+			err = append(err, attackerValidator(attacker)...)
+		}
+	}
+	switch config.Consensus() {
+	case interfaces.CONSENSUS_POW:
+		switch config.ForkChoice() {
+		case interfaces.FORK_CHOICE_TOTAL_DIFFICULTY, interfaces.FORK_CHOICE_LONGEST_CHAIN, interfaces.FORK_CHOICE_GHOST:
+		default:
+			err = append(err, fmt.Sprintf("Unknown forkChoice %v, use %v, %v or %v", config.ForkChoice(), interfaces.FORK_CHOICE_TOTAL_DIFFICULTY, interfaces.FORK_CHOICE_LONGEST_CHAIN, interfaces.FORK_CHOICE_GHOST))
+		}
+		switch config.Difficulty() {
+		case interfaces.DIFFICULTY_SIMPLE:
+		case interfaces.DIFFICULTY_ETHEREUM:
+			if config.GenesisDifficulty() <= 0 {
+				err = append(err, "genesisDifficulty should be positive")
+			}
+		default:
+			err = append(err, fmt.Sprintf("Unknown difficulty %v, use %v or %v", config.Difficulty(), interfaces.DIFFICULTY_SIMPLE, interfaces.DIFFICULTY_ETHEREUM))
+		}
+		for _, change := range config.HashPowerChanges() {
+			if change.Factor() <= 0 { // hash power is also used for the verification time
+				err = append(err, "hashPowerChanges factor should be positive")
+			}
+			if change.Time() < 0 {
+				err = append(err, "hashPowerChanges time should not be negative")
+			}
+		}
+	case interfaces.CONSENSUS_POS:
+		if len(config.HashPowerChanges()) > 0 {
+			err = append(err, "hashPowerChanges are only supported with consensus pow")
+		}
+		if config.Pos() == nil || config.Pos().SlotTime() <= 0 || config.Pos().SlotsPerEpoch() <= 0 {
+			err = append(err, "pos needs a positive slotTime and slotsPerEpoch")
+		}
+	default:
+		err = append(err, fmt.Sprintf("Unknown consensus %v, use %v or %v", config.Consensus(), interfaces.CONSENSUS_POW, interfaces.CONSENSUS_POS))
+	}
+	switch config.FeeMarket() {
+	case interfaces.FEE_MARKET_LEGACY:
+	case interfaces.FEE_MARKET_EIP1559:
+		if initialBaseFee, ok := config.Limits()["initialBaseFee"]; ok && initialBaseFee <= 0 {
+			err = append(err, "limits initialBaseFee should be positive")
+		}
+	default:
+		err = append(err, fmt.Sprintf("Unknown feeMarket %v, use %v or %v", config.FeeMarket(), interfaces.FEE_MARKET_LEGACY, interfaces.FEE_MARKET_EIP1559))
+	}
+	switch config.BlockPropagation() {
+	case interfaces.PROPAGATION_SQRT, interfaces.PROPAGATION_FULL, interfaces.PROPAGATION_ANNOUNCE, interfaces.PROPAGATION_COMPACT:
+	default:
+		err = append(err, fmt.Sprintf("Unknown blockPropagation %v, use %v, %v, %v or %v", config.BlockPropagation(), interfaces.PROPAGATION_SQRT, interfaces.PROPAGATION_FULL, interfaces.PROPAGATION_ANNOUNCE, interfaces.PROPAGATION_COMPACT))
+	}
+	switch config.TxPropagation() {
+	case interfaces.PROPAGATION_SQRT, interfaces.PROPAGATION_FULL, interfaces.PROPAGATION_ANNOUNCE:
+	default:
+		err = append(err, fmt.Sprintf("Unknown txPropagation %v, use %v, %v or %v", config.TxPropagation(), interfaces.PROPAGATION_SQRT, interfaces.PROPAGATION_FULL, interfaces.PROPAGATION_ANNOUNCE))
+	}
+	if config.ClockSkewActive() && (config.ClockSkew().Offset().Name() == "" || config.ClockSkew().Drift().Name() == "") {
+		err = append(err, "clockSkew needs an offset and a drift distribution")
+	}
+	if config.ChurnActive() {
+		for class, classConfig := range config.Churn().Classes() {
+			if class != interfaces.NODE_CLASS_NODE && class != interfaces.NODE_CLASS_POOL && class != interfaces.NODE_CLASS_ATTACKER {
+				err = append(err, fmt.Sprintf("Unknown churn class %v, use %v, %v or %v", class, interfaces.NODE_CLASS_NODE, interfaces.NODE_CLASS_POOL, interfaces.NODE_CLASS_ATTACKER))
+			}
+			if classConfig.Session().Name() == "" || classConfig.Downtime().Name() == "" {
+				err = append(err, fmt.Sprintf("churn class %v needs a session and a downtime distribution", class))
+			}
+		}
+		for _, change := range config.Churn().Schedule() {
+			if change.Time() < 0 {
+				err = append(err, "churn schedule time should not be negative")
+			}
+		}
+	}
+	if config.TopologyActive() {
+		switch config.Topology().Model() {
+		case interfaces.TOPOLOGY_FILE:
+			if config.Topology().File() == "" {
+				err = append(err, "topology file should be set")
+			}
+		case interfaces.TOPOLOGY_ERDOS_RENYI, interfaces.TOPOLOGY_BARABASI_ALBERT, interfaces.TOPOLOGY_SMALL_WORLD:
+			if config.Topology().Degree() <= 0 || uint64(config.Topology().Degree()) >= config.NodeCount() {
+				err = append(err, "topology degree should be positive and less than nodeCount")
+			}
+			if config.Topology().Rewiring() < 0 || config.Topology().Rewiring() > 1 {
+				err = append(err, "topology rewiring should be in [0, 1]")
+			}
+		default:
+			err = append(err, fmt.Sprintf("Unknown topology model %v, use %v, %v, %v or %v", config.Topology().Model(), interfaces.TOPOLOGY_FILE, interfaces.TOPOLOGY_ERDOS_RENYI, interfaces.TOPOLOGY_BARABASI_ALBERT, interfaces.TOPOLOGY_SMALL_WORLD))
+		}
+	}
+	if config.BandwidthActive() {
+		for class, link := range config.Bandwidth().Classes() {
+			if class != interfaces.NODE_CLASS_NODE && class != interfaces.NODE_CLASS_POOL && class != interfaces.NODE_CLASS_ATTACKER {
+				err = append(err, fmt.Sprintf("Unknown bandwidth class %v, use %v, %v or %v", class, interfaces.NODE_CLASS_NODE, interfaces.NODE_CLASS_POOL, interfaces.NODE_CLASS_ATTACKER))
+			}
+			if link.Upload() <= 0 || link.Download() <= 0 {
+				err = append(err, fmt.Sprintf("bandwidth class %v needs a positive upload and download", class))
+			}
+		}
+	}
+	if timeout, ok := config.Limits()["requestTimeout"]; ok && timeout <= 0 {
+		err = append(err, "limits requestTimeout should be positive")
+	}
+	if _, ok := config.Limits()["syncThreshold"]; ok {
+		if _, ok := config.Limits()["requestTimeout"]; !ok {
+			err = append(err, "limits syncThreshold needs limits requestTimeout")
+		}
+		if config.Limits()["syncBatch"] <= 0 {
+			err = append(err, "limits syncBatch should be positive")
+		}
+	}
+	for _, limit := range []string{"maxFutureBlocks", "txPoolGlobalSlots", "txPoolAccountSlots", "txPoolGlobalQueue", "txPoolAccountQueue", "txPoolPriceBump", "requestRetries", "maxPeerTimeouts", "syncThreshold"} {
+		if config.Limits()[limit] < 0 {
+			err = append(err, fmt.Sprintf("limits %v should not be negative", limit))
+		}
+	}
+	if config.AccountState() && config.InitialBalance() < 0 {
+		err = append(err, "initialBalance should not be negative")
+	}
+	if config.PoolMembersActive() {
+		err = append(err, validatePoolMembers(config)...)
+	}
 
 	if len(err) > 0 {
 		var errMessage string = "There are configuration errors:\n"
@@ -27,3 +160,110 @@ func ValidateConfig(config *file.Config) {
 		log.Panic(errMessage)
 	}
 }
+
+func validatePoolMembers(config *file.Config) (err []string) {
+	poolCount := len(config.MiningPoolsHashPower())
+	if config.PoolMembers().ShareDifficulty() <= 0 {
+		err = append(err, "poolMembers shareDifficulty should be positive")
+	}
+	if config.PoolMembers().PoolFee() < 0 || config.PoolMembers().PoolFee() >= 1 {
+		err = append(err, "poolMembers poolFee should be in [0, 1)")
+	}
+	if config.PoolMembers().PplnsWindow() <= 0 {
+		err = append(err, "poolMembers pplnsWindow should be positive")
+	}
+	pools := make(map[int]bool)
+	infiltratedPower := make(map[int]float64)
+	for _, pool := range config.PoolMembers().Pools() {
+		if pool.Pool() < 1 || pool.Pool() > poolCount {
+			err = append(err, fmt.Sprintf("poolMembers pool %v does not exist, use 1 to %v", pool.Pool(), poolCount))
+			continue
+		}
+		if pools[pool.Pool()] {
+			err = append(err, fmt.Sprintf("poolMembers pool %v is configured twice", pool.Pool()))
+		}
+		pools[pool.Pool()] = true
+		for _, fraction := range append(append([]float64{}, pool.Members()...), pool.Withholders()...) {
+			if fraction <= 0 {
+				err = append(err, fmt.Sprintf("poolMembers pool %v: member and withholder fractions should be positive", pool.Pool()))
+				break
+			}
+		}
+		for _, infiltrator := range pool.Infiltrators() {
+			if infiltrator.Owner() < 1 || infiltrator.Owner() > poolCount || infiltrator.Owner() == pool.Pool() {
+				err = append(err, fmt.Sprintf("poolMembers pool %v: infiltrator owner %v should be another pool", pool.Pool(), infiltrator.Owner()))
+				continue
+			}
+			if infiltrator.HashPower() <= 0 {
+				err = append(err, fmt.Sprintf("poolMembers pool %v: infiltrator hashPower should be positive", pool.Pool()))
+			}
+			infiltratedPower[infiltrator.Owner()] += infiltrator.HashPower()
+		}
+	}
+	for owner := 1; owner <= poolCount; owner++ {
+		if infiltratedPower[owner] >= config.MiningPoolsHashPower()[owner-1] {
+			err = append(err, fmt.Sprintf("poolMembers pool %v moves more hash power to infiltrated pools than it has", owner))
+		}
+	}
+	return err
+}
+
+// ValidateDelaysConfig panics if the delays between two regions of delays.yml are missing or the config uses unknown regions.
+func ValidateDelaysConfig(delays *file.DelaysConfig, config *file.Config) {
+	var err []string = make([]string, 0, 2)
+	if len(delays.Regions) == 0 {
+		err = append(err, "delays.yml needs at least one location")
+	}
+	regions := make(map[string]bool, len(delays.Regions))
+	for _, origin := range delays.Regions {
+		regions[origin] = true
+		for _, destination := range delays.Regions {
+			delay, ok := delays.Locations[origin][destination]
+			if !ok || delay.Latency.Distribution == "" || delay.SendThroughput.Distribution == "" || delay.ReceiveThroughput.Distribution == "" {
+				err = append(err, fmt.Sprintf("delays.yml needs the latency, sendThroughput and receiveThroughput from %v to %v", origin, destination))
+			}
+		}
+	}
+	for i, shares := range []map[string]float64{config.LocationShares(), config.PoolLocationShares()} {
+		name := []string{"locationShares", "poolLocationShares"}[i]
+		total := 0.0
+		for region, share := range shares {
+			if !regions[region] {
+				err = append(err, fmt.Sprintf("%v region %v is not a location of delays.yml", name, region))
+			}
+			if share < 0 {
+				err = append(err, fmt.Sprintf("%v of %v should not be negative", name, region))
+			}
+			total += share
+		}
+		if len(shares) > 0 && total <= 0 {
+			err = append(err, fmt.Sprintf("%v should have a positive share", name))
+		}
+	}
+	if config.AttackerActive() {
+		for _, attacker := range config.Attackers() {
+			for _, location := range attacker.Location() {
+				if !regions[location] {
+					err = append(err, fmt.Sprintf("Attacker group %v location %v is not a location of delays.yml", attacker.Name(), location))
+				}
+			}
+		}
+	}
+	if config.PartitionActive() {
+		for _, group := range config.Partition().Groups() {
+			for _, location := range group {
+				if !regions[location] {
+					err = append(err, fmt.Sprintf("partition location %v is not a location of delays.yml", location))
+				}
+			}
+		}
+	}
+
+	if len(err) > 0 {
+		var errMessage string = "There are delays configuration errors:\n"
+		for _, err := range err {
+			errMessage += err + "\n"
+		}
+		log.Panic(errMessage)
+	}
+}
@@ -29,10 +29,12 @@ type World struct {
 	simConfig           interfaces.IConfig
 	printMemStats       bool
 	simStopped          bool
+	attackerGroups      map[string][]string
+	statsProviders      []interfaces.IStatsProvider
 }
 
 func NewWorld(queue interfaces.IQueue, simConfig interfaces.IConfig) interfaces.IWorld {
-	return &World{endTime: simConfig.EndTime(), queue: queue, WTime: 0, WNodes: make(map[string]interfaces.INode), WUsers: make(map[string]int), eventsExecutedCount: 0, nodeIdCount: 0, specialNodeIdCount: make(map[string]uint64), txIdCount: 0, nodeIds: make([]string, 0), userIds: make([]string, 0), userIdCount: 0, simConfig: simConfig, printMemStats: simConfig.PrintMemStats(), simStopped: false}
+	return &World{endTime: simConfig.EndTime(), queue: queue, WTime: 0, WNodes: make(map[string]interfaces.INode), WUsers: make(map[string]int), eventsExecutedCount: 0, nodeIdCount: 0, specialNodeIdCount: make(map[string]uint64), txIdCount: 0, nodeIds: make([]string, 0), userIds: make([]string, 0), userIdCount: 0, simConfig: simConfig, printMemStats: simConfig.PrintMemStats(), simStopped: false, attackerGroups: make(map[string][]string), statsProviders: make([]interfaces.IStatsProvider, 0)}
 }
 
 func (world *World) Queue() interfaces.IQueue {
@@ -101,6 +103,22 @@ func (world *World) SimConfig() interfaces.IConfig {
 	return world.simConfig
 }
 
+func (world *World) AttackerGroups() map[string][]string {
+	return world.attackerGroups
+}
+
+func (world *World) AddAttackerGroupNodes(group string, nodeIds ...string) {
+	world.attackerGroups[group] = append(world.attackerGroups[group], nodeIds...)
+}
+
+func (world *World) StatsProviders() []interfaces.IStatsProvider {
+	return world.statsProviders
+}
+
+func (world *World) AddStatsProvider(provider interfaces.IStatsProvider) {
+	world.statsProviders = append(world.statsProviders, provider)
+}
+
 func (world *World) NodeIds() []string {
 	return world.nodeIds
 }